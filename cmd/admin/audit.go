@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"strconv"
+	"time"
+
+	"go-ovpn-bot/internal/database"
+)
+
+// runAudit выводит журнал аудита
+func runAudit(args []string) {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	action := fs.String("action", "", "Действие: code_failed, code_rejected, code_redeemed, code_lockout, config_download")
+	userArg := fs.String("user", "", "Telegram ID пользователя")
+	since := fs.Duration("since", 0, "Только записи за последний период (например, 24h)")
+	limit := fs.Int("limit", 100, "Максимальное число записей, 0 - все")
+	format := formatFlag(fs, formatTable)
+	fs.Parse(args)
+
+	_, db := openDB()
+	defer db.Close()
+
+	filter := database.AuditFilter{Action: *action, Limit: *limit}
+	if *userArg != "" {
+		filter.UserID = lookupUser(db, *userArg).ID
+	}
+	if *since > 0 {
+		filter.Since = time.Now().Add(-*since)
+	}
+
+	entries, err := db.ListAuditEntries(filter)
+	if err != nil {
+		log.Fatalf("Failed to list audit log: %v", err)
+	}
+	if entries == nil {
+		entries = []database.AuditEntry{}
+	}
+
+	t := &table{header: []string{"ID", "TIME", "TELEGRAM_ID", "ACTION", "DETAIL"}}
+	for _, e := range entries {
+		telegramID := ""
+		if e.TelegramID != 0 {
+			telegramID = strconv.FormatInt(e.TelegramID, 10)
+		}
+		t.add(strconv.FormatInt(e.ID, 10), formatTime(e.CreatedAt), telegramID, e.Action, e.Detail)
+	}
+	printOutput(*format, t, entries)
+}
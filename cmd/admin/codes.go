@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"go-ovpn-bot/internal/database"
+	"go-ovpn-bot/internal/generator"
+)
+
+// runCodes обрабатывает "ovpn-admin codes ..."
+func runCodes(args []string) {
+	sub, args := subcommand("codes", args)
+	switch sub {
+	case "generate":
+		runGenerate(args)
+	case "list":
+		runCodesList(args, formatTable)
+	case "export":
+		runCodesList(args, formatCSV)
+	case "revoke":
+		runCodesRevoke(args)
+	default:
+		unknownSubcommand("codes", sub)
+	}
+}
+
+// runGenerate создает коды активации
+func runGenerate(args []string) {
+	fs := flag.NewFlagSet("codes generate", flag.ExitOnError)
+	var (
+		limit   = fs.Int("limit", 1, "Лимит конфигураций для кода")
+		count   = fs.Int("count", 1, "Количество кодов для генерации")
+		quota   = fs.Int64("quota-gb", 0, "Месячная квота трафика в ГБ, добавляемая кодом (0 - без квоты)")
+		days    = fs.Int("days", 0, "Срок доступа в днях, на который код продлевает подписку (0 - бессрочно)")
+		uses    = fs.Int("uses", 1, "Сколько пользователей может активировать код (0 - без ограничений)")
+		expires = fs.String("expires", "", "Дата, после которой код нельзя активировать (YYYY-MM-DD)")
+		batch   = fs.String("batch", "", "Партия (кампания), к которой относятся коды")
+		note    = fs.String("note", "", "Заметка администратора")
+	)
+	fs.Parse(args)
+
+	opts := database.CodeOptions{
+		Limit:        *limit,
+		TrafficQuota: *quota << 30,
+		DurationDays: *days,
+		MaxUses:      *uses,
+		Batch:        *batch,
+		Note:         *note,
+	}
+	if *expires != "" {
+		// Код действует до конца указанного дня по местному времени
+		date, err := time.ParseInLocation("2006-01-02", *expires, time.Local)
+		if err != nil {
+			log.Fatalf("Invalid -expires date %q: %v", *expires, err)
+		}
+		expiresAt := date.AddDate(0, 0, 1)
+		opts.ExpiresAt = &expiresAt
+	}
+	if *uses < 0 {
+		log.Fatalf("-uses must not be negative")
+	}
+
+	cfg, db := openDB()
+	defer db.Close()
+
+	codes, err := generator.New(cfg.CodeOptions())
+	if err != nil {
+		log.Fatalf("Failed to create code generator: %v", err)
+	}
+
+	// Генерируем коды
+	fmt.Printf("Генерируем %d кодов с лимитом %d...\n\n", *count, *limit)
+
+	created := 0
+	for i := 0; i < *count; i++ {
+		code, err := codes.GenerateUnique(db.ActivationCodeExists)
+		if err != nil {
+			log.Printf("Failed to generate activation code: %v", err)
+			continue
+		}
+
+		// Создаем код в базе данных
+		activationCode, err := db.CreateActivationCode(code, opts)
+		if err != nil {
+			log.Printf("Failed to create activation code %s: %v", code, err)
+			continue
+		}
+
+		fmt.Printf("Код %d: %s (ID: %d, Лимит: %d)",
+			i+1, activationCode.Code, activationCode.ID, activationCode.Limit)
+		if activationCode.TrafficQuota > 0 {
+			fmt.Printf(", квота: %d ГБ/мес", activationCode.TrafficQuota>>30)
+		}
+		if activationCode.DurationDays > 0 {
+			fmt.Printf(", срок: %d дн.", activationCode.DurationDays)
+		}
+		if activationCode.MaxUses != 1 {
+			fmt.Printf(", активаций: %s", formatMaxUses(activationCode.MaxUses))
+		}
+		fmt.Println()
+		created++
+	}
+
+	fmt.Printf("\n✅ Успешно создано %d кодов активации!\n", created)
+}
+
+// runCodesList выводит коды по фильтру; export отличается только форматом по умолчанию
+func runCodesList(args []string, defaultFormat string) {
+	fs := flag.NewFlagSet("codes list", flag.ExitOnError)
+	status := fs.String("status", "", "Статус кода: active, used или revoked")
+	batch := fs.String("batch", "", "Партия (кампания)")
+	format := formatFlag(fs, defaultFormat)
+	fs.Parse(args)
+
+	_, db := openDB()
+	defer db.Close()
+
+	codes, err := db.ListActivationCodes(database.CodeFilter{Status: *status, Batch: *batch})
+	if err != nil {
+		log.Fatalf("Failed to list codes: %v", err)
+	}
+	if codes == nil {
+		codes = []database.ActivationCode{}
+	}
+
+	t := &table{header: []string{"ID", "CODE", "STATUS", "LIMIT", "QUOTA_GB", "DAYS", "USES", "MAX_USES", "EXPIRES", "BATCH", "NOTE", "CREATED"}}
+	for _, c := range codes {
+		t.add(
+			strconv.FormatInt(c.ID, 10), c.Code, c.Status, strconv.Itoa(c.Limit),
+			formatGB(c.TrafficQuota), strconv.Itoa(c.DurationDays),
+			strconv.Itoa(c.Uses), formatMaxUses(c.MaxUses),
+			formatOptionalTime(c.ExpiresAt), c.Batch, c.Note, formatTime(c.CreatedAt),
+		)
+	}
+	printOutput(*format, t, codes)
+}
+
+// runCodesRevoke отзывает активные коды по списку или целой партией
+func runCodesRevoke(args []string) {
+	fs := flag.NewFlagSet("codes revoke", flag.ExitOnError)
+	batch := fs.String("batch", "", "Отозвать все активные коды партии")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 && *batch == "" {
+		log.Fatalf("Specify codes or -batch")
+	}
+
+	_, db := openDB()
+	defer db.Close()
+
+	revoked, err := db.RevokeActivationCodes(fs.Args(), *batch)
+	if err != nil {
+		log.Fatalf("Failed to revoke codes: %v", err)
+	}
+	fmt.Printf("✅ Отозвано кодов: %d\n", revoked)
+}
+
+// formatMaxUses возвращает число активаций кода, 0 - без ограничений
+func formatMaxUses(maxUses int) string {
+	if maxUses == 0 {
+		return "без ограничений"
+	}
+	return fmt.Sprintf("%d", maxUses)
+}
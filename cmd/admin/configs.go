@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+
+	"go-ovpn-bot/internal/database"
+	"go-ovpn-bot/internal/ovpn"
+)
+
+// runConfigs обрабатывает "ovpn-admin configs ..."
+func runConfigs(args []string) {
+	sub, args := subcommand("configs", args)
+	switch sub {
+	case "list":
+		runConfigsList(args)
+	case "revoke":
+		runConfigsRevoke(args)
+	case "reassign":
+		runConfigsReassign(args)
+	default:
+		unknownSubcommand("configs", sub)
+	}
+}
+
+func runConfigsList(args []string) {
+	fs := flag.NewFlagSet("configs list", flag.ExitOnError)
+	userArg := fs.String("user", "", "Telegram ID владельца")
+	server := fs.String("server", "", "Имя сервера")
+	format := formatFlag(fs, formatTable)
+	fs.Parse(args)
+
+	_, db := openDB()
+	defer db.Close()
+
+	var configs []database.Config
+	if *userArg != "" {
+		configs = lookupUser(db, *userArg).Configs
+	} else {
+		var err error
+		if configs, err = db.ListConfigs(); err != nil {
+			log.Fatalf("Failed to list configs: %v", err)
+		}
+	}
+
+	filtered := []database.Config{}
+	for _, c := range configs {
+		if *server == "" || c.Server == *server {
+			filtered = append(filtered, c)
+		}
+	}
+
+	t := &table{header: []string{"ID", "USER_ID", "SERVER", "NAME", "BLOCKED", "EXPIRES", "CREATED"}}
+	for _, c := range filtered {
+		t.add(
+			strconv.FormatInt(c.ID, 10), strconv.FormatInt(c.UserID, 10), c.Server, c.Name,
+			strconv.FormatBool(c.Blocked), formatOptionalTime(c.ExpiresAt), formatTime(c.CreatedAt),
+		)
+	}
+	printOutput(*format, t, filtered)
+}
+
+func runConfigsRevoke(args []string) {
+	requireArgs(args, 1, "configs revoke CONFIG_ID")
+
+	cfg, db := openDB()
+	defer db.Close()
+
+	config := lookupConfig(db, args[0])
+	if err := revokeConfig(openServers(cfg), db, *config); err != nil {
+		log.Fatalf("Failed to revoke config: %v", err)
+	}
+	fmt.Printf("✅ Конфигурация #%d %s отозвана\n", config.ID, config.Name)
+}
+
+func runConfigsReassign(args []string) {
+	requireArgs(args, 2, "configs reassign CONFIG_ID TELEGRAM_ID")
+
+	_, db := openDB()
+	defer db.Close()
+
+	config := lookupConfig(db, args[0])
+	user := lookupUser(db, args[1])
+	if err := db.ReassignConfig(config.ID, user.ID); err != nil {
+		log.Fatalf("Failed to reassign config: %v", err)
+	}
+	fmt.Printf("✅ Конфигурация #%d %s передана пользователю %d\n", config.ID, config.Name, user.TelegramID)
+}
+
+// lookupConfig находит конфигурацию по ID или завершает работу
+func lookupConfig(db *database.DB, arg string) *database.Config {
+	configID, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		log.Fatalf("Invalid config ID %q", arg)
+	}
+
+	config, err := db.GetConfigByID(configID)
+	if err != nil {
+		log.Fatalf("Failed to get config %d: %v", configID, err)
+	}
+	return config
+}
+
+// revokeConfig отзывает сертификат на сервере и удаляет конфигурацию из базы данных
+func revokeConfig(servers *ovpn.Registry, db *database.DB, config database.Config) error {
+	if err := servers.RevokeClient(config.Server, config.Name, config.FilePath); err != nil {
+		return err
+	}
+	return db.DeleteConfig(config.ID)
+}
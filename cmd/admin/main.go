@@ -1,23 +1,71 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"log"
-	"math/rand"
-	"time"
+	"os"
+	"strings"
 
 	"go-ovpn-bot/internal/config"
 	"go-ovpn-bot/internal/database"
+	"go-ovpn-bot/internal/ovpn"
 )
 
+const usage = `Usage: ovpn-admin <command> [subcommand] [flags]
+
+Commands:
+  codes generate [-limit N] [-count N] [-quota-gb N] [-days N] [-uses N] [-expires YYYY-MM-DD] [-batch NAME] [-note TEXT]
+  codes list [-status active|used|revoked] [-batch NAME]
+  codes revoke [-batch NAME] [CODE...]
+  codes export [-status STATUS] [-batch NAME]       (CSV по умолчанию)
+  users list
+  users show TELEGRAM_ID
+  users set-limit TELEGRAM_ID LIMIT
+  users set-quota TELEGRAM_ID QUOTA_GB              (0 - без ограничений)
+  users ban [-unban] [-revoke] TELEGRAM_ID
+  users unlock TELEGRAM_ID                          (снять блокировку ввода кодов)
+  configs list [-user TELEGRAM_ID] [-server NAME]
+  configs revoke CONFIG_ID
+  configs reassign CONFIG_ID TELEGRAM_ID
+  stats
+  audit [-action ACTION] [-user TELEGRAM_ID] [-since DURATION] [-limit N]
+  migrate status|up [-to VERSION]
+
+Команды list, show, export, stats и audit принимают -format table|json|csv.
+Запуск без команды с флагами (ovpn-admin -limit=1 -count=5) равносилен "codes generate".
+`
+
 func main() {
-	var (
-		limit = flag.Int("limit", 1, "Лимит конфигураций для кода")
-		count = flag.Int("count", 1, "Количество кодов для генерации")
-	)
-	flag.Parse()
+	args := os.Args[1:]
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help" {
+		// Совместимость со старым вызовом ovpn-admin -limit=1 -count=5
+		runGenerate(args)
+		return
+	}
 
+	switch args[0] {
+	case "codes":
+		runCodes(args[1:])
+	case "users":
+		runUsers(args[1:])
+	case "configs":
+		runConfigs(args[1:])
+	case "stats":
+		runStats(args[1:])
+	case "audit":
+		runAudit(args[1:])
+	case "migrate":
+		runMigrate(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", args[0], usage)
+		os.Exit(2)
+	}
+}
+
+// openDB загружает конфигурацию и открывает базу данных с применением миграций
+func openDB() (*config.Config, *database.DB) {
 	// Загружаем конфигурацию
 	cfg, err := config.Load()
 	if err != nil {
@@ -29,38 +77,37 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
-	defer db.Close()
 
-	// Генерируем коды
-	rand.Seed(time.Now().UnixNano())
-	
-	fmt.Printf("Генерируем %d кодов с лимитом %d...\n\n", *count, *limit)
-	
-	for i := 0; i < *count; i++ {
-		code := generateActivationCode()
-		
-		// Создаем код в базе данных
-		activationCode, err := db.CreateActivationCode(code, *limit)
-		if err != nil {
-			log.Printf("Failed to create activation code %s: %v", code, err)
-			continue
-		}
-		
-		fmt.Printf("Код %d: %s (ID: %d, Лимит: %d)\n", 
-			i+1, activationCode.Code, activationCode.ID, activationCode.Limit)
+	return cfg, db
+}
+
+// openServers создает провижинеры серверов для отзыва конфигураций
+func openServers(cfg *config.Config) *ovpn.Registry {
+	servers, err := ovpn.NewRegistryFromConfig(cfg.Servers)
+	if err != nil {
+		log.Fatalf("Failed to initialize VPN servers: %v", err)
 	}
-	
-	fmt.Printf("\n✅ Успешно создано %d кодов активации!\n", *count)
+	return servers
+}
+
+// subcommand возвращает подкоманду и ее аргументы или завершает работу с подсказкой
+func subcommand(command string, args []string) (string, []string) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Missing %s subcommand\n\n%s", command, usage)
+		os.Exit(2)
+	}
+	return args[0], args[1:]
+}
+
+func unknownSubcommand(command, sub string) {
+	fmt.Fprintf(os.Stderr, "Unknown %s subcommand %q\n\n%s", command, sub, usage)
+	os.Exit(2)
 }
 
-// generateActivationCode генерирует случайный код активации
-func generateActivationCode() string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	code := make([]byte, 10)
-	
-	for i := range code {
-		code[i] = charset[rand.Intn(len(charset))]
+// requireArgs проверяет количество позиционных аргументов
+func requireArgs(args []string, n int, usageLine string) {
+	if len(args) != n {
+		fmt.Fprintf(os.Stderr, "Usage: ovpn-admin %s\n", usageLine)
+		os.Exit(2)
 	}
-	
-	return string(code)
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"go-ovpn-bot/internal/config"
+	"go-ovpn-bot/internal/database"
+)
+
+// runMigrate обрабатывает "ovpn-admin migrate status|up [-to N]"
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: ovpn-admin migrate status|up [-to VERSION]")
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	to := fs.Int("to", 0, "Применить миграции до указанной версии (0 - до последней)")
+	fs.Parse(args[1:])
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Open не применяет миграции, чтобы status показывал реальное состояние
+	db, err := database.Open(cfg.DatabasePath)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "status":
+		statuses, err := db.MigrationStatus()
+		if err != nil {
+			log.Fatalf("Failed to get migration status: %v", err)
+		}
+
+		pending := 0
+		for _, s := range statuses {
+			applied := "ожидает"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			} else {
+				pending++
+			}
+			fmt.Printf("%4d  %-24s %s\n", s.Version, s.Name, applied)
+		}
+		fmt.Printf("\nНе применено миграций: %d\n", pending)
+	case "up":
+		count, err := db.MigrateTo(*to)
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+
+		version, err := db.SchemaVersion()
+		if err != nil {
+			log.Fatalf("Failed to get schema version: %v", err)
+		}
+		fmt.Printf("✅ Применено миграций: %d, версия схемы: %d\n", count, version)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown migrate command %q\n", args[0])
+		os.Exit(2)
+	}
+}
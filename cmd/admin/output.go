@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Форматы вывода
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+// table - данные для табличного и CSV вывода
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+func (t *table) add(values ...string) {
+	t.rows = append(t.rows, values)
+}
+
+// formatFlag добавляет флаг -format к подкоманде
+func formatFlag(fs *flag.FlagSet, defaultFormat string) *string {
+	return fs.String("format", defaultFormat, "Формат вывода: table, json или csv")
+}
+
+// printOutput выводит t в виде таблицы или CSV, а value - в виде JSON
+func printOutput(format string, t *table, value interface{}) {
+	var err error
+	switch format {
+	case formatTable:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(t.header, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		err = w.Flush()
+	case formatCSV:
+		w := csv.NewWriter(os.Stdout)
+		w.Write(t.header)
+		w.WriteAll(t.rows)
+		err = w.Error()
+	case formatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(value)
+	default:
+		log.Fatalf("Unknown output format %q", format)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write output: %v", err)
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTime(*t)
+}
+
+// formatGB выводит объем в гигабайтах с точностью до сотых
+func formatGB(bytes int64) string {
+	return fmt.Sprintf("%.2f", float64(bytes)/(1<<30))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"go-ovpn-bot/internal/database"
+)
+
+// runStats выводит сводную статистику
+func runStats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	format := formatFlag(fs, formatTable)
+	fs.Parse(args)
+
+	_, db := openDB()
+	defer db.Close()
+
+	now := time.Now()
+	stats, err := db.GetStats(now)
+	if err != nil {
+		log.Fatalf("Failed to get stats: %v", err)
+	}
+
+	t := &table{header: []string{"METRIC", "VALUE"}}
+	t.add("users", strconv.Itoa(stats.Users))
+	t.add("banned_users", strconv.Itoa(stats.BannedUsers))
+	t.add("configs", strconv.Itoa(stats.Configs))
+	t.add("blocked_configs", strconv.Itoa(stats.BlockedConfigs))
+	for _, server := range sortedKeys(stats.ConfigsByServer) {
+		t.add("configs."+server, strconv.Itoa(stats.ConfigsByServer[server]))
+	}
+	for _, status := range sortedKeys(stats.CodesByStatus) {
+		t.add("codes."+status, strconv.Itoa(stats.CodesByStatus[status]))
+	}
+	t.add("redemptions", strconv.Itoa(stats.Redemptions))
+	t.add("traffic_gb."+database.QuotaPeriod(now), formatGB(stats.MonthlyUsage.BytesReceived+stats.MonthlyUsage.BytesSent))
+	printOutput(*format, t, stats)
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"go-ovpn-bot/internal/database"
+)
+
+// runUsers обрабатывает "ovpn-admin users ..."
+func runUsers(args []string) {
+	sub, args := subcommand("users", args)
+	switch sub {
+	case "list":
+		runUsersList(args)
+	case "show":
+		runUsersShow(args)
+	case "set-limit":
+		runUsersSetLimit(args)
+	case "set-quota":
+		runUsersSetQuota(args)
+	case "ban":
+		runUsersBan(args)
+	case "unlock":
+		runUsersUnlock(args)
+	default:
+		unknownSubcommand("users", sub)
+	}
+}
+
+func runUsersList(args []string) {
+	fs := flag.NewFlagSet("users list", flag.ExitOnError)
+	format := formatFlag(fs, formatTable)
+	fs.Parse(args)
+
+	_, db := openDB()
+	defer db.Close()
+
+	users, err := db.ListUsers()
+	if err != nil {
+		log.Fatalf("Failed to list users: %v", err)
+	}
+	if users == nil {
+		users = []database.UserSummary{}
+	}
+
+	t := &table{header: []string{"ID", "TELEGRAM_ID", "USERNAME", "CONFIGS", "LIMIT", "QUOTA_GB", "EXPIRES", "BANNED", "CREATED"}}
+	for _, u := range users {
+		t.add(
+			strconv.FormatInt(u.ID, 10), strconv.FormatInt(u.TelegramID, 10), u.Username,
+			strconv.Itoa(u.ConfigCount), strconv.Itoa(u.Limit), formatGB(u.TrafficQuota),
+			formatOptionalTime(u.ExpiresAt), strconv.FormatBool(u.Banned), formatTime(u.CreatedAt),
+		)
+	}
+	printOutput(*format, t, users)
+}
+
+// userDetails - подробная информация о пользователе для "users show"
+type userDetails struct {
+	*database.User
+	MonthlyUsage database.Usage            `json:"monthly_usage"`
+	Redemptions  []database.UserRedemption `json:"redemptions"`
+}
+
+func runUsersShow(args []string) {
+	fs := flag.NewFlagSet("users show", flag.ExitOnError)
+	format := formatFlag(fs, formatTable)
+	fs.Parse(args)
+	requireArgs(fs.Args(), 1, "users show [-format F] TELEGRAM_ID")
+
+	_, db := openDB()
+	defer db.Close()
+
+	user := lookupUser(db, fs.Arg(0))
+
+	usage, err := db.GetUserMonthlyUsage(user.ID, time.Now())
+	if err != nil {
+		log.Fatalf("Failed to get traffic usage: %v", err)
+	}
+	redemptions, err := db.GetUserRedemptions(user.ID)
+	if err != nil {
+		log.Fatalf("Failed to get redemptions: %v", err)
+	}
+	if redemptions == nil {
+		redemptions = []database.UserRedemption{}
+	}
+	details := userDetails{User: user, MonthlyUsage: usage, Redemptions: redemptions}
+
+	if *format == formatJSON {
+		printOutput(*format, nil, details)
+		return
+	}
+
+	// Табличный и CSV вывод - пары "поле, значение"
+	t := &table{header: []string{"FIELD", "VALUE"}}
+	t.add("id", strconv.FormatInt(user.ID, 10))
+	t.add("telegram_id", strconv.FormatInt(user.TelegramID, 10))
+	t.add("username", user.Username)
+	t.add("limit", strconv.Itoa(user.Limit))
+	t.add("quota_gb", formatGB(user.TrafficQuota))
+	t.add("used_gb", formatGB(usage.BytesReceived+usage.BytesSent))
+	t.add("expires", formatOptionalTime(user.ExpiresAt))
+	t.add("banned", strconv.FormatBool(user.Banned))
+	t.add("created", formatTime(user.CreatedAt))
+	for _, c := range user.Configs {
+		t.add("config", fmt.Sprintf("#%d %s (%s) blocked=%t expires=%s", c.ID, c.Name, c.Server, c.Blocked, formatOptionalTime(c.ExpiresAt)))
+	}
+	for _, r := range redemptions {
+		t.add("redemption", fmt.Sprintf("%s batch=%s at %s", r.Code, r.Batch, formatTime(r.RedeemedAt)))
+	}
+	printOutput(*format, t, details)
+}
+
+func runUsersSetLimit(args []string) {
+	requireArgs(args, 2, "users set-limit TELEGRAM_ID LIMIT")
+	limit, err := strconv.Atoi(args[1])
+	if err != nil || limit < 0 {
+		log.Fatalf("Invalid limit %q", args[1])
+	}
+
+	_, db := openDB()
+	defer db.Close()
+
+	user := lookupUser(db, args[0])
+	if err := db.UpdateUserLimit(user.ID, limit); err != nil {
+		log.Fatalf("Failed to update limit: %v", err)
+	}
+	fmt.Printf("✅ Лимит пользователя %d: %d → %d\n", user.TelegramID, user.Limit, limit)
+}
+
+// runUsersSetQuota заменяет месячную квоту трафика пользователя. Бот применяет ее
+// при следующей проверке трафика: блокирует конфигурации при превышении новой квоты
+// или снимает блокировку, если квота увеличена или снята
+func runUsersSetQuota(args []string) {
+	requireArgs(args, 2, "users set-quota TELEGRAM_ID QUOTA_GB")
+	quotaGB, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || quotaGB < 0 {
+		log.Fatalf("Invalid quota %q", args[1])
+	}
+
+	_, db := openDB()
+	defer db.Close()
+
+	user := lookupUser(db, args[0])
+	if err := db.SetUserTrafficQuota(user.ID, quotaGB<<30); err != nil {
+		log.Fatalf("Failed to update quota: %v", err)
+	}
+	fmt.Printf("✅ Квота пользователя %d: %s → %s ГБ (0 - без ограничений)\n",
+		user.TelegramID, formatGB(user.TrafficQuota), formatGB(quotaGB<<30))
+}
+
+func runUsersBan(args []string) {
+	fs := flag.NewFlagSet("users ban", flag.ExitOnError)
+	unban := fs.Bool("unban", false, "Снять блокировку")
+	revoke := fs.Bool("revoke", false, "Отозвать все конфигурации пользователя")
+	fs.Parse(args)
+	requireArgs(fs.Args(), 1, "users ban [-unban] [-revoke] TELEGRAM_ID")
+
+	cfg, db := openDB()
+	defer db.Close()
+
+	user := lookupUser(db, fs.Arg(0))
+	if err := db.SetUserBanned(user.ID, !*unban); err != nil {
+		log.Fatalf("Failed to update user: %v", err)
+	}
+	if *unban {
+		fmt.Printf("✅ Пользователь %d разблокирован\n", user.TelegramID)
+	} else {
+		fmt.Printf("🚫 Пользователь %d заблокирован\n", user.TelegramID)
+	}
+
+	if !*revoke || len(user.Configs) == 0 {
+		return
+	}
+
+	servers := openServers(cfg)
+	for _, config := range user.Configs {
+		if err := revokeConfig(servers, db, config); err != nil {
+			log.Printf("Failed to revoke config %d: %v", config.ID, err)
+			continue
+		}
+		fmt.Printf("Отозвана конфигурация #%d %s\n", config.ID, config.Name)
+	}
+}
+
+// runUsersUnlock снимает блокировку ввода кодов и прощает накопленные неудачные попытки
+func runUsersUnlock(args []string) {
+	requireArgs(args, 1, "users unlock TELEGRAM_ID")
+
+	_, db := openDB()
+	defer db.Close()
+
+	user := lookupUser(db, args[0])
+	if err := db.ResetCodeLockout(user.ID, time.Now()); err != nil {
+		log.Fatalf("Failed to unlock code entry: %v", err)
+	}
+	fmt.Printf("✅ Ввод кодов для пользователя %d разблокирован\n", user.TelegramID)
+}
+
+// lookupUser находит пользователя по Telegram ID или завершает работу
+func lookupUser(db *database.DB, arg string) *database.User {
+	telegramID, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		log.Fatalf("Invalid Telegram ID %q", arg)
+	}
+
+	user, err := db.GetUserByTelegramID(telegramID)
+	if errors.Is(err, database.ErrUserNotFound) {
+		log.Fatalf("User %d not found", telegramID)
+	}
+	if err != nil {
+		log.Fatalf("Failed to get user: %v", err)
+	}
+	return user
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"go-ovpn-bot/internal/agent"
+	"go-ovpn-bot/internal/config"
+	"go-ovpn-bot/internal/ovpn"
+)
+
+func main() {
+	// Загружаем конфигурацию
+	cfg, err := config.LoadAgent()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Инициализируем локальный провижинер
+	provisioner, err := ovpn.NewProvisioner(cfg.Server)
+	if err != nil {
+		log.Fatalf("Failed to initialize provisioner: %v", err)
+	}
+
+	tlsConfig, err := agent.ServerTLSConfig(cfg.TLSCert, cfg.TLSKey, cfg.ClientCA)
+	if err != nil {
+		log.Fatalf("Failed to configure TLS: %v", err)
+	}
+
+	server := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           agent.NewServer(cfg.Server.Name, provisioner, cfg.Server.ConfigsPath, cfg.Debug),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("Agent %s listening on %s (backend: %s)", cfg.Server.Name, cfg.Listen, cfg.Server.Backend)
+	if err := server.ListenAndServeTLS("", ""); err != nil {
+		log.Fatalf("Agent stopped: %v", err)
+	}
+}
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"go-ovpn-bot/internal/bot"
 	"go-ovpn-bot/internal/config"
@@ -16,20 +22,58 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	if err := run(cfg); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run работает до SIGINT/SIGTERM и закрывает базу данных при любом исходе
+func run(cfg *config.Config) error {
 	// Инициализируем базу данных
 	db, err := database.New(cfg.DatabasePath)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 	defer db.Close()
 
-	// Инициализируем OpenVPN сервис
-	ovpnService := ovpn.New(cfg.ScriptsPath, cfg.ConfigsPath, cfg.ConfigPrefix)
+	// Инициализируем OpenVPN серверы
+	servers, err := ovpn.NewRegistryFromConfig(cfg.Servers)
+	if err != nil {
+		return fmt.Errorf("failed to initialize VPN servers: %w", err)
+	}
+
+	// Создаем бота
+	botInstance, err := bot.New(cfg, db, servers)
+	if err != nil {
+		return err
+	}
+
+	// Останавливаемся по сигналу от systemd или Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- botInstance.Start(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("bot stopped: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+	}
 
-	// Создаем и запускаем бота
-	botInstance := bot.New(cfg, db, ovpnService)
+	// Повторный сигнал прерывает ожидание сразу
+	stop()
+	log.Printf("Shutting down, waiting up to %s for running handlers...", cfg.ShutdownTimeout)
 
-	if err := botInstance.Start(); err != nil {
-		log.Fatalf("Failed to start bot: %v", err)
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(cfg.ShutdownTimeout):
+		return fmt.Errorf("shutdown timed out after %s", cfg.ShutdownTimeout)
 	}
 }
@@ -0,0 +1,263 @@
+// Package agent реализует HTTP API агента, который выпускает конфигурации
+// на удаленном OpenVPN сервере по запросам бота
+package agent
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go-ovpn-bot/internal/ovpn"
+)
+
+var clientNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// Server обслуживает API агента поверх локального провижинера.
+// Аутентификация выполняется на уровне TLS (взаимный TLS)
+type Server struct {
+	name        string
+	provisioner ovpn.ClientProvisioner
+	configsPath string
+	debug       bool
+}
+
+// NewServer создает обработчик API. configsPath ограничивает пути,
+// которые агент согласен читать и удалять
+func NewServer(name string, provisioner ovpn.ClientProvisioner, configsPath string, debug bool) *Server {
+	if abs, err := filepath.Abs(configsPath); err == nil {
+		configsPath = abs
+	}
+	return &Server{
+		name:        name,
+		provisioner: provisioner,
+		configsPath: configsPath,
+		debug:       debug,
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.debug {
+		log.Printf("%s %s from %s", r.Method, r.URL.Path, clientCN(r))
+	}
+
+	switch {
+	case r.URL.Path == "/v1/clients" && r.Method == http.MethodGet:
+		s.handleList(w)
+	case r.URL.Path == "/v1/clients" && r.Method == http.MethodPost:
+		s.handleCreate(w, r)
+	case r.URL.Path == "/v1/clients/revoke" && r.Method == http.MethodPost:
+		s.handleRevoke(w, r)
+	case r.URL.Path == "/v1/clients/disconnect" && r.Method == http.MethodPost:
+		s.handleDisconnect(w, r)
+	case r.URL.Path == "/v1/clients/block" && r.Method == http.MethodPost:
+		s.handleBlock(w, r)
+	case r.URL.Path == "/v1/config" && r.Method == http.MethodGet:
+		s.handleConfig(w, r)
+	case r.URL.Path == "/v1/status" && r.Method == http.MethodGet:
+		s.handleStatus(w)
+	case r.URL.Path == "/v1/connected" && r.Method == http.MethodGet:
+		s.handleConnected(w)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) handleList(w http.ResponseWriter) {
+	clients, err := s.provisioner.ListClients()
+	if err != nil {
+		log.Printf("Failed to list clients: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to list clients")
+		return
+	}
+	if clients == nil {
+		clients = []string{}
+	}
+	writeJSON(w, http.StatusOK, ovpn.AgentListResponse{Clients: clients})
+}
+
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req ovpn.AgentCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	name, configPath, err := s.provisioner.CreateClient(ovpn.ClientOptions{Owner: req.Owner})
+	if err != nil {
+		log.Printf("Failed to create client: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to create client")
+		return
+	}
+
+	log.Printf("Client %s created by %s", name, clientCN(r))
+	writeJSON(w, http.StatusCreated, ovpn.AgentCreateResponse{Name: name, ConfigPath: configPath})
+}
+
+func (s *Server) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	var req ovpn.AgentRevokeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !clientNamePattern.MatchString(req.Name) {
+		writeError(w, http.StatusBadRequest, "invalid client name")
+		return
+	}
+	if req.ConfigPath != "" && !s.allowedPath(req.ConfigPath) {
+		writeError(w, http.StatusForbidden, "config path is outside of configs directory")
+		return
+	}
+
+	if err := s.provisioner.RemoveClient(req.Name, req.ConfigPath); err != nil {
+		log.Printf("Failed to remove client %s: %v", req.Name, err)
+		writeError(w, http.StatusInternalServerError, "failed to remove client")
+		return
+	}
+
+	log.Printf("Client %s revoked by %s", req.Name, clientCN(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDisconnect(w http.ResponseWriter, r *http.Request) {
+	var req ovpn.AgentDisconnectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !clientNamePattern.MatchString(req.Name) {
+		writeError(w, http.StatusBadRequest, "invalid client name")
+		return
+	}
+
+	disconnector, ok := s.provisioner.(ovpn.Disconnector)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "disconnect is not supported")
+		return
+	}
+	if err := disconnector.DisconnectClient(req.Name); err != nil {
+		log.Printf("Failed to disconnect client %s: %v", req.Name, err)
+		writeError(w, http.StatusInternalServerError, "failed to disconnect client")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleBlock(w http.ResponseWriter, r *http.Request) {
+	var req ovpn.AgentBlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !clientNamePattern.MatchString(req.Name) {
+		writeError(w, http.StatusBadRequest, "invalid client name")
+		return
+	}
+
+	blocker, ok := s.provisioner.(ovpn.Blocker)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "blocking is not supported")
+		return
+	}
+
+	var err error
+	if req.Blocked {
+		err = blocker.BlockClient(req.Name)
+	} else {
+		err = blocker.UnblockClient(req.Name)
+	}
+	if err != nil {
+		log.Printf("Failed to update block of client %s: %v", req.Name, err)
+		writeError(w, http.StatusInternalServerError, "failed to update client block")
+		return
+	}
+
+	log.Printf("Client %s blocked=%t by %s", req.Name, req.Blocked, clientCN(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	configPath := r.URL.Query().Get("path")
+	if !s.allowedPath(configPath) {
+		writeError(w, http.StatusForbidden, "config path is outside of configs directory")
+		return
+	}
+
+	data, err := s.provisioner.ReadConfigFile(configPath)
+	if err != nil {
+		log.Printf("Failed to read config %s: %v", configPath, err)
+		writeError(w, http.StatusNotFound, "config not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-openvpn-profile")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter) {
+	clients, err := s.provisioner.ListClients()
+	if err != nil {
+		log.Printf("Failed to list clients: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to list clients")
+		return
+	}
+	writeJSON(w, http.StatusOK, ovpn.AgentStatusResponse{Server: s.name, Clients: len(clients)})
+}
+
+func (s *Server) handleConnected(w http.ResponseWriter) {
+	source, ok := s.provisioner.(ovpn.StatusSource)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "connection status is not supported")
+		return
+	}
+
+	clients, err := source.ConnectedClients()
+	if err != nil {
+		log.Printf("Failed to read connected clients: %v", err)
+		writeError(w, http.StatusInternalServerError, "failed to read connected clients")
+		return
+	}
+	if clients == nil {
+		clients = []ovpn.ConnectedClient{}
+	}
+	writeJSON(w, http.StatusOK, ovpn.AgentConnectedResponse{Clients: clients})
+}
+
+// allowedPath проверяет, что путь указывает на .ovpn внутри директории конфигураций
+func (s *Server) allowedPath(configPath string) bool {
+	if configPath == "" || !strings.HasSuffix(configPath, ".ovpn") {
+		return false
+	}
+	abs, err := filepath.Abs(configPath)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(s.configsPath, abs)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !strings.Contains(rel, string(filepath.Separator))
+}
+
+func clientCN(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return "unknown"
+	}
+	return r.TLS.PeerCertificates[0].Subject.CommonName
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, ovpn.AgentErrorResponse{Error: message})
+}
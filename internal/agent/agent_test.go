@@ -0,0 +1,399 @@
+package agent
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-ovpn-bot/internal/config"
+	"go-ovpn-bot/internal/ovpn"
+)
+
+// testCA - удостоверяющий центр временной PKI
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	dir  string
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key := newTestKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse CA: %v", err)
+	}
+
+	ca := &testCA{cert: cert, key: key, dir: t.TempDir()}
+	writePEM(t, ca.path("ca.crt"), "CERTIFICATE", der)
+	return ca
+}
+
+func (ca *testCA) path(name string) string {
+	return filepath.Join(ca.dir, name)
+}
+
+// issue выпускает сертификат name и возвращает пути к сертификату и ключу
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) (string, string) {
+	t.Helper()
+	key := newTestKey(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	if usage == x509.ExtKeyUsageServerAuth {
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to issue %s: %v", name, err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	certFile, keyFile := ca.path(name+".crt"), ca.path(name+".key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return key
+}
+
+func writePEM(t *testing.T, name, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(name, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+}
+
+// newTestEasyRSA раскладывает CA ca в директорию pki easy-rsa с пустым index.txt
+func newTestEasyRSA(t *testing.T, ca *testCA) string {
+	t.Helper()
+	pkiDir := filepath.Join(t.TempDir(), "easy-rsa", "pki")
+	if err := os.MkdirAll(filepath.Join(pkiDir, "private"), 0700); err != nil {
+		t.Fatalf("failed to create pki: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(ca.key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	writePEM(t, filepath.Join(pkiDir, "ca.crt"), "CERTIFICATE", ca.cert.Raw)
+	writePEM(t, filepath.Join(pkiDir, "private", "ca.key"), "EC PRIVATE KEY", keyDER)
+	if err := os.WriteFile(filepath.Join(pkiDir, "index.txt"), nil, 0600); err != nil {
+		t.Fatalf("failed to write index.txt: %v", err)
+	}
+	return pkiDir
+}
+
+// readTestCRL разбирает CRL в PEM
+func readTestCRL(t *testing.T, path string) *x509.RevocationList {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read CRL: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "X509 CRL" {
+		t.Fatalf("%s is not a PEM CRL", path)
+	}
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse CRL: %v", err)
+	}
+	return crl
+}
+
+// testAgent - агент с взаимным TLS поверх провижинера в памяти
+type testAgent struct {
+	server      *httptest.Server
+	ca          *testCA
+	configsPath string
+}
+
+func newTestAgent(t *testing.T) *testAgent {
+	t.Helper()
+	configsPath := t.TempDir()
+	return newTestAgentWith(t, ovpn.NewMemory(configsPath, "client"), configsPath)
+}
+
+// newTestAgentWith запускает агента поверх provisioner
+func newTestAgentWith(t *testing.T, provisioner ovpn.ClientProvisioner, configsPath string) *testAgent {
+	t.Helper()
+	ca := newTestCA(t, "Test CA")
+	certFile, keyFile := ca.issue(t, "agent", x509.ExtKeyUsageServerAuth)
+	tlsConfig, err := ServerTLSConfig(certFile, keyFile, ca.path("ca.crt"))
+	if err != nil {
+		t.Fatalf("ServerTLSConfig: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(NewServer("test", provisioner, configsPath, false))
+	server.TLS = tlsConfig
+	// Ошибки рукопожатия с чужими сертификатами ожидаемы
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return &testAgent{server: server, ca: ca, configsPath: configsPath}
+}
+
+// client создает клиента агента с сертификатом, выпущенным ca
+func (a *testAgent) client(t *testing.T, ca *testCA, name string) *ovpn.AgentClient {
+	t.Helper()
+	certFile, keyFile := ca.issue(t, name, x509.ExtKeyUsageClientAuth)
+	client, err := ovpn.NewAgentClient(a.server.URL, certFile, keyFile, a.ca.path("ca.crt"))
+	if err != nil {
+		t.Fatalf("NewAgentClient: %v", err)
+	}
+	return client
+}
+
+func TestAgentCreateAndRevoke(t *testing.T) {
+	agent := newTestAgent(t)
+	client := agent.client(t, agent.ca, "bot")
+
+	name, configPath, err := client.CreateClient(ovpn.ClientOptions{Owner: "user 42"})
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	if !strings.HasPrefix(name, "client") || filepath.Dir(configPath) != agent.configsPath {
+		t.Errorf("CreateClient = %q, %q", name, configPath)
+	}
+
+	data, err := client.ReadConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("ReadConfigFile: %v", err)
+	}
+	if !strings.Contains(string(data), "user 42") {
+		t.Errorf("config does not mention owner:\n%s", data)
+	}
+
+	clients, err := client.ListClients()
+	if err != nil || len(clients) != 1 || clients[0] != name {
+		t.Fatalf("ListClients = %v, %v", clients, err)
+	}
+
+	if err := client.RemoveClient(name, configPath); err != nil {
+		t.Fatalf("RemoveClient: %v", err)
+	}
+	if clients, err := client.ListClients(); err != nil || len(clients) != 0 {
+		t.Errorf("ListClients after revoke = %v, %v", clients, err)
+	}
+	if _, err := client.ReadConfigFile(configPath); err == nil {
+		t.Error("config of revoked client is still readable")
+	}
+	if err := client.RemoveClient("../etc", ""); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("RemoveClient with invalid name = %v, want 400", err)
+	}
+}
+
+func TestAgentRejectsPathTraversal(t *testing.T) {
+	agent := newTestAgent(t)
+	client := agent.client(t, agent.ca, "bot")
+
+	name, _, err := client.CreateClient(ovpn.ClientOptions{})
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+
+	paths := []string{
+		filepath.Join(agent.configsPath, "..", "secret.ovpn"),
+		filepath.Join(agent.configsPath, "sub", "client.ovpn"),
+		agent.configsPath + "/../" + filepath.Base(agent.configsPath) + "x/client.ovpn",
+		"/etc/passwd",
+		filepath.Join(agent.configsPath, "client.conf"),
+		"",
+	}
+	for _, configPath := range paths {
+		if _, err := client.ReadConfigFile(configPath); err == nil || !strings.Contains(err.Error(), "403") {
+			t.Errorf("ReadConfigFile(%q) = %v, want 403", configPath, err)
+		}
+	}
+
+	// Отзыв с чужим путем отклоняется до удаления клиента
+	if err := client.RemoveClient(name, "/etc/openvpn/server.ovpn"); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("RemoveClient with foreign path = %v, want 403", err)
+	}
+	if clients, err := client.ListClients(); err != nil || len(clients) != 1 {
+		t.Errorf("ListClients = %v, %v", clients, err)
+	}
+}
+
+func TestAgentRejectsUnknownClientCertificate(t *testing.T) {
+	agent := newTestAgent(t)
+
+	// Сертификат выпущен другим CA, которому агент не доверяет
+	rogue := newTestCA(t, "Rogue CA")
+	client := agent.client(t, rogue, "bot")
+	if _, err := client.ListClients(); err == nil {
+		t.Fatal("agent accepted certificate of unknown CA")
+	}
+
+	// Без клиентского сертификата рукопожатие тоже не проходит
+	resp, err := agent.server.Client().Get(agent.server.URL + "/v1/clients")
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("agent accepted connection without client certificate")
+	}
+}
+
+func TestAgentBlocksClient(t *testing.T) {
+	// Провижинер собирается из окружения так же, как в cmd/agent
+	ccdPath := t.TempDir()
+	configsPath := t.TempDir()
+	t.Setenv("AGENT_TLS_CERT", "agent.crt")
+	t.Setenv("AGENT_TLS_KEY", "agent.key")
+	t.Setenv("AGENT_CLIENT_CA", "ca.crt")
+	t.Setenv("OVPN_BACKEND", config.BackendScript)
+	t.Setenv("CONFIGS_PATH", configsPath)
+	t.Setenv("CCD_PATH", ccdPath)
+	t.Setenv("MANAGEMENT_ADDR", "")
+	cfg, err := config.LoadAgent()
+	if err != nil {
+		t.Fatalf("LoadAgent: %v", err)
+	}
+	provisioner, err := ovpn.NewProvisioner(cfg.Server)
+	if err != nil {
+		t.Fatalf("NewProvisioner: %v", err)
+	}
+
+	agent := newTestAgentWith(t, provisioner, configsPath)
+	client := agent.client(t, agent.ca, "bot")
+	ccdFile := filepath.Join(ccdPath, "client1")
+
+	if err := client.BlockClient("client1"); err != nil {
+		t.Fatalf("BlockClient: %v", err)
+	}
+	if data, err := os.ReadFile(ccdFile); err != nil || strings.TrimSpace(string(data)) != "disable" {
+		t.Fatalf("CCD file after block = %q, %v", data, err)
+	}
+
+	if err := client.UnblockClient("client1"); err != nil {
+		t.Fatalf("UnblockClient: %v", err)
+	}
+	if _, err := os.Stat(ccdFile); !os.IsNotExist(err) {
+		t.Errorf("CCD file after unblock: %v", err)
+	}
+
+	if err := client.BlockClient("../passwd"); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("BlockClient with invalid name = %v, want 400", err)
+	}
+}
+
+func TestAgentNativeProvisioner(t *testing.T) {
+	pkiCA := newTestCA(t, "cn_test")
+	pkiDir := newTestEasyRSA(t, pkiCA)
+	openvpnDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(openvpnDir, "server.conf"), []byte("port 1194\nproto udp\n"), 0644); err != nil {
+		t.Fatalf("failed to write server.conf: %v", err)
+	}
+
+	pki, err := ovpn.NewPKI(pkiDir)
+	if err != nil {
+		t.Fatalf("NewPKI: %v", err)
+	}
+	renderer, err := ovpn.NewRenderer(openvpnDir, "", "")
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+	configsPath := t.TempDir()
+	agent := newTestAgentWith(t, ovpn.NewNative(pki, renderer, openvpnDir, configsPath, "client"), configsPath)
+	client := agent.client(t, agent.ca, "bot")
+
+	name, configPath, err := client.CreateClient(ovpn.ClientOptions{Owner: "user 42"})
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+
+	// Выпуск регистрирует сертификат в index.txt, как easy-rsa build-client-full
+	index, err := os.ReadFile(filepath.Join(pkiDir, "index.txt"))
+	if err != nil {
+		t.Fatalf("failed to read index.txt: %v", err)
+	}
+	fields := strings.Split(strings.TrimSpace(string(index)), "\t")
+	if len(fields) != 6 || fields[0] != "V" || fields[2] != "" || fields[5] != "/CN="+name {
+		t.Fatalf("index.txt after create = %q", index)
+	}
+	serial := fields[3]
+
+	data, err := client.ReadConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("ReadConfigFile: %v", err)
+	}
+	if !strings.Contains(string(data), "# Owner: user 42") || !strings.Contains(string(data), "<cert>") {
+		t.Errorf("config:\n%s", data)
+	}
+	if clients, err := client.ListClients(); err != nil || len(clients) != 1 || clients[0] != name {
+		t.Fatalf("ListClients = %v, %v", clients, err)
+	}
+
+	if err := client.RemoveClient(name, configPath); err != nil {
+		t.Fatalf("RemoveClient: %v", err)
+	}
+
+	index, err = os.ReadFile(filepath.Join(pkiDir, "index.txt"))
+	if err != nil {
+		t.Fatalf("failed to read index.txt: %v", err)
+	}
+	fields = strings.Split(strings.TrimSpace(string(index)), "\t")
+	if len(fields) != 6 || fields[0] != "R" || fields[2] == "" || fields[3] != serial {
+		t.Fatalf("index.txt after revoke = %q", index)
+	}
+
+	// CRL перевыпущен в pki и установлен серверу
+	for _, path := range []string{filepath.Join(pkiDir, "crl.pem"), filepath.Join(openvpnDir, "crl.pem")} {
+		crl := readTestCRL(t, path)
+		if err := crl.CheckSignatureFrom(pkiCA.cert); err != nil {
+			t.Errorf("%s is not signed by CA: %v", path, err)
+		}
+		if len(crl.RevokedCertificateEntries) != 1 || fmt.Sprintf("%X", crl.RevokedCertificateEntries[0].SerialNumber) != strings.TrimLeft(serial, "0") {
+			t.Errorf("%s revoked entries = %+v, want serial %s", path, crl.RevokedCertificateEntries, serial)
+		}
+	}
+
+	if clients, err := client.ListClients(); err != nil || len(clients) != 0 {
+		t.Errorf("ListClients after revoke = %v, %v", clients, err)
+	}
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		t.Errorf("config of revoked client: %v", err)
+	}
+}
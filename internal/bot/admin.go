@@ -0,0 +1,466 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go-ovpn-bot/internal/database"
+	"go-ovpn-bot/internal/i18n"
+)
+
+const (
+	// adminPageSize - число пользователей на странице /users
+	adminPageSize = 10
+	// maxGeneratedCodes ограничивает /gencodes, чтобы ответ поместился в одно сообщение
+	maxGeneratedCodes = 50
+	// broadcastInterval - пауза между сообщениями рассылки (лимит Telegram ~30 сообщений в секунду)
+	broadcastInterval = 50 * time.Millisecond
+)
+
+// adminRoutes регистрирует команды и кнопки администратора. Они доступны только
+// пользователям из ADMIN_IDS
+func (b *Bot) adminRoutes(r *router) {
+	r.command("gencodes", b.handleGenCodesCommand, b.requireAdmin)
+	r.command("users", b.handleUsersCommand, b.requireAdmin)
+	r.command("user", b.handleUserCommand, b.requireAdmin)
+	r.command("setlimit", b.handleSetLimitCommand, b.requireAdmin)
+	r.command("revoke", b.handleAdminRevokeCommand, b.requireAdmin)
+	r.command("broadcast", b.handleBroadcastCommand, b.requireAdmin)
+
+	// Данные кнопок: admin_<действие>_<id>
+	for _, action := range []string{"users", "user", "ban", "unban", "revoke"} {
+		action := action
+		r.callback("admin_"+action, b.idCallback(func(query *tgbotapi.CallbackQuery, admin *database.User, id int64) {
+			b.handleAdminCallback(query, admin, action, id)
+		}), b.requireAdmin)
+	}
+}
+
+// handleUsersCommand показывает первую страницу списка пользователей: /users
+func (b *Bot) handleUsersCommand(req *request) {
+	text, markup, err := b.renderUsersPage(req.tr, 0)
+	if err != nil {
+		log.Printf("Failed to list users: %v", err)
+		b.sendMessage(req.chatID(), req.tr.T("common.error"))
+		return
+	}
+	b.sendWithKeyboard(req.chatID(), text, markup)
+}
+
+// handleUserCommand показывает карточку пользователя: /user <telegram id>
+func (b *Bot) handleUserCommand(req *request) {
+	if len(req.args) != 1 {
+		b.sendMessage(req.chatID(), req.tr.T("admin.user_usage"))
+		return
+	}
+	target, ok := b.findAdminTarget(req.tr, req.chatID(), req.args[0])
+	if !ok {
+		return
+	}
+	text, markup := b.renderUserCard(req.tr, target)
+	b.sendWithKeyboard(req.chatID(), text, markup)
+}
+
+// handleGenCodesCommand создает коды активации: /gencodes [кол-во] [лимит] [дни] [квота ГБ]
+func (b *Bot) handleGenCodesCommand(req *request) {
+	message, user, t := req.message, req.user, req.tr
+	values := []int{1, 1, 0, 0}
+	for i, arg := range req.args {
+		value, err := strconv.Atoi(arg)
+		if i >= len(values) || err != nil || value < 0 {
+			b.sendMessage(message.Chat.ID, t.T("admin.gencodes_usage"))
+			return
+		}
+		values[i] = value
+	}
+
+	count := values[0]
+	if count < 1 || count > maxGeneratedCodes {
+		b.sendMessage(message.Chat.ID, t.T("admin.gencodes_count", maxGeneratedCodes))
+		return
+	}
+
+	opts := database.CodeOptions{
+		Limit:        values[1],
+		DurationDays: values[2],
+		TrafficQuota: int64(values[3]) << 30,
+		MaxUses:      1,
+		// Заметку читают в ovpn-admin codes list, поэтому она пишется на языке по умолчанию,
+		// а не на языке администратора
+		Note: b.bundle.Localizer(b.config.DefaultLanguage).T("admin.gencodes_note", user.TelegramID),
+	}
+
+	var sb strings.Builder
+	sb.WriteString(t.T("admin.gencodes_title", opts.Limit))
+	if opts.DurationDays > 0 {
+		sb.WriteString(t.T("admin.gencodes_days", opts.DurationDays))
+	}
+	if opts.TrafficQuota > 0 {
+		sb.WriteString(t.T("admin.gencodes_quota", formatBytes(t, opts.TrafficQuota)))
+	}
+	sb.WriteString(")\n\n")
+
+	created := 0
+	for i := 0; i < count; i++ {
+		value, err := b.codes.GenerateUnique(b.db.ActivationCodeExists)
+		if err != nil {
+			log.Printf("Failed to generate activation code: %v", err)
+			continue
+		}
+		code, err := b.db.CreateActivationCode(value, opts)
+		if err != nil {
+			log.Printf("Failed to create activation code: %v", err)
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("`%s`\n", code.Code))
+		created++
+	}
+	if created == 0 {
+		b.sendMessage(message.Chat.ID, t.T("admin.gencodes_failed"))
+		return
+	}
+
+	b.sendMessage(message.Chat.ID, sb.String())
+}
+
+// handleSetLimitCommand изменяет лимит конфигураций: /setlimit <telegram id> <лимит>
+func (b *Bot) handleSetLimitCommand(req *request) {
+	message, args, t := req.message, req.args, req.tr
+	if len(args) != 2 {
+		b.sendMessage(message.Chat.ID, t.T("admin.setlimit_usage"))
+		return
+	}
+	limit, err := strconv.Atoi(args[1])
+	if err != nil || limit < 0 {
+		b.sendMessage(message.Chat.ID, t.T("admin.setlimit_invalid"))
+		return
+	}
+
+	target, ok := b.findAdminTarget(t, message.Chat.ID, args[0])
+	if !ok {
+		return
+	}
+	if err := b.db.UpdateUserLimit(target.ID, limit); err != nil {
+		log.Printf("Failed to update limit of user %d: %v", target.TelegramID, err)
+		b.sendMessage(message.Chat.ID, t.T("admin.setlimit_failed"))
+		return
+	}
+
+	b.sendMessage(message.Chat.ID, t.T("admin.setlimit_done", target.TelegramID, target.Limit, limit))
+}
+
+// handleAdminRevokeCommand отзывает любую конфигурацию: /revoke <id конфигурации>
+func (b *Bot) handleAdminRevokeCommand(req *request) {
+	message, args, t := req.message, req.args, req.tr
+	if len(args) != 1 {
+		b.sendMessage(message.Chat.ID, t.T("admin.revoke_usage"))
+		return
+	}
+	configID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		b.sendMessage(message.Chat.ID, t.T("admin.revoke_invalid_id"))
+		return
+	}
+
+	config, err := b.adminRevokeConfig(t, configID)
+	if err != nil {
+		b.sendMessage(message.Chat.ID, "❌ "+err.Error())
+		return
+	}
+
+	b.sendMessage(message.Chat.ID, t.T("admin.revoke_done", config.Name))
+}
+
+// adminRevokeConfig отзывает конфигурацию и уведомляет владельца.
+// Ошибка содержит текст для администратора на его языке
+func (b *Bot) adminRevokeConfig(t *i18n.Localizer, configID int64) (*database.Config, error) {
+	config, err := b.db.GetConfigByID(configID)
+	if err != nil {
+		log.Printf("Failed to get config %d: %v", configID, err)
+		return nil, errors.New(t.T("admin.revoke_not_found"))
+	}
+
+	if err := b.revokeConfig(*config); err != nil {
+		log.Printf("Failed to revoke config %d: %v", config.ID, err)
+		return nil, errors.New(t.T("admin.revoke_failed"))
+	}
+	log.Printf("Config %s of user %d was revoked by admin", config.Name, config.UserID)
+
+	if owner, err := b.db.GetUserByID(config.UserID); err != nil {
+		log.Printf("Failed to get owner of config %d: %v", config.ID, err)
+	} else {
+		b.sendMessage(owner.TelegramID, b.tr(owner).T("admin.revoke_notice", config.Name))
+	}
+
+	return config, nil
+}
+
+// handleBroadcastCommand рассылает сообщение всем незаблокированным пользователям
+func (b *Bot) handleBroadcastCommand(req *request) {
+	message, admin, text, t := req.message, req.user, req.argText, req.tr
+	if text == "" {
+		b.sendMessage(message.Chat.ID, t.T("admin.broadcast_usage"))
+		return
+	}
+
+	users, err := b.db.ListUsers()
+	if err != nil {
+		log.Printf("Failed to list users: %v", err)
+		b.sendMessage(message.Chat.ID, t.T("common.error"))
+		return
+	}
+
+	b.sendMessage(message.Chat.ID, t.T("admin.broadcast_started"))
+
+	// Рассылка может занять минуты, поэтому не блокирует обработку обновлений.
+	// При остановке бота она прерывается, а администратор получает отчет о доставленных
+	b.goBackground(func(ctx context.Context) {
+		var recipients []int64
+		for _, user := range users {
+			if !user.Banned {
+				recipients = append(recipients, user.TelegramID)
+			}
+		}
+
+		delivered := 0
+		for _, telegramID := range recipients {
+			if ctx.Err() != nil {
+				log.Printf("Broadcast by admin %d interrupted after %d of %d users", admin.TelegramID, delivered, len(recipients))
+				b.sendMessage(message.Chat.ID, t.T("admin.broadcast_interrupted", delivered, len(recipients)))
+				return
+			}
+
+			// Текст рассылки отправляется без разметки, чтобы не сломать его парсингом Markdown
+			if _, err := b.api.Send(tgbotapi.NewMessage(telegramID, text)); err != nil {
+				log.Printf("Failed to send broadcast to user %d: %v", telegramID, err)
+			} else {
+				delivered++
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(broadcastInterval):
+			}
+		}
+
+		log.Printf("Broadcast by admin %d delivered to %d of %d users", admin.TelegramID, delivered, len(recipients))
+		b.sendMessage(message.Chat.ID, t.T("admin.broadcast_done", delivered, len(recipients)))
+	})
+}
+
+// handleAdminCallback обрабатывает кнопки панели администратора:
+// users_<страница>, user_<telegram id>, ban_<telegram id>, unban_<telegram id>, revoke_<id конфигурации>
+func (b *Bot) handleAdminCallback(query *tgbotapi.CallbackQuery, admin *database.User, action string, id int64) {
+	t := b.tr(admin)
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+
+	switch action {
+	case "users":
+		text, markup, err := b.renderUsersPage(t, int(id))
+		if err != nil {
+			log.Printf("Failed to list users: %v", err)
+			b.answerCallbackQuery(query.ID, t.T("common.error_short"))
+			return
+		}
+		b.editWithKeyboard(chatID, messageID, text, markup)
+	case "user", "ban", "unban":
+		target, err := b.db.GetUserByTelegramID(id)
+		if err != nil {
+			log.Printf("Failed to get user %d: %v", id, err)
+			b.answerCallbackQuery(query.ID, t.T("admin.user_not_found"))
+			return
+		}
+		if action != "user" {
+			if err := b.db.SetUserBanned(target.ID, action == "ban"); err != nil {
+				log.Printf("Failed to update user %d: %v", target.TelegramID, err)
+				b.answerCallbackQuery(query.ID, t.T("common.error_short"))
+				return
+			}
+			target.Banned = action == "ban"
+		}
+		text, markup := b.renderUserCard(t, target)
+		b.editWithKeyboard(chatID, messageID, text, markup)
+	case "revoke":
+		config, err := b.adminRevokeConfig(t, id)
+		if err != nil {
+			b.answerCallbackQuery(query.ID, "❌ "+err.Error())
+			return
+		}
+		if owner, err := b.db.GetUserByID(config.UserID); err == nil {
+			text, markup := b.renderUserCard(t, owner)
+			b.editWithKeyboard(chatID, messageID, text, markup)
+		}
+		b.answerCallbackQuery(query.ID, t.T("admin.revoke_done_short"))
+	default:
+		b.answerCallbackQuery(query.ID, t.T("admin.unknown_action"))
+	}
+}
+
+// renderUsersPage формирует страницу списка пользователей с кнопками навигации
+func (b *Bot) renderUsersPage(t *i18n.Localizer, page int) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	if page < 0 {
+		page = 0
+	}
+	users, total, err := b.db.ListUsersPage(page*adminPageSize, adminPageSize)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	pages := (total + adminPageSize - 1) / adminPageSize
+	if pages == 0 {
+		pages = 1
+	}
+
+	var sb strings.Builder
+	sb.WriteString(t.T("admin.users_title", total, page+1, pages))
+
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for _, user := range users {
+		status := ""
+		if user.Banned {
+			status = " 🚫"
+		}
+		sb.WriteString(t.T("admin.users_line",
+			user.TelegramID, displayUsername(user.Username), user.ConfigCount, user.Limit, status))
+
+		button := tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("👤 %d %s", user.TelegramID, user.Username),
+			fmt.Sprintf("admin_user_%d", user.TelegramID),
+		)
+		keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{button})
+	}
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(t.T("admin.button_prev"), fmt.Sprintf("admin_users_%d", page-1)))
+	}
+	if page+1 < pages {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(t.T("admin.button_next"), fmt.Sprintf("admin_users_%d", page+1)))
+	}
+	if len(nav) > 0 {
+		keyboard = append(keyboard, nav)
+	}
+
+	return sb.String(), inlineKeyboard(keyboard), nil
+}
+
+// renderUserCard формирует карточку пользователя с кнопками отзыва конфигураций и блокировки
+func (b *Bot) renderUserCard(t *i18n.Localizer, user *database.User) (string, tgbotapi.InlineKeyboardMarkup) {
+	var sb strings.Builder
+	sb.WriteString(t.T("admin.card_title", user.TelegramID, displayUsername(user.Username)))
+	sb.WriteString(t.T("admin.card_configs", len(user.Configs), user.Limit))
+
+	if usage, err := b.db.GetUserMonthlyUsage(user.ID, time.Now()); err != nil {
+		log.Printf("Failed to get monthly usage: %v", err)
+	} else if user.TrafficQuota > 0 {
+		sb.WriteString(t.T("admin.card_traffic_quota", formatBytes(t, usage.Total()), formatBytes(t, user.TrafficQuota)))
+	} else {
+		sb.WriteString(t.T("admin.card_traffic", formatBytes(t, usage.Total())))
+	}
+	if user.ExpiresAt != nil {
+		sb.WriteString(t.T("admin.card_expires", formatDate(*user.ExpiresAt)))
+	}
+	sb.WriteString(t.T("admin.card_registered", formatDate(user.CreatedAt)))
+	if user.Banned {
+		sb.WriteString(t.T("admin.card_banned"))
+	}
+
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	if len(user.Configs) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, config := range user.Configs {
+		status := ""
+		if config.Blocked {
+			status = " 🚫"
+		}
+		sb.WriteString(fmt.Sprintf("• #%d `%s` (%s)%s\n", config.ID, config.Name, config.Server, status))
+
+		button := tgbotapi.NewInlineKeyboardButtonData(
+			t.T("admin.button_revoke", config.Name),
+			fmt.Sprintf("admin_revoke_%d", config.ID),
+		)
+		keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{button})
+	}
+
+	ban := tgbotapi.NewInlineKeyboardButtonData(t.T("admin.button_ban"), fmt.Sprintf("admin_ban_%d", user.TelegramID))
+	if user.Banned {
+		ban = tgbotapi.NewInlineKeyboardButtonData(t.T("admin.button_unban"), fmt.Sprintf("admin_unban_%d", user.TelegramID))
+	}
+	back := tgbotapi.NewInlineKeyboardButtonData(t.T("admin.button_back"), "admin_users_0")
+	keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{ban, back})
+
+	return sb.String(), tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+}
+
+// findAdminTarget находит пользователя по Telegram ID из аргумента команды
+func (b *Bot) findAdminTarget(t *i18n.Localizer, chatID int64, arg string) (*database.User, bool) {
+	telegramID, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		b.sendMessage(chatID, t.T("admin.invalid_telegram_id"))
+		return nil, false
+	}
+
+	user, err := b.db.GetUserByTelegramID(telegramID)
+	if errors.Is(err, database.ErrUserNotFound) {
+		b.sendMessage(chatID, t.T("admin.user_not_found"))
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Failed to get user %d: %v", telegramID, err)
+		b.sendMessage(chatID, t.T("common.error"))
+		return nil, false
+	}
+	return user, true
+}
+
+// notifyAdmins отправляет сообщение key всем администраторам, каждому на его языке
+func (b *Bot) notifyAdmins(key string, args ...interface{}) {
+	for _, adminID := range b.config.AdminIDs {
+		b.sendMessage(adminID, b.trTelegramID(adminID).T(key, args...))
+	}
+}
+
+// inlineKeyboard собирает клавиатуру из рядов кнопок, которые могут быть не заданы.
+// Пустая клавиатура должна сериализоваться в [], а не null
+func inlineKeyboard(rows [][]tgbotapi.InlineKeyboardButton) tgbotapi.InlineKeyboardMarkup {
+	if rows == nil {
+		rows = [][]tgbotapi.InlineKeyboardButton{}
+	}
+	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+func (b *Bot) sendWithKeyboard(chatID int64, text string, markup tgbotapi.InlineKeyboardMarkup) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	if len(markup.InlineKeyboard) > 0 {
+		msg.ReplyMarkup = markup
+	}
+
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("Failed to send message: %v", err)
+	}
+}
+
+func (b *Bot) editWithKeyboard(chatID int64, messageID int, text string, markup tgbotapi.InlineKeyboardMarkup) {
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, markup)
+	edit.ParseMode = "Markdown"
+
+	if _, err := b.api.Send(edit); err != nil {
+		log.Printf("Failed to edit message: %v", err)
+	}
+}
+
+// displayUsername возвращает @username в виде, безопасном для Markdown
+func displayUsername(username string) string {
+	if username == "" {
+		return "—"
+	}
+	return "@" + strings.ReplaceAll(username, "_", "\\_")
+}
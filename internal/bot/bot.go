@@ -1,204 +1,377 @@
 package bot
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"math/rand"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"go-ovpn-bot/internal/config"
 	"go-ovpn-bot/internal/database"
+	"go-ovpn-bot/internal/generator"
+	"go-ovpn-bot/internal/i18n"
 	"go-ovpn-bot/internal/ovpn"
 )
 
 type Bot struct {
-	api         *tgbotapi.BotAPI
-	config      *config.Config
-	db          *database.DB
-	ovpnService *ovpn.Service
-	// Состояние ожидания кода активации для пользователей
-	waitingForCode map[int64]bool
+	api     *tgbotapi.BotAPI
+	config  *config.Config
+	db      *database.DB
+	servers *ovpn.Registry
+	// Генератор и нормализатор кодов активации
+	codes *generator.Generator
+	// Время последнего предупреждения администраторов о переборе кодов
+	alertMu        sync.Mutex
+	lastBurstAlert time.Time
+	// capacityMu не дает параллельным /add превысить Capacity сервера
+	capacityMu sync.Mutex
+	router     *router
+	limiter    *rateLimiter
+	// Каталоги переводов сообщений
+	bundle *i18n.Bundle
+	// ctx отменяется при остановке бота; Start ждет фоновые задачи перед возвратом
+	ctx        context.Context
+	background sync.WaitGroup
 }
 
-func New(cfg *config.Config, db *database.DB, ovpnService *ovpn.Service) *Bot {
+// New создает бота и проверяет токен в Telegram API
+func New(cfg *config.Config, db *database.DB, servers *ovpn.Registry) (*Bot, error) {
 	bot, err := tgbotapi.NewBotAPI(cfg.BotToken)
 	if err != nil {
-		log.Fatalf("Failed to create bot: %v", err)
+		return nil, fmt.Errorf("failed to create bot: %w", err)
 	}
 
 	bot.Debug = cfg.Debug
 
-	return &Bot{
-		api:            bot,
-		config:         cfg,
-		db:             db,
-		ovpnService:    ovpnService,
-		waitingForCode: make(map[int64]bool),
+	codes, err := generator.New(cfg.CodeOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create code generator: %w", err)
+	}
+
+	bundle, err := i18n.Load(cfg.DefaultLanguage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load translations: %w", err)
+	}
+
+	b := &Bot{
+		api:     bot,
+		config:  cfg,
+		db:      db,
+		servers: servers,
+		codes:   codes,
+		limiter: newRateLimiter(cfg.RateLimit, cfg.RateLimitBurst),
+		bundle:  bundle,
+		ctx:     context.Background(),
 	}
+	b.router = b.routes()
+
+	return b, nil
 }
 
-func (b *Bot) Start() error {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+// Start принимает обновления до отмены ctx. После отмены прием прекращается,
+// а Start возвращается, когда воркеры и фоновые задачи завершат начатую работу
+func (b *Bot) Start(ctx context.Context) error {
+	source := b.newUpdateSource()
+	updates, err := source.Start()
+	if err != nil {
+		return fmt.Errorf("failed to start receiving updates: %w", err)
+	}
 
-	updates := b.api.GetUpdatesChan(u)
+	b.ctx = ctx
+	b.goBackground(func(ctx context.Context) {
+		b.runUsageCollector(ctx, b.config.UsageInterval)
+	})
+	b.goBackground(func(ctx context.Context) {
+		b.runExpiryScheduler(ctx, b.config.ExpiryCheckInterval)
+	})
+	for _, server := range b.servers.Servers() {
+		server := server
+		b.goBackground(func(ctx context.Context) {
+			b.runSessionWatcher(ctx, server)
+		})
+	}
 
 	if b.config.Debug {
-		log.Println("Bot started successfully in DEBUG mode")
+		log.Printf("Bot started successfully in DEBUG mode (%s)", b.config.UpdateMode)
 	} else {
-		log.Println("Bot started successfully")
+		log.Printf("Bot started successfully (%s)", b.config.UpdateMode)
 	}
 
-	for update := range updates {
-		if b.config.Debug {
-			log.Printf("Received update: %+v", update)
-		}
-		
-		if update.Message != nil {
-			b.handleMessage(update.Message)
-		} else if update.CallbackQuery != nil {
-			b.handleCallbackQuery(update.CallbackQuery)
-		}
-	}
+	// Обновления разных пользователей обрабатываются параллельно, одного - по порядку
+	pool := newWorkerPool(b.config.Workers, b.config.UpdateQueueSize, b.handleUpdate)
+	b.receive(ctx, updates, pool)
+
+	source.Stop()
+	log.Println("Stopped receiving updates, waiting for running handlers...")
+
+	// Обработчики могли запустить фоновые задачи, поэтому ждем их после воркеров
+	pool.Stop()
+	b.background.Wait()
+	log.Println("Bot stopped")
 
 	return nil
 }
 
-func (b *Bot) handleMessage(message *tgbotapi.Message) {
+// goBackground запускает фоновую задачу, завершения которой Start ждет перед возвратом.
+// Задача должна завершиться после отмены ctx
+func (b *Bot) goBackground(task func(ctx context.Context)) {
+	b.background.Add(1)
+	go func() {
+		defer b.background.Done()
+		task(b.ctx)
+	}()
+}
+
+// receive передает обновления воркерам, пока не отменен ctx или не закрыт канал
+func (b *Bot) receive(ctx context.Context, updates tgbotapi.UpdatesChannel, pool *workerPool) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			pool.Submit(update)
+		}
+	}
+}
+
+// handleUpdate обрабатывает одно обновление в воркере
+func (b *Bot) handleUpdate(update tgbotapi.Update) {
 	if b.config.Debug {
-		log.Printf("Handling message from user %d (%s): %s", 
-			message.From.ID, message.From.UserName, message.Text)
+		log.Printf("Received update: %+v", update)
 	}
-	
-	// Получаем или создаем пользователя
-	user, err := b.db.GetOrCreateUser(
-		int64(message.From.ID),
-		message.From.UserName,
-	)
-	if err != nil {
-		log.Printf("Failed to get/create user: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Произошла ошибка при обработке запроса")
-		return
+
+	if update.Message != nil {
+		b.handleMessage(update.Message)
+	} else if update.CallbackQuery != nil {
+		b.handleCallbackQuery(update.CallbackQuery)
 	}
+}
 
-	// Проверяем, ожидает ли пользователь ввод кода активации
-	if b.waitingForCode[user.ID] {
-		b.handleActivationCode(message, user)
+func (b *Bot) handleMessage(message *tgbotapi.Message) {
+	if message.From == nil {
 		return
 	}
+	req := newMessageRequest(message)
+	req.tr = b.trFrom(message.From)
+	b.router.serve(req)
+}
 
-	// Обрабатываем команды
-	switch {
-	case strings.HasPrefix(message.Text, "/start"):
-		b.handleStartCommand(message, user)
-	case strings.HasPrefix(message.Text, "/add"):
-		b.handleAddCommand(message, user)
-	case strings.HasPrefix(message.Text, "/remove"):
-		b.handleRemoveCommand(message, user)
-	case strings.HasPrefix(message.Text, "/code"):
-		b.handleCodeCommand(message, user)
-	default:
-		b.sendMessage(message.Chat.ID, "❓ Неизвестная команда. Используйте /start для просмотра доступных команд.")
+func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
+	req := newCallbackRequest(query)
+	req.tr = b.trFrom(query.From)
+	b.router.serve(req)
+
+	// Отвечаем на callback query
+	b.answerCallbackQuery(query.ID, "")
+}
+
+// routes регистрирует команды и кнопки. Каждый запрос проходит общие middleware:
+// защиту от паники, журнал, ограничение частоты и загрузку пользователя
+func (b *Bot) routes() *router {
+	r := newRouter(b.api.Self.UserName, b.handleUnknownCommand)
+	r.use(b.recoverPanic, b.logRequests, b.rateLimit, b.loadUser, b.handleConversation)
+
+	r.command("start", messageHandler(b.handleStartCommand))
+	r.command("add", messageHandler(b.handleAddCommand))
+	r.command("remove", messageHandler(b.handleRemoveCommand))
+	r.command("code", messageHandler(b.handleCodeCommand))
+	r.command("status", messageHandler(b.handleStatusCommand))
+	r.command("usage", messageHandler(b.handleUsageCommand))
+	r.command("language", messageHandler(b.handleLanguageCommand))
+	r.command("list", messageHandler(b.handleListCommand))
+	r.command("configs", messageHandler(b.handleListCommand))
+
+	r.callback("add", stringCallback(b.handleAddServerCallback))
+	r.callback("remove", b.idCallback(b.handleRemoveConfigCallback))
+	r.callback("cancel_remove", func(req *request) { b.handleCancelRemoveCallback(req.query, req.user) })
+	r.callback("usage", b.idCallback(b.handleUsageCallback))
+	r.callback("language", stringCallback(b.handleLanguageCallback))
+	b.configRoutes(r)
+
+	b.adminRoutes(r)
+	return r
+}
+
+// handleUnknownCommand отвечает на неизвестные команды и сообщения вне диалога
+func (b *Bot) handleUnknownCommand(req *request) {
+	b.sendMessage(req.chatID(), req.tr.T("common.unknown_command"))
+}
+
+func (b *Bot) handleStartCommand(message *tgbotapi.Message, user *database.User) {
+	t := b.tr(user)
+	text := t.T("start.text", len(user.Configs), user.Limit)
+
+	if user.TrafficQuota > 0 {
+		usage, err := b.db.GetUserMonthlyUsage(user.ID, time.Now())
+		if err != nil {
+			log.Printf("Failed to get monthly usage: %v", err)
+		} else {
+			text += t.T("start.traffic", formatBytes(t, usage.Total()), formatBytes(t, user.TrafficQuota))
+		}
+	}
+	if user.ExpiresAt != nil {
+		text += t.T("start.expires", formatDate(*user.ExpiresAt))
+	}
+	if b.config.IsAdmin(user.TelegramID) {
+		text += t.T("admin.help")
 	}
+
+	b.sendMessage(message.Chat.ID, text)
 }
 
-func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
-	// Получаем пользователя
-	user, err := b.db.GetOrCreateUser(
-		int64(query.From.ID),
-		query.From.UserName,
-	)
-	if err != nil {
-		log.Printf("Failed to get/create user: %v", err)
-		b.answerCallbackQuery(query.ID, "❌ Произошла ошибка")
+func (b *Bot) handleAddCommand(message *tgbotapi.Message, user *database.User) {
+	if !b.checkLimit(message.Chat.ID, user) {
 		return
 	}
 
-	// Обрабатываем callback данные
-	data := query.Data
-	if strings.HasPrefix(data, "remove_") {
-		configIDStr := strings.TrimPrefix(data, "remove_")
-		configID, err := strconv.ParseInt(configIDStr, 10, 64)
-		if err != nil {
-			b.answerCallbackQuery(query.ID, "❌ Неверный ID конфигурации")
-			return
+	servers := b.servers.Servers()
+	if len(servers) == 1 {
+		b.createConfig(message.Chat.ID, message.From, user, servers[0])
+		return
+	}
+
+	// Предлагаем выбрать локацию
+	t := b.tr(user)
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for _, server := range servers {
+		label := server.Name
+		if server.Region != "" {
+			label = fmt.Sprintf("%s (%s)", server.Region, server.Name)
 		}
 
-		b.handleRemoveConfigCallback(query, user, configID)
-	} else if data == "cancel_remove" {
-		b.handleCancelRemoveCallback(query)
+		if server.Capacity > 0 {
+			used, err := b.db.CountConfigsByServer(server.Name, server == b.servers.Default())
+			if err != nil {
+				log.Printf("Failed to count configs on server %s: %v", server.Name, err)
+				continue
+			}
+			if used >= server.Capacity {
+				label += t.T("add.server_full_label")
+			}
+		}
+
+		button := tgbotapi.NewInlineKeyboardButtonData("🌍 "+label, "add_"+server.Name)
+		keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{button})
 	}
 
-	// Отвечаем на callback query
-	b.answerCallbackQuery(query.ID, "")
+	msg := tgbotapi.NewMessage(message.Chat.ID, t.T("add.choose_server"))
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("Failed to send server menu: %v", err)
+	}
 }
 
-func (b *Bot) handleStartCommand(message *tgbotapi.Message, user *database.User) {
-	text := `🔐 *Добро пожаловать в OpenVPN Bot!*
+// handleAddServerCallback создает конфигурацию на выбранном сервере
+func (b *Bot) handleAddServerCallback(query *tgbotapi.CallbackQuery, user *database.User, serverName string) {
+	server, ok := b.servers.Get(serverName)
+	if !ok || serverName == "" {
+		b.answerCallbackQuery(query.ID, b.tr(user).T("add.server_not_found"))
+		return
+	}
 
-Этот бот поможет вам управлять VPN конфигурациями.
+	if !b.checkLimit(query.Message.Chat.ID, user) {
+		return
+	}
 
-*Доступные команды:*
-• /add - Создать новую VPN конфигурацию
-• /remove - Удалить существующую конфигурацию
-• /code - Активировать код для увеличения лимита
+	b.createConfig(query.Message.Chat.ID, query.From, user, server)
+}
 
-*Ваши конфигурации:* ` + fmt.Sprintf("%d", len(user.Configs)) + `
-*Ваш лимит:* ` + fmt.Sprintf("%d", user.Limit)
+// checkLimit проверяет лимит конфигураций и квоту трафика и сообщает пользователю, если они исчерпаны
+func (b *Bot) checkLimit(chatID int64, user *database.User) bool {
+	t := b.tr(user)
+	if user.Limit <= len(user.Configs) {
+		b.sendMessage(chatID, t.T("limit.exhausted", user.Limit, len(user.Configs)))
+		return false
+	}
 
-	b.sendMessage(message.Chat.ID, text)
+	if user.ExpiresAt != nil && !user.ExpiresAt.After(time.Now()) {
+		b.sendMessage(chatID, t.T("limit.access_expired"))
+		return false
+	}
+
+	exceeded, err := b.quotaExceeded(user)
+	if err != nil {
+		log.Printf("Failed to check traffic quota: %v", err)
+		b.sendMessage(chatID, t.T("common.error"))
+		return false
+	}
+	if exceeded {
+		b.sendMessage(chatID, t.T("limit.quota_exceeded"))
+		return false
+	}
+
+	return true
 }
 
-func (b *Bot) handleAddCommand(message *tgbotapi.Message, user *database.User) {
-	// Проверяем лимит пользователя
-	if user.Limit <= len(user.Configs) {
-		b.sendMessage(message.Chat.ID, 
-			"❌ У вас исчерпан лимит конфигураций!\n\n"+
-			"*Текущий лимит:* "+fmt.Sprintf("%d", user.Limit)+"\n"+
-			"*Использовано:* "+fmt.Sprintf("%d", len(user.Configs))+"\n\n"+
-			"Используйте команду /code для активации кода и увеличения лимита.")
-		return
+// createConfig выпускает конфигурацию на сервере и отправляет ее пользователю
+func (b *Bot) createConfig(chatID int64, from *tgbotapi.User, user *database.User, server *ovpn.Server) {
+	t := b.tr(user)
+
+	// Проверяем, есть ли свободные места на сервере. Проверка и выпуск выполняются
+	// под блокировкой, иначе параллельные запросы займут больше мест, чем есть
+	if server.Capacity > 0 {
+		b.capacityMu.Lock()
+		defer b.capacityMu.Unlock()
+
+		used, err := b.db.CountConfigsByServer(server.Name, server == b.servers.Default())
+		if err != nil {
+			log.Printf("Failed to count configs on server %s: %v", server.Name, err)
+			b.sendMessage(chatID, t.T("common.error"))
+			return
+		}
+		if used >= server.Capacity {
+			b.sendMessage(chatID, t.T("add.server_full"))
+			return
+		}
 	}
 
 	// Создаем клиента
-	b.sendMessage(message.Chat.ID, "⏳ Создаю новую VPN конфигурацию...")
+	b.sendMessage(chatID, t.T("add.creating"))
 
-	clientName, configPath, err := b.ovpnService.CreateClient()
+	clientName, configPath, err := server.Provisioner.CreateClient(ovpn.ClientOptions{
+		Owner: fmt.Sprintf("@%s (%d)", from.UserName, from.ID),
+	})
 	if err != nil {
-		log.Printf("Failed to create client: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Ошибка при создании конфигурации. Попробуйте позже.")
+		log.Printf("Failed to create client on server %s: %v", server.Name, err)
+		b.sendMessage(chatID, t.T("add.create_failed"))
 		return
 	}
 
 	// Сохраняем информацию о конфигурации в базу данных
-	config, err := b.db.CreateConfig(user.ID, clientName, configPath)
+	config, err := b.db.CreateConfig(user.ID, server.Name, clientName, configPath)
 	if err != nil {
 		log.Printf("Failed to save config to database: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Ошибка при сохранении конфигурации в базу данных.")
+		b.sendMessage(chatID, t.T("add.save_failed"))
 		return
 	}
 
 	// Читаем содержимое конфигурационного файла
-	configData, err := b.ovpnService.ReadConfigFile(configPath)
+	configData, err := server.Provisioner.ReadConfigFile(configPath)
 	if err != nil {
 		log.Printf("Failed to read config file: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Ошибка при чтении конфигурационного файла.")
+		b.sendMessage(chatID, t.T("add.read_failed"))
 		return
 	}
 
 	// Отправляем конфигурационный файл
-	file := tgbotapi.NewDocument(message.Chat.ID, tgbotapi.FileBytes{
+	file := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
 		Name:  clientName + ".ovpn",
 		Bytes: configData,
 	})
-	file.Caption = fmt.Sprintf("✅ Конфигурация *%s* успешно создана!", clientName)
+	file.Caption = t.T("add.created", clientName)
 
 	if _, err := b.api.Send(file); err != nil {
 		log.Printf("Failed to send config file: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Ошибка при отправке конфигурационного файла.")
+		b.sendMessage(chatID, t.T("add.send_failed"))
 		return
 	}
 
@@ -207,8 +380,9 @@ func (b *Bot) handleAddCommand(message *tgbotapi.Message, user *database.User) {
 }
 
 func (b *Bot) handleRemoveCommand(message *tgbotapi.Message, user *database.User) {
+	t := b.tr(user)
 	if len(user.Configs) == 0 {
-		b.sendMessage(message.Chat.ID, "📭 У вас нет созданных конфигураций.")
+		b.sendMessage(message.Chat.ID, t.T("common.no_configs"))
 		return
 	}
 
@@ -223,13 +397,12 @@ func (b *Bot) handleRemoveCommand(message *tgbotapi.Message, user *database.User
 	}
 
 	// Добавляем кнопку отмены
-	cancelButton := tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "cancel_remove")
+	cancelButton := tgbotapi.NewInlineKeyboardButtonData(t.T("remove.cancel_button"), "cancel_remove")
 	keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{cancelButton})
 
 	inlineKeyboard := tgbotapi.NewInlineKeyboardMarkup(keyboard...)
 
-	text := "🗑️ *Выберите конфигурацию для удаления:*"
-	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg := tgbotapi.NewMessage(message.Chat.ID, t.T("remove.choose"))
 	msg.ParseMode = "Markdown"
 	msg.ReplyMarkup = inlineKeyboard
 
@@ -239,55 +412,49 @@ func (b *Bot) handleRemoveCommand(message *tgbotapi.Message, user *database.User
 }
 
 func (b *Bot) handleRemoveConfigCallback(query *tgbotapi.CallbackQuery, user *database.User, configID int64) {
+	t := b.tr(user)
+
 	// Получаем информацию о конфигурации
 	config, err := b.db.GetConfigByID(configID)
 	if err != nil {
 		log.Printf("Failed to get config: %v", err)
-		b.answerCallbackQuery(query.ID, "❌ Конфигурация не найдена")
+		b.answerCallbackQuery(query.ID, t.T("common.config_not_found"))
 		return
 	}
 
 	// Проверяем что конфигурация принадлежит пользователю
 	if config.UserID != user.ID {
-		b.answerCallbackQuery(query.ID, "❌ У вас нет прав на удаление этой конфигурации")
+		b.answerCallbackQuery(query.ID, t.T("remove.forbidden"))
 		return
 	}
 
-	// Удаляем клиента через OpenVPN скрипт
-	if err := b.ovpnService.RemoveClient(config.Name, config.FilePath); err != nil {
-		log.Printf("Failed to remove client: %v", err)
-		b.answerCallbackQuery(query.ID, "❌ Ошибка при удалении конфигурации")
-		return
-	}
-
-	// Удаляем конфигурацию из базы данных
-	if err := b.db.DeleteConfig(configID); err != nil {
-		log.Printf("Failed to delete config from database: %v", err)
-		b.answerCallbackQuery(query.ID, "❌ Ошибка при удалении из базы данных")
+	// Удаляем клиента на сервере, где он был создан, и запись в базе данных
+	if err := b.revokeConfig(*config); err != nil {
+		log.Printf("Failed to remove config %d: %v", config.ID, err)
+		b.answerCallbackQuery(query.ID, t.T("remove.failed"))
 		return
 	}
 
 	// Отправляем подтверждение
-	text := fmt.Sprintf("✅ Конфигурация *%s* успешно удалена!", config.Name)
-	msg := tgbotapi.NewMessage(query.Message.Chat.ID, text)
+	msg := tgbotapi.NewMessage(query.Message.Chat.ID, t.T("remove.done", config.Name))
 	msg.ParseMode = "Markdown"
 
 	if _, err := b.api.Send(msg); err != nil {
 		log.Printf("Failed to send confirmation: %v", err)
 	}
 
-	b.answerCallbackQuery(query.ID, "✅ Конфигурация удалена")
+	b.answerCallbackQuery(query.ID, t.T("remove.done_short"))
 }
 
-func (b *Bot) handleCancelRemoveCallback(query *tgbotapi.CallbackQuery) {
-	text := "❌ Удаление отменено."
-	msg := tgbotapi.NewMessage(query.Message.Chat.ID, text)
+func (b *Bot) handleCancelRemoveCallback(query *tgbotapi.CallbackQuery, user *database.User) {
+	t := b.tr(user)
+	msg := tgbotapi.NewMessage(query.Message.Chat.ID, t.T("remove.cancelled"))
 
 	if _, err := b.api.Send(msg); err != nil {
 		log.Printf("Failed to send cancel message: %v", err)
 	}
 
-	b.answerCallbackQuery(query.ID, "❌ Отменено")
+	b.answerCallbackQuery(query.ID, t.T("remove.cancelled_short"))
 }
 
 func (b *Bot) sendMessage(chatID int64, text string) {
@@ -306,103 +473,225 @@ func (b *Bot) answerCallbackQuery(callbackQueryID, text string) {
 	}
 }
 
+// handleStatusCommand показывает, какие конфигурации пользователя сейчас онлайн
+func (b *Bot) handleStatusCommand(message *tgbotapi.Message, user *database.User) {
+	t := b.tr(user)
+	if len(user.Configs) == 0 {
+		b.sendMessage(message.Chat.ID, t.T("common.no_configs"))
+		return
+	}
+
+	connected, failed := b.connectedClients(user.Configs)
+
+	var sb strings.Builder
+	sb.WriteString(t.T("status.title"))
+	for _, config := range user.Configs {
+		server, _ := b.servers.Get(config.Server)
+		if server == nil || failed[server.Name] {
+			sb.WriteString(t.T("status.unavailable", config.Name))
+			continue
+		}
+
+		if config.Blocked {
+			sb.WriteString(t.T("status.blocked", config.Name))
+			continue
+		}
+
+		client, online := connected[server.Name][config.Name]
+		if !online {
+			sb.WriteString(t.T("status.offline", config.Name))
+			continue
+		}
+
+		sb.WriteString(t.T("status.online", config.Name, client.ConnectedSince.Format("02.01.2006 15:04")))
+		sb.WriteString(t.T("status.address", client.VirtualAddress, client.RealAddress))
+		sb.WriteString(fmt.Sprintf("    ⬇️ %s  ⬆️ %s\n", formatBytes(t, client.BytesSent), formatBytes(t, client.BytesReceived)))
+	}
+
+	b.sendMessage(message.Chat.ID, sb.String())
+}
+
+// connectedClients читает подключенных клиентов только с тех серверов, где есть
+// конфигурации из configs. Возвращает клиентов по серверу и CN и серверы, статус
+// которых прочитать не удалось
+func (b *Bot) connectedClients(configs []database.Config) (map[string]map[string]ovpn.ConnectedClient, map[string]bool) {
+	connected := make(map[string]map[string]ovpn.ConnectedClient)
+	failed := make(map[string]bool)
+	for _, config := range configs {
+		server, ok := b.servers.Get(config.Server)
+		if !ok {
+			failed[config.Server] = true
+			continue
+		}
+		if _, done := connected[server.Name]; done || failed[server.Name] {
+			continue
+		}
+
+		source, ok := server.Provisioner.(ovpn.StatusSource)
+		if !ok {
+			failed[server.Name] = true
+			continue
+		}
+		clients, err := source.ConnectedClients()
+		if err != nil {
+			log.Printf("Failed to read status of server %s: %v", server.Name, err)
+			failed[server.Name] = true
+			continue
+		}
+
+		byName := make(map[string]ovpn.ConnectedClient)
+		for _, client := range clients {
+			byName[client.CommonName] = client
+		}
+		connected[server.Name] = byName
+	}
+	return connected, failed
+}
+
+// formatBytes форматирует количество байт в человекочитаемый вид
+func formatBytes(t *i18n.Localizer, n int64) string {
+	const unit = 1024
+	// Единицы перечислены через пробел: байты, КБ, МБ, ГБ, ТБ
+	units := strings.Fields(t.T("units.bytes"))
+	if n < unit {
+		return fmt.Sprintf("%d %s", n, units[0])
+	}
+	units = units[1:]
+	value := float64(n) / unit
+	i := 0
+	for value >= unit && i < len(units)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
+
 // handleCodeCommand обрабатывает команду /code
 func (b *Bot) handleCodeCommand(message *tgbotapi.Message, user *database.User) {
-	b.waitingForCode[user.ID] = true
-	b.sendMessage(message.Chat.ID, 
-		"🔑 *Активация кода*\n\n"+
-		"Введите код активации для увеличения лимита конфигураций.\n\n"+
-		"Код должен состоять из 10 символов (латинские буквы и цифры).")
+	if !b.checkCodeAttempts(message.Chat.ID, user, time.Now()) {
+		return
+	}
+
+	t := b.tr(user)
+	if err := b.startConversation(message.Chat.ID, user, stateAwaitingCode, nil); err != nil {
+		log.Printf("Failed to start conversation: %v", err)
+		b.sendMessage(message.Chat.ID, t.T("common.error"))
+		return
+	}
+	b.sendMessage(message.Chat.ID, t.T("code.prompt"))
 }
 
 // handleActivationCode обрабатывает введенный код активации
 func (b *Bot) handleActivationCode(message *tgbotapi.Message, user *database.User) {
 	code := strings.TrimSpace(message.Text)
+	t := b.tr(user)
 	
-	// Сбрасываем состояние ожидания
-	delete(b.waitingForCode, user.ID)
-	
-	// Проверяем формат кода
-	if len(code) != 10 {
-		b.sendMessage(message.Chat.ID, 
-			"❌ Неверный формат кода!\n\n"+
-			"Код должен содержать ровно 10 символов (латинские буквы и цифры).")
+	// Завершаем диалог ввода кода
+	b.endConversation(message.Chat.ID, user)
+
+	// Блокировка могла начаться, пока пользователь вводил код
+	now := time.Now()
+	if !b.checkCodeAttempts(message.Chat.ID, user, now) {
 		return
 	}
 	
-	// Проверяем что код содержит только латинские буквы и цифры
-	if !isValidCode(code) {
-		b.sendMessage(message.Chat.ID, 
-			"❌ Неверный формат кода!\n\n"+
-			"Код должен содержать только латинские буквы (a-z, A-Z) и цифры (0-9).")
+	// Проверяем формат кода
+	if !b.validCode(code) {
+		b.sendMessage(message.Chat.ID, t.T("code.invalid_format"))
+		b.recordCodeFailure(message.Chat.ID, user, code, "invalid format", now)
 		return
 	}
 	
-	// Получаем код из базы данных
-	activationCode, err := b.db.GetActivationCodeByCode(code)
-	if err != nil {
-		b.sendMessage(message.Chat.ID, 
-			"❌ Код не найден или неверный!\n\n"+
-			"Проверьте правильность введенного кода.")
+	// Активируем код: проверка статуса и начисление выполняются одной транзакцией
+	redemption, err := b.redeemCode(user.ID, code)
+	switch {
+	case errors.Is(err, database.ErrCodeNotFound):
+		b.sendMessage(message.Chat.ID, t.T("code.not_found"))
+		b.recordCodeFailure(message.Chat.ID, user, code, "not found", now)
 		return
-	}
-	
-	// Проверяем статус кода
-	if activationCode.Status != "active" {
-		b.sendMessage(message.Chat.ID, 
-			"❌ Код уже использован!\n\n"+
-			"Этот код активации уже был использован ранее.")
+	case errors.Is(err, database.ErrCodeUsed):
+		b.audit(user.ID, database.AuditCodeRejected, err.Error()+": "+code, now)
+		b.sendMessage(message.Chat.ID, t.T("code.used"))
 		return
-	}
-	
-	// Обновляем лимит пользователя
-	newLimit := user.Limit + activationCode.Limit
-	if err := b.db.UpdateUserLimit(user.ID, newLimit); err != nil {
-		log.Printf("Failed to update user limit: %v", err)
-		b.sendMessage(message.Chat.ID, "❌ Ошибка при обновлении лимита. Попробуйте позже.")
+	case errors.Is(err, database.ErrCodeRedeemed):
+		b.audit(user.ID, database.AuditCodeRejected, err.Error()+": "+code, now)
+		b.sendMessage(message.Chat.ID, t.T("code.already_redeemed"))
+		return
+	case errors.Is(err, database.ErrCodeRevoked):
+		b.audit(user.ID, database.AuditCodeRejected, err.Error()+": "+code, now)
+		b.sendMessage(message.Chat.ID, t.T("code.revoked"))
+		return
+	case errors.Is(err, database.ErrCodeExpired):
+		b.audit(user.ID, database.AuditCodeRejected, err.Error()+": "+code, now)
+		b.sendMessage(message.Chat.ID, t.T("code.expired"))
+		return
+	case err != nil:
+		log.Printf("Failed to redeem activation code: %v", err)
+		b.sendMessage(message.Chat.ID, t.T("code.failed"))
 		return
 	}
-	
-	// Помечаем код как использованный
-	if err := b.db.UseActivationCode(activationCode.ID); err != nil {
-		log.Printf("Failed to mark code as used: %v", err)
-		// Не прерываем выполнение, так как лимит уже обновлен
+
+	b.audit(user.ID, database.AuditCodeRedeemed, redemption.Code.Code, now)
+	if err := b.db.ResetCodeLockout(user.ID, now); err != nil {
+		log.Printf("Failed to reset code lockout: %v", err)
 	}
-	
-	// Обновляем лимит в объекте пользователя
-	user.Limit = newLimit
-	
-	b.sendMessage(message.Chat.ID, 
-		fmt.Sprintf("✅ *Код успешно активирован!*\n\n"+
-		"*Добавлено к лимиту:* %d\n"+
-		"*Новый лимит:* %d\n"+
-		"*Использовано:* %d\n\n"+
-		"Теперь вы можете создавать VPN конфигурации!",
-		activationCode.Limit, newLimit, len(user.Configs)))
+
+	activationCode := redemption.Code
+	user.Limit = redemption.Limit
+	user.TrafficQuota = redemption.TrafficQuota
+	user.ExpiresAt = redemption.ExpiresAt
+
+	text := t.T("code.redeemed", activationCode.Limit, redemption.Limit, len(user.Configs))
+
+	// Код с квотой трафика увеличивает месячную квоту и снимает блокировку
+	if activationCode.TrafficQuota > 0 {
+		text += t.T("code.redeemed_quota", formatBytes(t, redemption.TrafficQuota))
+
+		if state, err := b.db.GetQuotaState(user.ID); err != nil {
+			log.Printf("Failed to get quota state: %v", err)
+		} else {
+			b.enforceQuota(*state, time.Now())
+		}
+	}
+
+	// Код с ограниченным сроком продлевает доступ и все конфигурации пользователя
+	if activationCode.DurationDays > 0 && redemption.ExpiresAt != nil {
+		text += t.T("code.redeemed_expires", formatDate(*redemption.ExpiresAt))
+	}
+
+	b.sendMessage(message.Chat.ID, text+t.T("code.redeemed_footer"))
 }
 
-// isValidCode проверяет что код содержит только латинские буквы и цифры
-func isValidCode(code string) bool {
+// redeemCode активирует код, введенный пользователем. Сначала пробуется код,
+// приведенный к текущему формату (группы, регистр), затем ввод как есть -
+// для кодов, выпущенных до смены параметров CODE_*
+func (b *Bot) redeemCode(userID int64, input string) (*database.Redemption, error) {
+	normalized := b.codes.Normalize(input)
+	redemption, err := b.db.RedeemCode(userID, normalized)
+	if errors.Is(err, database.ErrCodeNotFound) && normalized != input {
+		return b.db.RedeemCode(userID, input)
+	}
+	return redemption, err
+}
+
+// validCode проверяет, что введенный код мог быть выпущен генератором кодов.
+// Коды, выпущенные до смены параметров CODE_*, проверяются по прежнему формату
+func (b *Bot) validCode(input string) bool {
+	return b.codes.Valid(b.codes.Normalize(input)) || isLegacyCode(input)
+}
+
+// isLegacyCode проверяет что код содержит от 4 до 64 латинских букв, цифр и дефисов
+func isLegacyCode(code string) bool {
+	if len(code) < 4 || len(code) > 64 {
+		return false
+	}
 	for _, char := range code {
-		if !((char >= 'a' && char <= 'z') || 
-			 (char >= 'A' && char <= 'Z') || 
-			 (char >= '0' && char <= '9')) {
+		if !((char >= 'a' && char <= 'z') ||
+			(char >= 'A' && char <= 'Z') ||
+			(char >= '0' && char <= '9') ||
+			char == '-') {
 			return false
 		}
 	}
 	return true
 }
-
-// generateActivationCode генерирует случайный код активации
-func generateActivationCode() string {
-	rand.Seed(time.Now().UnixNano())
-	
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	code := make([]byte, 10)
-	
-	for i := range code {
-		code[i] = charset[rand.Intn(len(charset))]
-	}
-	
-	return string(code)
-}
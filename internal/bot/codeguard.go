@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"time"
+	"unicode/utf8"
+
+	"go-ovpn-bot/internal/database"
+)
+
+// maxAuditCodeLength ограничивает длину введенного кода, сохраняемого в журнале аудита
+const maxAuditCodeLength = 64
+
+// checkCodeAttempts проверяет, что пользователь может вводить коды активации:
+// нет действующей блокировки и не превышен общий порог неудачных попыток
+func (b *Bot) checkCodeAttempts(chatID int64, user *database.User, now time.Time) bool {
+	t := b.tr(user)
+	lockout, err := b.db.GetCodeLockout(user.ID)
+	if err != nil {
+		log.Printf("Failed to get code lockout: %v", err)
+		b.sendMessage(chatID, t.T("common.error"))
+		return false
+	}
+	if lockout.Locked(now) {
+		b.sendMessage(chatID, t.T("code.locked", formatDate(*lockout.LockedUntil)))
+		return false
+	}
+
+	failures, err := b.db.CountAuditEntries(database.AuditFilter{
+		Action: database.AuditCodeFailed,
+		Since:  now.Add(-b.config.CodeGlobalWindow),
+	})
+	if err != nil {
+		log.Printf("Failed to count failed code attempts: %v", err)
+		b.sendMessage(chatID, t.T("common.error"))
+		return false
+	}
+	if failures >= b.config.CodeGlobalMaxFailures {
+		b.alertCodeBurst(failures, now)
+		b.sendMessage(chatID, t.T("code.paused"))
+		return false
+	}
+
+	return true
+}
+
+// recordCodeFailure записывает неудачную попытку в журнал аудита и блокирует ввод кодов,
+// если за CodeFailureWindow набралось CodeMaxFailures неудачных попыток
+func (b *Bot) recordCodeFailure(chatID int64, user *database.User, code, reason string, now time.Time) {
+	b.audit(user.ID, database.AuditCodeFailed, reason+": "+truncateCode(code), now)
+
+	lockout, err := b.db.GetCodeLockout(user.ID)
+	if err != nil {
+		log.Printf("Failed to get code lockout: %v", err)
+		return
+	}
+
+	// Попытки до окончания предыдущей блокировки уже были наказаны
+	since := now.Add(-b.config.CodeFailureWindow)
+	if lockout.LockedUntil != nil && lockout.LockedUntil.After(since) {
+		since = *lockout.LockedUntil
+	}
+	failures, err := b.db.CountAuditEntries(database.AuditFilter{
+		Action: database.AuditCodeFailed,
+		UserID: user.ID,
+		Since:  since,
+	})
+	if err != nil {
+		log.Printf("Failed to count failed code attempts: %v", err)
+		return
+	}
+
+	if failures >= b.config.CodeMaxFailures {
+		b.lockCodeEntry(chatID, user, lockout.Lockouts, failures, now)
+	}
+
+	global, err := b.db.CountAuditEntries(database.AuditFilter{
+		Action: database.AuditCodeFailed,
+		Since:  now.Add(-b.config.CodeGlobalWindow),
+	})
+	if err != nil {
+		log.Printf("Failed to count failed code attempts: %v", err)
+		return
+	}
+	if global >= b.config.CodeGlobalMaxFailures {
+		b.alertCodeBurst(global, now)
+	}
+}
+
+// lockCodeEntry блокирует ввод кодов. Каждая следующая блокировка подряд вдвое длиннее
+// предыдущей, но не длиннее CodeLockoutMax
+func (b *Bot) lockCodeEntry(chatID int64, user *database.User, lockouts, failures int, now time.Time) {
+	duration := b.config.CodeLockout
+	for i := 0; i < lockouts && duration < b.config.CodeLockoutMax; i++ {
+		duration *= 2
+	}
+	if duration > b.config.CodeLockoutMax {
+		duration = b.config.CodeLockoutMax
+	}
+	until := now.Add(duration)
+
+	if err := b.db.LockCodeEntry(user.ID, until); err != nil {
+		log.Printf("Failed to lock code entry: %v", err)
+		return
+	}
+	b.audit(user.ID, database.AuditCodeLockout, fmt.Sprintf("%d failed attempts, locked for %s", failures, duration), now)
+	log.Printf("Code entry of user %d locked for %s after %d failed attempts", user.TelegramID, duration, failures)
+
+	b.sendMessage(chatID, b.tr(user).T("code.lockout", formatDate(until)))
+	b.notifyAdmins("admin.alert_lockout",
+		user.TelegramID, displayUsername(user.Username), failures, formatDate(until), lockouts+1)
+}
+
+// alertCodeBurst предупреждает администраторов о всплеске неудачных попыток,
+// не чаще одного раза за CodeGlobalWindow
+func (b *Bot) alertCodeBurst(failures int, now time.Time) {
+	b.alertMu.Lock()
+	if now.Sub(b.lastBurstAlert) < b.config.CodeGlobalWindow {
+		b.alertMu.Unlock()
+		return
+	}
+	b.lastBurstAlert = now
+	b.alertMu.Unlock()
+
+	log.Printf("Code brute-force burst: %d failed attempts in %s, redemption paused", failures, b.config.CodeGlobalWindow)
+	b.notifyAdmins("admin.alert_burst", failures, b.config.CodeGlobalWindow)
+}
+
+// audit добавляет запись в журнал аудита; ошибка записи не прерывает обработку запроса
+func (b *Bot) audit(userID int64, action, detail string, now time.Time) {
+	if err := b.db.AddAuditEntry(userID, action, detail, now); err != nil {
+		log.Printf("Failed to write audit log: %v", err)
+	}
+}
+
+// truncateCode обрезает введенный текст для журнала аудита
+func truncateCode(code string) string {
+	if utf8.RuneCountInString(code) <= maxAuditCodeLength {
+		return code
+	}
+	return string([]rune(code)[:maxAuditCodeLength]) + "…"
+}
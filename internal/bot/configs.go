@@ -0,0 +1,297 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/skip2/go-qrcode"
+	"go-ovpn-bot/internal/database"
+	"go-ovpn-bot/internal/i18n"
+)
+
+const (
+	// maxConfigLabelLength - максимальная длина названия конфигурации в символах
+	maxConfigLabelLength = 32
+	// qrModuleSize - размер точки QR-кода в пикселях
+	qrModuleSize = 4
+)
+
+// configLabelPayload - данные диалога переименования
+type configLabelPayload struct {
+	ConfigID int64 `json:"config_id"`
+}
+
+// configRoutes регистрирует кнопки списка и карточек конфигураций.
+// Данные кнопок: configs - список, config_<действие>_<id> - действие с конфигурацией
+func (b *Bot) configRoutes(r *router) {
+	r.callback("configs", func(req *request) { b.handleConfigsCallback(req.query, req.user) })
+	for _, action := range []string{"show", "download", "qr", "rename"} {
+		action := action
+		r.callback("config_"+action, b.idCallback(func(query *tgbotapi.CallbackQuery, user *database.User, id int64) {
+			b.handleConfigCallback(query, user, action, id)
+		}))
+	}
+}
+
+// handleListCommand показывает конфигурации пользователя: /list
+func (b *Bot) handleListCommand(message *tgbotapi.Message, user *database.User) {
+	t := b.tr(user)
+	if len(user.Configs) == 0 {
+		b.sendMessage(message.Chat.ID, t.T("common.no_configs"))
+		return
+	}
+
+	text, markup := b.renderConfigList(t, user)
+	b.sendWithKeyboard(message.Chat.ID, text, markup)
+}
+
+// handleConfigsCallback возвращает карточку к списку конфигураций
+func (b *Bot) handleConfigsCallback(query *tgbotapi.CallbackQuery, user *database.User) {
+	text, markup := b.renderConfigList(b.tr(user), user)
+	b.editWithKeyboard(query.Message.Chat.ID, query.Message.MessageID, text, markup)
+}
+
+// handleConfigCallback обрабатывает кнопки карточки конфигурации:
+// show_<id>, download_<id>, qr_<id>, rename_<id>
+func (b *Bot) handleConfigCallback(query *tgbotapi.CallbackQuery, user *database.User, action string, configID int64) {
+	t := b.tr(user)
+	chatID := query.Message.Chat.ID
+
+	config, err := b.db.GetConfigByID(configID)
+	if err != nil || config.UserID != user.ID {
+		b.answerCallbackQuery(query.ID, t.T("common.config_not_found"))
+		return
+	}
+
+	switch action {
+	case "show":
+		text, markup := b.renderConfigCard(t, config)
+		b.editWithKeyboard(chatID, query.Message.MessageID, text, markup)
+	case "download":
+		data, ok := b.readConfig(query, t, config)
+		if !ok {
+			return
+		}
+		if b.sendConfigFile(chatID, t, config, data) {
+			b.audit(user.ID, database.AuditConfigDownload, config.Name+" as file", time.Now())
+		} else {
+			b.answerCallbackQuery(query.ID, t.T("common.error_short"))
+		}
+	case "qr":
+		data, ok := b.readConfig(query, t, config)
+		if !ok {
+			return
+		}
+		if b.sendConfigQR(chatID, t, config, data) {
+			b.audit(user.ID, database.AuditConfigDownload, config.Name+" as QR code", time.Now())
+		}
+	case "rename":
+		if err := b.startConversation(chatID, user, stateAwaitingConfigLabel, configLabelPayload{ConfigID: config.ID}); err != nil {
+			log.Printf("Failed to start conversation: %v", err)
+			b.answerCallbackQuery(query.ID, t.T("common.error_short"))
+			return
+		}
+		b.sendMessage(chatID, t.T("config.rename_prompt", config.Name, maxConfigLabelLength))
+	}
+}
+
+// readConfig читает .ovpn с сервера, на котором создана конфигурация. Владелец
+// конфигурации проверяется до вызова
+func (b *Bot) readConfig(query *tgbotapi.CallbackQuery, t *i18n.Localizer, config *database.Config) ([]byte, bool) {
+	server, ok := b.servers.Get(config.Server)
+	if !ok {
+		log.Printf("Server %q of config %d is not configured", config.Server, config.ID)
+		b.answerCallbackQuery(query.ID, t.T("config.unavailable"))
+		return nil, false
+	}
+
+	data, err := server.Provisioner.ReadConfigFile(config.FilePath)
+	if err != nil {
+		log.Printf("Failed to read config file of config %d: %v", config.ID, err)
+		b.answerCallbackQuery(query.ID, t.T("config.unavailable"))
+		return nil, false
+	}
+	return data, true
+}
+
+// sendConfigFile повторно отправляет .ovpn, если сообщение после /add потеряно
+func (b *Bot) sendConfigFile(chatID int64, t *i18n.Localizer, config *database.Config, data []byte) bool {
+	file := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  config.Name + ".ovpn",
+		Bytes: data,
+	})
+	file.Caption = t.T("config.download_caption", configTitle(*config))
+	file.ParseMode = "Markdown"
+	if _, err := b.api.Send(file); err != nil {
+		log.Printf("Failed to send config file: %v", err)
+		return false
+	}
+	return true
+}
+
+// sendConfigQR отправляет профиль QR-кодом для импорта с телефона. PNG уходит
+// документом: Telegram сжимает фотографии, и плотный QR-код перестает читаться
+func (b *Bot) sendConfigQR(chatID int64, t *i18n.Localizer, config *database.Config, data []byte) bool {
+	qr, err := qrcode.New(string(data), qrcode.Low)
+	if err != nil {
+		// Профиль со встроенными ключами RSA обычно больше емкости QR-кода (~3 КБ)
+		log.Printf("Failed to encode config %d as QR code: %v", config.ID, err)
+		b.sendMessage(chatID, t.T("config.qr_too_large"))
+		return false
+	}
+	image, err := qr.PNG(-qrModuleSize)
+	if err != nil {
+		log.Printf("Failed to render QR code: %v", err)
+		b.sendMessage(chatID, t.T("common.error"))
+		return false
+	}
+
+	file := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  config.Name + ".png",
+		Bytes: image,
+	})
+	file.Caption = t.T("config.qr_caption", configTitle(*config))
+	file.ParseMode = "Markdown"
+	if _, err := b.api.Send(file); err != nil {
+		log.Printf("Failed to send QR code: %v", err)
+		return false
+	}
+	return true
+}
+
+// handleConfigLabel сохраняет название, введенное после кнопки "Переименовать".
+// Неподходящее название не завершает диалог, чтобы можно было ввести другое
+func (b *Bot) handleConfigLabel(message *tgbotapi.Message, user *database.User, conv *database.Conversation) {
+	t := b.tr(user)
+
+	var payload configLabelPayload
+	if err := decodePayload(conv, &payload); err != nil {
+		log.Printf("Failed to rename config: %v", err)
+		b.endConversation(message.Chat.ID, user)
+		b.sendMessage(message.Chat.ID, t.T("common.error"))
+		return
+	}
+
+	// Конфигурация могла быть удалена, пока пользователь вводил название
+	config, err := b.db.GetConfigByID(payload.ConfigID)
+	if err != nil || config.UserID != user.ID {
+		b.endConversation(message.Chat.ID, user)
+		b.sendMessage(message.Chat.ID, t.T("common.config_not_found"))
+		return
+	}
+
+	label := strings.TrimSpace(message.Text)
+	if !validConfigLabel(label) {
+		b.sendMessage(message.Chat.ID, t.T("config.rename_invalid", maxConfigLabelLength))
+		return
+	}
+
+	b.endConversation(message.Chat.ID, user)
+	if err := b.db.SetConfigLabel(config.ID, label); err != nil {
+		log.Printf("Failed to rename config %d: %v", config.ID, err)
+		b.sendMessage(message.Chat.ID, t.T("common.error"))
+		return
+	}
+	config.Label = label
+
+	text, markup := b.renderConfigCard(t, config)
+	b.sendWithKeyboard(message.Chat.ID, t.T("config.renamed")+text, markup)
+}
+
+// renderConfigList формирует список конфигураций с кнопкой карточки для каждой
+func (b *Bot) renderConfigList(t *i18n.Localizer, user *database.User) (string, tgbotapi.InlineKeyboardMarkup) {
+	var sb strings.Builder
+	sb.WriteString(t.T("config.list_title", len(user.Configs), user.Limit))
+
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for _, config := range user.Configs {
+		sb.WriteString(t.T("config.list_line", configTitle(config), b.serverName(config), formatDate(config.CreatedAt)))
+
+		button := tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("📄 %s", configTitle(config)),
+			fmt.Sprintf("config_show_%d", config.ID),
+		)
+		keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{button})
+	}
+
+	return sb.String(), inlineKeyboard(keyboard)
+}
+
+// renderConfigCard формирует карточку конфигурации: сервер, даты, статус подключения и действия
+func (b *Bot) renderConfigCard(t *i18n.Localizer, config *database.Config) (string, tgbotapi.InlineKeyboardMarkup) {
+	var sb strings.Builder
+	sb.WriteString(t.T("config.card_title", config.Name))
+	if config.Label != "" {
+		sb.WriteString(t.T("config.card_label", config.Label))
+	}
+	sb.WriteString(t.T("config.card_server", b.serverName(*config)))
+	sb.WriteString(t.T("config.card_created", formatDate(config.CreatedAt)))
+	if config.ExpiresAt != nil {
+		sb.WriteString(t.T("config.card_expires", formatDate(*config.ExpiresAt)))
+	} else {
+		sb.WriteString(t.T("config.card_no_expiry"))
+	}
+	sb.WriteString(t.T("config.card_status", b.configStatus(t, *config)))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(t.T("config.button_download"), fmt.Sprintf("config_download_%d", config.ID)),
+			tgbotapi.NewInlineKeyboardButtonData(t.T("config.button_qr"), fmt.Sprintf("config_qr_%d", config.ID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(t.T("config.button_rename"), fmt.Sprintf("config_rename_%d", config.ID)),
+			tgbotapi.NewInlineKeyboardButtonData(t.T("config.button_remove"), fmt.Sprintf("remove_%d", config.ID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(t.T("config.button_back"), "configs"),
+		),
+	)
+	return sb.String(), keyboard
+}
+
+// configStatus описывает подключение конфигурации так же, как /status
+func (b *Bot) configStatus(t *i18n.Localizer, config database.Config) string {
+	if config.Blocked {
+		return t.T("config.status_blocked")
+	}
+
+	connected, failed := b.connectedClients([]database.Config{config})
+	server, ok := b.servers.Get(config.Server)
+	if !ok || failed[server.Name] {
+		return t.T("config.status_unavailable")
+	}
+	client, online := connected[server.Name][config.Name]
+	if !online {
+		return t.T("config.status_offline")
+	}
+	return t.T("config.status_online", client.ConnectedSince.Format("02.01.2006 15:04"))
+}
+
+// serverName возвращает имя сервера конфигурации; старые конфигурации хранят пустое имя
+func (b *Bot) serverName(config database.Config) string {
+	if server, ok := b.servers.Get(config.Server); ok {
+		return server.Name
+	}
+	return config.Server
+}
+
+// configTitle возвращает название конфигурации, а если его нет - имя клиента
+func configTitle(config database.Config) string {
+	if config.Label != "" {
+		return config.Label
+	}
+	return config.Name
+}
+
+// validConfigLabel проверяет длину названия и отсутствие символов разметки Markdown,
+// которые сломали бы сообщения с названием
+func validConfigLabel(label string) bool {
+	if label == "" || utf8.RuneCountInString(label) > maxConfigLabelLength {
+		return false
+	}
+	return !strings.ContainsAny(label, "*_`[]\n")
+}
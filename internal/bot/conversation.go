@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go-ovpn-bot/internal/database"
+)
+
+// Состояния диалогов
+const (
+	// stateAwaitingCode - бот ждет код активации после /code
+	stateAwaitingCode = "awaiting_code"
+	// stateAwaitingConfigLabel - бот ждет новое название конфигурации после кнопки "Переименовать"
+	stateAwaitingConfigLabel = "awaiting_config_label"
+)
+
+// conversationHandler обрабатывает сообщение пользователя на шаге диалога
+type conversationHandler func(b *Bot, message *tgbotapi.Message, user *database.User, conv *database.Conversation)
+
+// conversationHandlers связывает состояния диалогов с обработчиками.
+// Новый многошаговый сценарий добавляет сюда свои состояния
+var conversationHandlers = map[string]conversationHandler{
+	stateAwaitingCode: func(b *Bot, message *tgbotapi.Message, user *database.User, _ *database.Conversation) {
+		b.handleActivationCode(message, user)
+	},
+	stateAwaitingConfigLabel: (*Bot).handleConfigLabel,
+}
+
+// startConversation переводит пользователя в чате в состояние state; payload сохраняется
+// в JSON и доступен следующему шагу через decodePayload
+func (b *Bot) startConversation(chatID int64, user *database.User, state string, payload interface{}) error {
+	var data []byte
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return fmt.Errorf("failed to encode conversation payload: %w", err)
+		}
+	}
+
+	now := time.Now()
+	return b.db.SaveConversation(database.Conversation{
+		ChatID:    chatID,
+		UserID:    user.ID,
+		State:     state,
+		Payload:   string(data),
+		ExpiresAt: now.Add(b.config.ConversationTimeout),
+		UpdatedAt: now,
+	})
+}
+
+// endConversation завершает диалог пользователя в чате
+func (b *Bot) endConversation(chatID int64, user *database.User) {
+	if err := b.db.DeleteConversation(chatID, user.ID); err != nil {
+		log.Printf("Failed to end conversation in chat %d: %v", chatID, err)
+	}
+}
+
+// decodePayload разбирает данные диалога, сохраненные startConversation
+func decodePayload(conv *database.Conversation, v interface{}) error {
+	if conv.Payload == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(conv.Payload), v); err != nil {
+		return fmt.Errorf("failed to decode conversation payload: %w", err)
+	}
+	return nil
+}
+
+// handleConversation - middleware сообщений: /cancel и ответы на шаги диалога
+// обрабатываются до маршрутизации команд
+func (b *Bot) handleConversation(next handlerFunc) handlerFunc {
+	return func(req *request) {
+		if req.message == nil {
+			next(req)
+			return
+		}
+
+		// /cancel прерывает текущий диалог
+		if req.command == "cancel" {
+			b.handleCancelCommand(req.message, req.user)
+			return
+		}
+
+		// Продолжаем многошаговый диалог, если он начат (например, ввод кода после /code)
+		if b.continueConversation(req.message, req.user) {
+			return
+		}
+
+		next(req)
+	}
+}
+
+// continueConversation передает сообщение обработчику текущего шага диалога.
+// Возвращает false, если диалога нет и сообщение нужно обработать как обычно.
+// Команда прерывает диалог и выполняется сама. Диалог принадлежит пользователю,
+// поэтому сообщения других участников группового чата его не продолжают
+func (b *Bot) continueConversation(message *tgbotapi.Message, user *database.User) bool {
+	conv, err := b.db.GetConversation(message.Chat.ID, user.ID)
+	if err != nil {
+		log.Printf("Failed to get conversation: %v", err)
+		return false
+	}
+	if conv == nil {
+		return false
+	}
+
+	if message.IsCommand() {
+		b.endConversation(message.Chat.ID, user)
+		return false
+	}
+
+	if conv.Expired(time.Now()) {
+		b.endConversation(message.Chat.ID, user)
+		b.sendMessage(message.Chat.ID, b.tr(user).T("conversation.expired"))
+		return true
+	}
+
+	handler, ok := conversationHandlers[conv.State]
+	if !ok {
+		log.Printf("Unknown conversation state %q in chat %d", conv.State, message.Chat.ID)
+		b.endConversation(message.Chat.ID, user)
+		return false
+	}
+
+	handler(b, message, user, conv)
+	return true
+}
+
+// handleCancelCommand прерывает текущий диалог
+func (b *Bot) handleCancelCommand(message *tgbotapi.Message, user *database.User) {
+	t := b.tr(user)
+	conv, err := b.db.GetConversation(message.Chat.ID, user.ID)
+	if err != nil {
+		log.Printf("Failed to get conversation: %v", err)
+		b.sendMessage(message.Chat.ID, t.T("common.error"))
+		return
+	}
+	if conv == nil {
+		b.sendMessage(message.Chat.ID, t.T("conversation.nothing_to_cancel"))
+		return
+	}
+
+	b.endConversation(message.Chat.ID, user)
+	b.sendMessage(message.Chat.ID, t.T("conversation.cancelled"))
+}
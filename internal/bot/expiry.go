@@ -0,0 +1,79 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go-ovpn-bot/internal/database"
+)
+
+// runExpiryScheduler периодически предупреждает владельцев об окончании срока
+// конфигураций и отзывает истекшие
+func (b *Bot) runExpiryScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	b.checkExpiry()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.checkExpiry()
+		}
+	}
+}
+
+func (b *Bot) checkExpiry() {
+	now := time.Now()
+
+	// Удаляем брошенные диалоги, чтобы таблица не росла
+	if _, err := b.db.DeleteExpiredConversations(now); err != nil {
+		log.Printf("Failed to delete expired conversations: %v", err)
+	}
+
+	expiring, err := b.db.ListExpiringConfigs(now.Add(b.config.ExpiryNotice))
+	if err != nil {
+		log.Printf("Failed to list expiring configs: %v", err)
+	} else {
+		for _, config := range expiring {
+			if !config.ExpiresAt.After(now) {
+				// Истекшие конфигурации отзываются ниже с отдельным уведомлением
+				continue
+			}
+			b.sendMessage(config.TelegramID, b.trUserID(config.UserID).T("expiry.notice",
+				config.Name, formatDate(*config.ExpiresAt)))
+			if err := b.db.MarkExpiryNotified(config.ID); err != nil {
+				log.Printf("Failed to mark expiry of config %d: %v", config.ID, err)
+			}
+		}
+	}
+
+	expired, err := b.db.ListExpiredConfigs(now)
+	if err != nil {
+		log.Printf("Failed to list expired configs: %v", err)
+		return
+	}
+	for _, config := range expired {
+		if err := b.revokeConfig(config.Config); err != nil {
+			log.Printf("Failed to revoke expired config %s: %v", config.Name, err)
+			continue
+		}
+		log.Printf("Config %s of user %d expired and was revoked", config.Name, config.UserID)
+		b.sendMessage(config.TelegramID, b.trUserID(config.UserID).T("expiry.revoked", config.Name))
+	}
+}
+
+// revokeConfig отзывает клиента на его сервере и удаляет запись из базы
+func (b *Bot) revokeConfig(config database.Config) error {
+	if err := b.servers.RevokeClient(config.Server, config.Name, config.FilePath); err != nil {
+		return err
+	}
+	return b.db.DeleteConfig(config.ID)
+}
+
+// formatDate форматирует момент окончания срока для сообщений
+func formatDate(t time.Time) string {
+	return t.Local().Format("02.01.2006 15:04")
+}
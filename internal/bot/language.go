@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"errors"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go-ovpn-bot/internal/database"
+	"go-ovpn-bot/internal/i18n"
+)
+
+// languageAuto - данные кнопки, возвращающей язык из настроек Telegram
+const languageAuto = "auto"
+
+// tr возвращает переводчик для пользователя: выбранный командой /language язык,
+// иначе язык Telegram, иначе язык по умолчанию
+func (b *Bot) tr(user *database.User) *i18n.Localizer {
+	if user.Language != "" && b.bundle.Supported(user.Language) {
+		return b.bundle.Localizer(user.Language)
+	}
+	return b.bundle.Localizer(b.bundle.Match(user.TelegramLanguage))
+}
+
+// trFrom возвращает переводчик по языку отправителя, пока пользователь еще не загружен из базы
+func (b *Bot) trFrom(from *tgbotapi.User) *i18n.Localizer {
+	return b.bundle.Localizer(b.bundle.Match(from.LanguageCode))
+}
+
+// trTelegramID возвращает переводчик для сообщения, отправляемого не в ответ на обновление.
+// Незарегистрированный пользователь получает язык по умолчанию
+func (b *Bot) trTelegramID(telegramID int64) *i18n.Localizer {
+	user, err := b.db.GetUserByTelegramID(telegramID)
+	if err != nil {
+		if !errors.Is(err, database.ErrUserNotFound) {
+			log.Printf("Failed to get user %d: %v", telegramID, err)
+		}
+		return b.bundle.Localizer(b.config.DefaultLanguage)
+	}
+	return b.tr(user)
+}
+
+// trUserID - то же, что trTelegramID, для ID пользователя в базе
+func (b *Bot) trUserID(userID int64) *i18n.Localizer {
+	user, err := b.db.GetUserByID(userID)
+	if err != nil {
+		log.Printf("Failed to get user %d: %v", userID, err)
+		return b.bundle.Localizer(b.config.DefaultLanguage)
+	}
+	return b.tr(user)
+}
+
+func (b *Bot) handleLanguageCommand(message *tgbotapi.Message, user *database.User) {
+	t := b.tr(user)
+
+	// Текущий выбор отмечается галочкой; названия языков - на самих языках
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for _, lang := range b.bundle.Languages() {
+		label := b.bundle.Localizer(lang).T(i18n.NameKey)
+		if lang == user.Language {
+			label = "✅ " + label
+		}
+		button := tgbotapi.NewInlineKeyboardButtonData(label, "language_"+lang)
+		keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{button})
+	}
+
+	auto := t.T("language.auto")
+	if user.Language == "" {
+		auto = "✅ " + auto
+	}
+	keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData(auto, "language_"+languageAuto),
+	})
+
+	b.sendWithKeyboard(message.Chat.ID, t.T("language.choose"), tgbotapi.NewInlineKeyboardMarkup(keyboard...))
+}
+
+func (b *Bot) handleLanguageCallback(query *tgbotapi.CallbackQuery, user *database.User, lang string) {
+	if lang == languageAuto {
+		lang = ""
+	} else if !b.bundle.Supported(lang) {
+		b.answerCallbackQuery(query.ID, b.tr(user).T("common.invalid_data"))
+		return
+	}
+
+	if err := b.db.SetUserLanguage(user.ID, lang); err != nil {
+		log.Printf("Failed to set language of user %d: %v", user.TelegramID, err)
+		b.answerCallbackQuery(query.ID, b.tr(user).T("common.error_short"))
+		return
+	}
+	user.Language = lang
+
+	// Подтверждение уже на новом языке
+	t := b.tr(user)
+	b.sendMessage(query.Message.Chat.ID, t.T("language.changed", t.T(i18n.NameKey)))
+	b.answerCallbackQuery(query.ID, t.T("language.changed_short"))
+}
@@ -0,0 +1,199 @@
+package bot
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// maxRateBuckets - после стольких пользователей limiter удаляет неактивных
+const maxRateBuckets = 10000
+
+// recoverPanic не дает ошибке в обработчике остановить воркер и весь бот
+func (b *Bot) recoverPanic(next handlerFunc) handlerFunc {
+	return func(req *request) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Panic while handling %s from user %d: %v\n%s", req.describe(), req.from().ID, r, debug.Stack())
+				b.replyError(req)
+			}
+		}()
+		next(req)
+	}
+}
+
+// logRequests в режиме DEBUG записывает каждый запрос и время его обработки
+func (b *Bot) logRequests(next handlerFunc) handlerFunc {
+	return func(req *request) {
+		if !b.config.Debug {
+			next(req)
+			return
+		}
+
+		from := req.from()
+		start := time.Now()
+		log.Printf("Handling %s from user %d (%s)", req.describe(), from.ID, from.UserName)
+		next(req)
+		log.Printf("Handled %s from user %d in %s", req.describe(), from.ID, time.Since(start))
+	}
+}
+
+// rateLimit отклоняет запросы пользователя сверх RATE_LIMIT. Проверка выполняется
+// до обращения к базе, администраторы не ограничиваются
+func (b *Bot) rateLimit(next handlerFunc) handlerFunc {
+	return func(req *request) {
+		from := req.from()
+		if b.limiter == nil || b.config.IsAdmin(from.ID) {
+			next(req)
+			return
+		}
+
+		allowed, warn := b.limiter.allow(from.ID, time.Now())
+		if allowed {
+			next(req)
+			return
+		}
+
+		if req.query != nil {
+			b.answerCallbackQuery(req.query.ID, req.tr.T("common.rate_limited_short"))
+		} else if warn {
+			// Предупреждаем один раз, чтобы не отвечать на каждое лишнее сообщение
+			b.sendMessage(req.chatID(), req.tr.T("common.rate_limited"))
+		}
+	}
+}
+
+// loadUser находит или регистрирует пользователя, выбирает язык ответов
+// и не пропускает заблокированных
+func (b *Bot) loadUser(next handlerFunc) handlerFunc {
+	return func(req *request) {
+		from := req.from()
+		user, err := b.db.GetOrCreateUser(from.ID, from.UserName)
+		if err != nil {
+			log.Printf("Failed to get/create user: %v", err)
+			b.replyError(req)
+			return
+		}
+
+		// Язык Telegram запоминается для фоновых уведомлений, которые приходят вне обновлений
+		if from.LanguageCode != "" && from.LanguageCode != user.TelegramLanguage {
+			if err := b.db.SetUserTelegramLanguage(user.ID, from.LanguageCode); err != nil {
+				log.Printf("Failed to save language of user %d: %v", user.TelegramID, err)
+			}
+			user.TelegramLanguage = from.LanguageCode
+		}
+		req.user = user
+		req.tr = b.tr(user)
+
+		if user.Banned {
+			if req.query != nil {
+				b.answerCallbackQuery(req.query.ID, req.tr.T("common.banned_short"))
+			} else {
+				b.sendMessage(req.chatID(), req.tr.T("common.banned"))
+			}
+			return
+		}
+
+		next(req)
+	}
+}
+
+// requireAdmin пропускает только пользователей из ADMIN_IDS. Для остальных
+// команды администратора выглядят как неизвестные
+func (b *Bot) requireAdmin(next handlerFunc) handlerFunc {
+	return func(req *request) {
+		if b.config.IsAdmin(req.from().ID) {
+			next(req)
+			return
+		}
+
+		if req.query != nil {
+			b.answerCallbackQuery(req.query.ID, req.tr.T("common.forbidden"))
+		} else {
+			b.handleUnknownCommand(req)
+		}
+	}
+}
+
+// replyError сообщает пользователю о внутренней ошибке
+func (b *Bot) replyError(req *request) {
+	if req.query != nil {
+		b.answerCallbackQuery(req.query.ID, req.tr.T("common.error_short"))
+	} else {
+		b.sendMessage(req.chatID(), req.tr.T("common.error"))
+	}
+}
+
+// rateLimiter - token bucket на каждого пользователя: ведро на burst запросов
+// пополняется со скоростью rate запросов в секунду
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[int64]*rateBucket
+}
+
+type rateBucket struct {
+	tokens  float64
+	updated time.Time
+	// warned - пользователь уже предупрежден о текущем превышении
+	warned bool
+}
+
+// newRateLimiter создает limiter на perMinute запросов в минуту; 0 отключает ограничение
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: make(map[int64]*rateBucket),
+	}
+}
+
+// allow расходует запрос пользователя. warn равен true для первого отклоненного
+// запроса после разрешенного
+func (l *rateLimiter) allow(userID int64, now time.Time) (allowed, warn bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[userID]
+	if !ok {
+		if len(l.buckets) >= maxRateBuckets {
+			l.prune(now)
+		}
+		bucket = &rateBucket{tokens: l.burst, updated: now}
+		l.buckets[userID] = bucket
+	}
+
+	l.refill(bucket, now)
+	if bucket.tokens < 1 {
+		warn = !bucket.warned
+		bucket.warned = true
+		return false, warn
+	}
+
+	bucket.tokens--
+	bucket.warned = false
+	return true, false
+}
+
+func (l *rateLimiter) refill(bucket *rateBucket, now time.Time) {
+	bucket.tokens += now.Sub(bucket.updated).Seconds() * l.rate
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.updated = now
+}
+
+// prune удаляет полные ведра: они не отличаются от ведер новых пользователей
+func (l *rateLimiter) prune(now time.Time) {
+	for userID, bucket := range l.buckets {
+		l.refill(bucket, now)
+		if bucket.tokens >= l.burst {
+			delete(l.buckets, userID)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	// 60 запросов в минуту - одно ведро пополняется на запрос в секунду
+	l := newRateLimiter(60, 3)
+	start := time.Now()
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	steps := []struct {
+		name    string
+		userID  int64
+		now     time.Time
+		allowed bool
+		warn    bool
+	}{
+		{"burst 1", 1, at(0), true, false},
+		{"burst 2", 1, at(0), true, false},
+		{"burst 3", 1, at(0), true, false},
+		{"first rejection warns", 1, at(0), false, true},
+		{"repeated rejection is silent", 1, at(500 * time.Millisecond), false, false},
+		{"other user has own bucket", 2, at(500 * time.Millisecond), true, false},
+		{"refilled after a second", 1, at(time.Second), true, false},
+		{"new rejection warns again", 1, at(time.Second), false, true},
+		{"silent until refill", 1, at(1900 * time.Millisecond), false, false},
+		// За долгий простой ведро наполняется только до burst
+		{"idle 1", 1, at(time.Minute), true, false},
+		{"idle 2", 1, at(time.Minute), true, false},
+		{"idle 3", 1, at(time.Minute), true, false},
+		{"idle bucket is capped", 1, at(time.Minute), false, true},
+	}
+
+	for _, step := range steps {
+		allowed, warn := l.allow(step.userID, step.now)
+		if allowed != step.allowed || warn != step.warn {
+			t.Errorf("%s: allow = %v, %v, want %v, %v", step.name, allowed, warn, step.allowed, step.warn)
+		}
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	if l := newRateLimiter(0, 10); l != nil {
+		t.Errorf("newRateLimiter(0) = %+v, want nil", l)
+	}
+}
+
+func TestRateLimiterPrune(t *testing.T) {
+	l := newRateLimiter(60, 2)
+	start := time.Now()
+	for userID := int64(1); userID <= maxRateBuckets; userID++ {
+		l.allow(userID, start)
+	}
+	// Ведро пользователя 1 еще не восстановилось к моменту заполнения таблицы
+	l.allow(1, start.Add(500*time.Millisecond))
+
+	// Новый пользователь сверх maxRateBuckets вызывает очистку полных ведер
+	l.allow(maxRateBuckets+1, start.Add(1500*time.Millisecond))
+	if len(l.buckets) != 2 {
+		t.Fatalf("buckets after prune = %d, want 2", len(l.buckets))
+	}
+	if allowed, _ := l.allow(1, start.Add(1500*time.Millisecond)); !allowed {
+		t.Fatal("user 1 rejected")
+	}
+	if allowed, warn := l.allow(1, start.Add(1500*time.Millisecond)); allowed || !warn {
+		t.Errorf("pruning refilled bucket of active user: allow = %v, %v", allowed, warn)
+	}
+}
@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"log"
+	"time"
+
+	"go-ovpn-bot/internal/database"
+	"go-ovpn-bot/internal/ovpn"
+)
+
+// enforceQuotas проверяет месячные квоты трафика всех пользователей с квотой
+// или с заблокированными конфигурациями
+func (b *Bot) enforceQuotas(now time.Time) {
+	states, err := b.db.ListQuotaStates()
+	if err != nil {
+		log.Printf("Failed to list quotas: %v", err)
+		return
+	}
+
+	for _, state := range states {
+		b.enforceQuota(state, now)
+	}
+}
+
+// enforceQuota предупреждает пользователя при расходе 80% и 100% квоты,
+// блокирует его конфигурации при превышении и снимает блокировку,
+// когда начинается новый месяц, квота увеличена или снята
+func (b *Bot) enforceQuota(state database.QuotaState, now time.Time) {
+	usage, err := b.db.GetUserMonthlyUsage(state.UserID, now)
+	if err != nil {
+		log.Printf("Failed to get monthly usage of user %d: %v", state.UserID, err)
+		return
+	}
+
+	period := database.QuotaPeriod(now)
+	warned := state.Warned
+	if state.Period != period {
+		warned = 0
+	}
+
+	used := usage.Total()
+	exceeded := state.Quota > 0 && used >= state.Quota
+	if err := b.setConfigsBlocked(state.UserID, exceeded); err != nil {
+		log.Printf("Failed to update blocks of user %d: %v", state.UserID, err)
+	}
+
+	level := 0
+	switch {
+	case exceeded:
+		level = database.QuotaBlockLevel
+	case state.Quota > 0 && used*100 >= state.Quota*database.QuotaWarnLevel:
+		level = database.QuotaWarnLevel
+	}
+
+	if level > warned {
+		b.sendQuotaWarning(state.UserID, state.TelegramID, level, used, state.Quota)
+	}
+	if level != warned || state.Period != period {
+		if err := b.db.SetQuotaWarning(state.UserID, period, level); err != nil {
+			log.Printf("Failed to save quota warning of user %d: %v", state.UserID, err)
+		}
+	}
+}
+
+// setConfigsBlocked блокирует или разблокирует все конфигурации пользователя на их серверах
+func (b *Bot) setConfigsBlocked(userID int64, blocked bool) error {
+	configs, err := b.db.GetUserConfigs(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, config := range configs {
+		if config.Blocked == blocked {
+			continue
+		}
+
+		server, ok := b.servers.Get(config.Server)
+		if !ok {
+			log.Printf("Config %d refers to unknown server %q", config.ID, config.Server)
+			continue
+		}
+		blocker, ok := server.Provisioner.(ovpn.Blocker)
+		if !ok {
+			log.Printf("Server %s does not support blocking clients", server.Name)
+			continue
+		}
+
+		if blocked {
+			err = blocker.BlockClient(config.Name)
+		} else {
+			err = blocker.UnblockClient(config.Name)
+		}
+		if err != nil {
+			log.Printf("Failed to update block of client %s: %v", config.Name, err)
+			continue
+		}
+
+		if err := b.db.SetConfigBlocked(config.ID, blocked); err != nil {
+			log.Printf("Failed to save block of config %d: %v", config.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// quotaExceeded проверяет, израсходована ли месячная квота пользователя
+func (b *Bot) quotaExceeded(user *database.User) (bool, error) {
+	if user.TrafficQuota <= 0 {
+		return false, nil
+	}
+
+	usage, err := b.db.GetUserMonthlyUsage(user.ID, time.Now())
+	if err != nil {
+		return false, err
+	}
+	return usage.Total() >= user.TrafficQuota, nil
+}
+
+func (b *Bot) sendQuotaWarning(userID, chatID int64, level int, used, quota int64) {
+	t := b.trUserID(userID)
+	var text string
+	if level >= database.QuotaBlockLevel {
+		text = t.T("quota.exhausted", formatBytes(t, used), formatBytes(t, quota))
+	} else {
+		text = t.T("quota.warning", level, formatBytes(t, used), formatBytes(t, quota))
+	}
+
+	b.sendMessage(chatID, text)
+}
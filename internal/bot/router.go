@@ -0,0 +1,216 @@
+package bot
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go-ovpn-bot/internal/database"
+	"go-ovpn-bot/internal/i18n"
+)
+
+// request - входящее сообщение или нажатие кнопки, разобранное роутером
+type request struct {
+	message *tgbotapi.Message
+	query   *tgbotapi.CallbackQuery
+	// user - пользователь из базы, заполняется middleware loadUser
+	user *database.User
+	// tr переводит ответы: сначала по языку Telegram, после loadUser - по выбору пользователя
+	tr *i18n.Localizer
+
+	// command - имя команды без "/" и "@BotName" в нижнем регистре, mention - имя бота
+	// после "@", args - аргументы через пробел, argText - аргументы одной строкой
+	command string
+	mention string
+	args    []string
+	argText string
+
+	// data - данные кнопки после префикса маршрута и "_"
+	data string
+}
+
+// newMessageRequest разбирает команду в начале сообщения
+func newMessageRequest(message *tgbotapi.Message) *request {
+	req := &request{message: message}
+
+	text := strings.TrimSpace(message.Text)
+	if !strings.HasPrefix(text, "/") {
+		return req
+	}
+
+	token, rest := text[1:], ""
+	if i := strings.IndexFunc(token, unicode.IsSpace); i >= 0 {
+		token, rest = token[:i], token[i:]
+	}
+	req.command, req.mention, _ = strings.Cut(token, "@")
+	req.command = strings.ToLower(req.command)
+	req.argText = strings.TrimSpace(rest)
+	req.args = strings.Fields(req.argText)
+	return req
+}
+
+// newCallbackRequest создает запрос для нажатия inline кнопки
+func newCallbackRequest(query *tgbotapi.CallbackQuery) *request {
+	return &request{query: query}
+}
+
+// from возвращает отправителя сообщения или нажавшего кнопку
+func (r *request) from() *tgbotapi.User {
+	if r.query != nil {
+		return r.query.From
+	}
+	return r.message.From
+}
+
+// chatID возвращает чат, в который отвечает бот
+func (r *request) chatID() int64 {
+	if r.query != nil {
+		if r.query.Message != nil {
+			return r.query.Message.Chat.ID
+		}
+		return r.query.From.ID
+	}
+	return r.message.Chat.ID
+}
+
+// describe кратко описывает запрос для журнала
+func (r *request) describe() string {
+	if r.query != nil {
+		return "callback " + r.query.Data
+	}
+	if r.command != "" {
+		return "command /" + r.command
+	}
+	return "message"
+}
+
+// handlerFunc обрабатывает запрос
+type handlerFunc func(req *request)
+
+// middleware оборачивает обработчик: проверяет запрос, дополняет его или прерывает цепочку,
+// не вызывая next
+type middleware func(next handlerFunc) handlerFunc
+
+// chain оборачивает h в middlewares; первая middleware выполняется первой
+func chain(h handlerFunc, middlewares ...middleware) handlerFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+type callbackRoute struct {
+	prefix  string
+	handler handlerFunc
+}
+
+// router выбирает обработчик по точному имени команды или префиксу данных кнопки
+type router struct {
+	// username - имя бота: команды вида /cmd@OtherBot в группах адресованы не ему
+	username    string
+	middlewares []middleware
+	commands    map[string]handlerFunc
+	callbacks   []callbackRoute
+	// notFound отвечает на неизвестные команды и сообщения вне диалога
+	notFound handlerFunc
+}
+
+func newRouter(username string, notFound handlerFunc) *router {
+	return &router{
+		username: username,
+		commands: make(map[string]handlerFunc),
+		notFound: notFound,
+	}
+}
+
+// use добавляет middleware, через которые проходит каждый запрос
+func (r *router) use(middlewares ...middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// command регистрирует обработчик команды /name; middlewares применяются только к ней
+func (r *router) command(name string, h handlerFunc, middlewares ...middleware) {
+	r.commands[name] = chain(h, middlewares...)
+}
+
+// callback регистрирует обработчик кнопок с данными "prefix" или "prefix_<аргумент>".
+// Если подходят несколько префиксов, выбирается самый длинный
+func (r *router) callback(prefix string, h handlerFunc, middlewares ...middleware) {
+	r.callbacks = append(r.callbacks, callbackRoute{prefix: prefix, handler: chain(h, middlewares...)})
+}
+
+// serve проводит запрос через общие middleware и передает обработчику маршрута.
+// Команды другому боту (/cmd@OtherBot) игнорируются сразу
+func (r *router) serve(req *request) {
+	if req.mention != "" && !strings.EqualFold(req.mention, r.username) {
+		return
+	}
+	chain(r.dispatch, r.middlewares...)(req)
+}
+
+func (r *router) dispatch(req *request) {
+	if req.query != nil {
+		r.dispatchCallback(req)
+		return
+	}
+
+	if req.command == "" {
+		r.notFound(req)
+		return
+	}
+
+	h, ok := r.commands[req.command]
+	if !ok {
+		r.notFound(req)
+		return
+	}
+	h(req)
+}
+
+func (r *router) dispatchCallback(req *request) {
+	data := req.query.Data
+
+	var route *callbackRoute
+	for i := range r.callbacks {
+		c := &r.callbacks[i]
+		if data != c.prefix && !strings.HasPrefix(data, c.prefix+"_") {
+			continue
+		}
+		if route == nil || len(c.prefix) > len(route.prefix) {
+			route = c
+		}
+	}
+	if route == nil {
+		return
+	}
+
+	req.data = strings.TrimPrefix(strings.TrimPrefix(data, route.prefix), "_")
+	route.handler(req)
+}
+
+// messageHandler адаптирует обработчик команды, которому не нужны аргументы
+func messageHandler(h func(*tgbotapi.Message, *database.User)) handlerFunc {
+	return func(req *request) {
+		h(req.message, req.user)
+	}
+}
+
+// idCallback разбирает аргумент кнопки как числовой ID
+func (b *Bot) idCallback(h func(*tgbotapi.CallbackQuery, *database.User, int64)) handlerFunc {
+	return func(req *request) {
+		id, err := strconv.ParseInt(req.data, 10, 64)
+		if err != nil {
+			b.answerCallbackQuery(req.query.ID, req.tr.T("common.invalid_data"))
+			return
+		}
+		h(req.query, req.user, id)
+	}
+}
+
+// stringCallback передает аргумент кнопки как строку
+func stringCallback(h func(*tgbotapi.CallbackQuery, *database.User, string)) handlerFunc {
+	return func(req *request) {
+		h(req.query, req.user, req.data)
+	}
+}
@@ -0,0 +1,152 @@
+package bot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// testRouter - роутер, который записывает вызванные обработчики и их аргументы
+type testRouter struct {
+	*router
+	calls []string
+}
+
+func newTestRouter() *testRouter {
+	r := &testRouter{}
+	record := func(name string) handlerFunc {
+		return func(req *request) {
+			call := name
+			switch {
+			case req.query != nil:
+				call += ":" + req.data
+			case len(req.args) > 0:
+				call += ":" + strings.Join(req.args, ",")
+			}
+			r.calls = append(r.calls, call)
+		}
+	}
+
+	r.router = newRouter("OvpnBot", record("notFound"))
+	r.command("add", record("add"))
+	r.command("address", record("address"))
+	r.callback("config", record("config"))
+	r.callback("config_delete", record("config_delete"))
+	r.callback("lang", record("lang"))
+	return r
+}
+
+func (r *testRouter) message(text string) []string {
+	r.calls = nil
+	r.serve(newMessageRequest(&tgbotapi.Message{Text: text}))
+	return r.calls
+}
+
+func (r *testRouter) press(data string) []string {
+	r.calls = nil
+	r.serve(newCallbackRequest(&tgbotapi.CallbackQuery{Data: data}))
+	return r.calls
+}
+
+func TestRouterCommands(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"/add", []string{"add"}},
+		{"/address", []string{"address"}},
+		{"/addx", []string{"notFound"}},
+		{"/ad", []string{"notFound"}},
+		{"/ADD", []string{"add"}},
+		{"  /add  work   phone ", []string{"add:work,phone"}},
+		{"/add\nwork", []string{"add:work"}},
+		{"/add@OvpnBot work", []string{"add:work"}},
+		{"/add@ovpnbot", []string{"add"}},
+		{"/add@OtherBot work", nil},
+		{"/addx@OtherBot", nil},
+		{"/", []string{"notFound"}},
+		{"add", []string{"notFound"}},
+		{"hello /add", []string{"notFound"}},
+	}
+
+	r := newTestRouter()
+	for _, tt := range tests {
+		if got := r.message(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("message %q: calls = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRouterCallbacks(t *testing.T) {
+	tests := []struct {
+		data string
+		want []string
+	}{
+		{"config", []string{"config:"}},
+		{"config_5", []string{"config:5"}},
+		// Самый длинный подходящий префикс выигрывает независимо от порядка регистрации
+		{"config_delete", []string{"config_delete:"}},
+		{"config_delete_5", []string{"config_delete:5"}},
+		{"config_deletex", []string{"config:deletex"}},
+		{"lang_en", []string{"lang:en"}},
+		{"configx", nil},
+		{"lan", nil},
+		{"", nil},
+	}
+
+	r := newTestRouter()
+	for _, tt := range tests {
+		if got := r.press(tt.data); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("callback %q: calls = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestRouterMiddlewares(t *testing.T) {
+	var calls []string
+	trace := func(name string) middleware {
+		return func(next handlerFunc) handlerFunc {
+			return func(req *request) {
+				calls = append(calls, name)
+				next(req)
+			}
+		}
+	}
+	stop := func(next handlerFunc) handlerFunc {
+		return func(req *request) {
+			calls = append(calls, "stop")
+		}
+	}
+	handler := func(name string) handlerFunc {
+		return func(req *request) {
+			calls = append(calls, name)
+		}
+	}
+
+	r := newRouter("OvpnBot", handler("notFound"))
+	r.use(trace("first"), trace("second"))
+	r.command("start", handler("start"))
+	r.command("admin", handler("admin"), trace("route"))
+	r.command("banned", handler("banned"), stop)
+
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"/start", []string{"first", "second", "start"}},
+		{"/admin", []string{"first", "second", "route", "admin"}},
+		{"/banned", []string{"first", "second", "stop"}},
+		{"/unknown", []string{"first", "second", "notFound"}},
+		// Команды другому боту не доходят даже до общих middleware
+		{"/start@OtherBot", nil},
+	}
+	for _, tt := range tests {
+		calls = nil
+		r.serve(newMessageRequest(&tgbotapi.Message{Text: tt.text}))
+		if !reflect.DeepEqual(calls, tt.want) {
+			t.Errorf("message %q: calls = %q, want %q", tt.text, calls, tt.want)
+		}
+	}
+}
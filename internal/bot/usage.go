@@ -0,0 +1,230 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go-ovpn-bot/internal/database"
+	"go-ovpn-bot/internal/ovpn"
+)
+
+// Почасовая статистика нужна только для отчета за последние сутки
+const hourlyUsageRetention = 7 * 24 * time.Hour
+
+const (
+	// sessionByteCountInterval - интервал уведомлений >BYTECOUNT_CLI: management интерфейса
+	sessionByteCountInterval = 30 * time.Second
+	// sessionWatchRetry - пауза перед переподключением к management интерфейсу
+	sessionWatchRetry = 30 * time.Second
+)
+
+// runUsageCollector периодически снимает счетчики трафика подключенных клиентов
+func (b *Bot) runUsageCollector(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	b.collectUsage()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.collectUsage()
+		}
+	}
+}
+
+// usageIndex возвращает индекс "сервер -> CN -> ID конфигурации"
+func (b *Bot) usageIndex() (map[string]map[string]int64, error) {
+	configs, err := b.db.ListConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]map[string]int64)
+	for _, config := range configs {
+		server, ok := b.servers.Get(config.Server)
+		if !ok {
+			continue
+		}
+		if index[server.Name] == nil {
+			index[server.Name] = make(map[string]int64)
+		}
+		index[server.Name][config.Name] = config.ID
+	}
+	return index, nil
+}
+
+// usageSessionKey отличает сессии клиента: счетчики OpenVPN начинаются заново с каждой
+func usageSessionKey(client ovpn.ConnectedClient) string {
+	return fmt.Sprintf("%d|%s", client.ConnectedSince.Unix(), client.RealAddress)
+}
+
+// collectUsage сохраняет трафик всех подключенных клиентов в базу данных
+func (b *Bot) collectUsage() {
+	index, err := b.usageIndex()
+	if err != nil {
+		log.Printf("Failed to list configs for usage: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, server := range b.servers.Servers() {
+		byName, ok := index[server.Name]
+		if !ok {
+			continue
+		}
+		source, ok := server.Provisioner.(ovpn.StatusSource)
+		if !ok {
+			continue
+		}
+
+		clients, err := source.ConnectedClients()
+		if err != nil {
+			log.Printf("Failed to read status of server %s: %v", server.Name, err)
+			continue
+		}
+
+		for _, client := range clients {
+			configID, ok := byName[client.CommonName]
+			if !ok {
+				continue
+			}
+			if err := b.db.RecordUsageSample(configID, usageSessionKey(client), client.BytesReceived, client.BytesSent, now); err != nil {
+				log.Printf("Failed to record usage for %s: %v", client.CommonName, err)
+			}
+		}
+	}
+
+	if err := b.db.PruneHourlyUsage(now.Add(-hourlyUsageRetention)); err != nil {
+		log.Printf("Failed to prune usage: %v", err)
+	}
+
+	b.enforceQuotas(now)
+}
+
+// runSessionWatcher записывает трафик из уведомлений management интерфейса сервера,
+// чтобы не терять трафик между опросами, когда клиент отключается.
+// После разрыва соединения переподключается до отмены ctx
+func (b *Bot) runSessionWatcher(ctx context.Context, server *ovpn.Server) {
+	watcher, ok := server.Provisioner.(ovpn.SessionWatcher)
+	if !ok {
+		return
+	}
+
+	var (
+		index     map[string]int64
+		indexedAt time.Time
+	)
+	record := func(client ovpn.ConnectedClient) {
+		now := time.Now()
+		configID, ok := index[client.CommonName]
+		// Конфигурация могла появиться после построения индекса
+		if !ok && now.Sub(indexedAt) >= sessionByteCountInterval {
+			all, err := b.usageIndex()
+			if err != nil {
+				log.Printf("Failed to list configs for usage: %v", err)
+				return
+			}
+			index, indexedAt = all[server.Name], now
+			configID, ok = index[client.CommonName]
+		}
+		if !ok {
+			return
+		}
+		if err := b.db.RecordUsageSample(configID, usageSessionKey(client), client.BytesReceived, client.BytesSent, now); err != nil {
+			log.Printf("Failed to record usage for %s: %v", client.CommonName, err)
+		}
+	}
+
+	for {
+		err := watcher.WatchSessions(ctx, sessionByteCountInterval, record)
+		if errors.Is(err, ovpn.ErrNoManagement) || ctx.Err() != nil {
+			return
+		}
+		log.Printf("Management events of server %s stopped: %v", server.Name, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(sessionWatchRetry):
+		}
+	}
+}
+
+// handleUsageCommand показывает трафик по конфигурациям пользователя
+func (b *Bot) handleUsageCommand(message *tgbotapi.Message, user *database.User) {
+	t := b.tr(user)
+	if len(user.Configs) == 0 {
+		b.sendMessage(message.Chat.ID, t.T("common.no_configs"))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(t.T("usage.title"))
+
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for _, config := range user.Configs {
+		summary, err := b.db.GetUsageSummary(config.ID, time.Now())
+		if err != nil {
+			log.Printf("Failed to get usage for config %d: %v", config.ID, err)
+			b.sendMessage(message.Chat.ID, t.T("common.error"))
+			return
+		}
+
+		sb.WriteString(fmt.Sprintf("• `%s` - %s\n", config.Name, formatBytes(t, summary.Month.Total())))
+
+		button := tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("📊 %s", config.Name),
+			fmt.Sprintf("usage_%d", config.ID),
+		)
+		keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{button})
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, sb.String())
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("Failed to send usage: %v", err)
+	}
+}
+
+// handleUsageCallback показывает трафик конфигурации за сутки, неделю и месяц
+func (b *Bot) handleUsageCallback(query *tgbotapi.CallbackQuery, user *database.User, configID int64) {
+	t := b.tr(user)
+	config, err := b.db.GetConfigByID(configID)
+	if err != nil || config.UserID != user.ID {
+		b.answerCallbackQuery(query.ID, t.T("common.config_not_found"))
+		return
+	}
+
+	summary, err := b.db.GetUsageSummary(config.ID, time.Now())
+	if err != nil {
+		log.Printf("Failed to get usage for config %d: %v", config.ID, err)
+		b.answerCallbackQuery(query.ID, t.T("common.error_short"))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(t.T("usage.config_title", config.Name))
+	for _, period := range []struct {
+		title string
+		usage database.Usage
+	}{
+		{t.T("usage.day"), summary.Day},
+		{t.T("usage.week"), summary.Week},
+		{t.T("usage.month"), summary.Month},
+	} {
+		sb.WriteString(fmt.Sprintf("*%s:* %s (⬇️ %s, ⬆️ %s)\n",
+			period.title, formatBytes(t, period.usage.Total()),
+			formatBytes(t, period.usage.BytesSent), formatBytes(t, period.usage.BytesReceived)))
+	}
+
+	b.sendMessage(query.Message.Chat.ID, sb.String())
+}
@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go-ovpn-bot/internal/config"
+)
+
+const (
+	// webhookSecretHeader - заголовок, в котором Telegram передает secret_token
+	webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+	// maxWebhookBody ограничивает размер тела запроса; обновления Telegram намного меньше
+	maxWebhookBody = 1 << 20
+	// webhookShutdownTimeout - сколько ждать завершения принятых HTTP запросов при остановке
+	webhookShutdownTimeout = 5 * time.Second
+)
+
+// updateSource поставляет обновления из Telegram: long polling или webhook
+type updateSource interface {
+	// Start начинает прием и возвращает канал обновлений
+	Start() (tgbotapi.UpdatesChannel, error)
+	// Stop прекращает прием новых обновлений
+	Stop()
+}
+
+// newUpdateSource выбирает способ получения обновлений по UPDATE_MODE
+func (b *Bot) newUpdateSource() updateSource {
+	if b.config.UpdateMode == config.UpdateModeWebhook {
+		return &webhookSource{api: b.api, config: b.config}
+	}
+	return &pollingSource{api: b.api}
+}
+
+// pollingSource получает обновления через getUpdates
+type pollingSource struct {
+	api *tgbotapi.BotAPI
+}
+
+func (s *pollingSource) Start() (tgbotapi.UpdatesChannel, error) {
+	// getUpdates не работает, пока установлен webhook, например после смены UPDATE_MODE
+	if _, err := s.api.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		return nil, fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 60
+	return s.api.GetUpdatesChan(u), nil
+}
+
+func (s *pollingSource) Stop() {
+	// Обновления, полученные после отмены, не подтверждены и придут снова после перезапуска
+	s.api.StopReceivingUpdates()
+}
+
+// webhookSource принимает обновления HTTP сервером, адрес которого зарегистрирован в Telegram
+type webhookSource struct {
+	api     *tgbotapi.BotAPI
+	config  *config.Config
+	server  *http.Server
+	handler *webhookHandler
+}
+
+func (s *webhookSource) Start() (tgbotapi.UpdatesChannel, error) {
+	// Порт занимается до setWebhook, чтобы Telegram не слал обновления в пустоту
+	listener, err := net.Listen("tcp", s.config.WebhookListen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", s.config.WebhookListen, err)
+	}
+
+	// Канал без буфера: Telegram получает ответ, только когда обновление принято
+	// циклом обработки, поэтому при остановке подтвержденные обновления не теряются
+	updates := make(chan tgbotapi.Update)
+	s.handler = newWebhookHandler(s.config.WebhookSecret, updates)
+
+	mux := http.NewServeMux()
+	mux.Handle(s.config.WebhookPath, s.handler)
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		var err error
+		if s.config.WebhookCert != "" {
+			err = s.server.ServeTLS(listener, s.config.WebhookCert, s.config.WebhookKey)
+		} else {
+			err = s.server.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Webhook server stopped: %v", err)
+		}
+	}()
+
+	if err := s.setWebhook(); err != nil {
+		s.server.Close()
+		return nil, err
+	}
+
+	log.Printf("Listening for webhook updates on %s%s", s.config.WebhookListen, s.config.WebhookPath)
+	return updates, nil
+}
+
+// setWebhook регистрирует адрес webhook в Telegram. WebhookConfig библиотеки
+// не поддерживает secret_token, поэтому параметры собираются вручную
+func (s *webhookSource) setWebhook() error {
+	params := tgbotapi.Params{}
+	params["url"] = strings.TrimRight(s.config.WebhookURL, "/") + s.config.WebhookPath
+	params["secret_token"] = s.config.WebhookSecret
+	if err := params.AddInterface("allowed_updates", []string{"message", "callback_query"}); err != nil {
+		return fmt.Errorf("failed to encode allowed updates: %w", err)
+	}
+
+	var err error
+	if s.config.WebhookSelfSigned {
+		files := []tgbotapi.RequestFile{{Name: "certificate", Data: tgbotapi.FilePath(s.config.WebhookCert)}}
+		_, err = s.api.UploadFiles("setWebhook", params, files)
+	} else {
+		_, err = s.api.MakeRequest("setWebhook", params)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set webhook: %w", err)
+	}
+	return nil
+}
+
+func (s *webhookSource) Stop() {
+	// Webhook остается зарегистрированным: пока бот остановлен, Telegram копит обновления
+	// и доставит их после перезапуска
+	s.handler.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		log.Printf("Failed to shut down webhook server: %v", err)
+	}
+}
+
+// webhookHandler проверяет запросы Telegram и передает обновления в тот же канал,
+// из которого их читает общий цикл обработки
+type webhookHandler struct {
+	secret  string
+	updates chan<- tgbotapi.Update
+	done    chan struct{}
+}
+
+func newWebhookHandler(secret string, updates chan<- tgbotapi.Update) *webhookHandler {
+	return &webhookHandler{
+		secret:  secret,
+		updates: updates,
+		done:    make(chan struct{}),
+	}
+}
+
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.Header.Get(webhookSecretHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.secret)) != 1 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var update tgbotapi.Update
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBody)).Decode(&update); err != nil {
+		log.Printf("Failed to decode webhook update: %v", err)
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	// Если бот останавливается, Telegram получит ошибку и повторит доставку позже
+	select {
+	case h.updates <- update:
+		w.WriteHeader(http.StatusOK)
+	case <-h.done:
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+	}
+}
+
+// Close отклоняет запросы, ожидающие цикла обработки, чтобы остановка сервера не зависла
+func (h *webhookHandler) Close() {
+	close(h.done)
+}
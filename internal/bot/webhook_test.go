@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const testWebhookSecret = "s3cret"
+
+// webhookRequest отправляет запрос обработчику и возвращает код ответа
+func webhookRequest(h http.Handler, method, secret, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/webhook", strings.NewReader(body))
+	if secret != "" {
+		req.Header.Set(webhookSecretHeader, secret)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWebhookHandlerRejects(t *testing.T) {
+	const update = `{"update_id": 1}`
+
+	tests := []struct {
+		name   string
+		method string
+		secret string
+		body   string
+		status int
+	}{
+		{"get", http.MethodGet, testWebhookSecret, "", http.StatusMethodNotAllowed},
+		{"put", http.MethodPut, testWebhookSecret, update, http.StatusMethodNotAllowed},
+		{"missing secret", http.MethodPost, "", update, http.StatusForbidden},
+		{"wrong secret", http.MethodPost, "wrong", update, http.StatusForbidden},
+		{"secret prefix", http.MethodPost, testWebhookSecret[:3], update, http.StatusForbidden},
+		{"malformed body", http.MethodPost, testWebhookSecret, `{"update_id":`, http.StatusBadRequest},
+		{"wrong type", http.MethodPost, testWebhookSecret, `{"update_id": "one"}`, http.StatusBadRequest},
+		{"too large", http.MethodPost, testWebhookSecret, `{"x": "` + strings.Repeat("a", maxWebhookBody) + `"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Канал без читателя: отклоненный запрос не должен до него дойти
+			h := newWebhookHandler(testWebhookSecret, make(chan tgbotapi.Update))
+
+			rec := webhookRequest(h, tt.method, tt.secret, tt.body)
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusMethodNotAllowed && rec.Header().Get("Allow") != http.MethodPost {
+				t.Errorf("Allow = %q, want %q", rec.Header().Get("Allow"), http.MethodPost)
+			}
+		})
+	}
+}
+
+func TestWebhookHandlerDeliversUpdate(t *testing.T) {
+	updates := make(chan tgbotapi.Update)
+	h := newWebhookHandler(testWebhookSecret, updates)
+
+	received := make(chan tgbotapi.Update, 1)
+	go func() { received <- <-updates }()
+
+	body := `{"update_id": 42, "message": {"message_id": 7, "date": 0, "chat": {"id": 100, "type": "private"}, "text": "/start"}}`
+	rec := webhookRequest(h, http.MethodPost, testWebhookSecret, body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	select {
+	case update := <-received:
+		if update.UpdateID != 42 || update.Message == nil || update.Message.Text != "/start" || update.Message.Chat.ID != 100 {
+			t.Errorf("update = %+v", update)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("update was not delivered")
+	}
+}
+
+func TestWebhookHandlerClosed(t *testing.T) {
+	h := newWebhookHandler(testWebhookSecret, make(chan tgbotapi.Update))
+	h.Close()
+
+	// Telegram повторит доставку обновления, которое не принял остановленный бот
+	rec := webhookRequest(h, http.MethodPost, testWebhookSecret, `{"update_id": 1}`)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
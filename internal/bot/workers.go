@@ -0,0 +1,123 @@
+package bot
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxPendingPerUser - сколько необработанных обновлений одного пользователя может
+// ждать в очереди. Остальные отбрасываются, чтобы один пользователь не занял очередь
+// воркера, общую с другими
+const maxPendingPerUser = 10
+
+// workerPool обрабатывает обновления параллельно. Обновления одного пользователя
+// всегда попадают в очередь одного воркера и обрабатываются в порядке поступления,
+// поэтому шаги диалога и нажатия кнопок не обгоняют друг друга
+type workerPool struct {
+	queues []chan tgbotapi.Update
+	handle func(tgbotapi.Update)
+	wg     sync.WaitGroup
+
+	mu sync.Mutex
+	// pending - число принятых и еще не обработанных обновлений по ID отправителя
+	pending map[int64]int
+	perUser int
+	dropped atomic.Uint64
+}
+
+// newWorkerPool запускает workers воркеров с очередями по queueSize обновлений
+func newWorkerPool(workers, queueSize int, handle func(tgbotapi.Update)) *workerPool {
+	p := &workerPool{
+		queues:  make([]chan tgbotapi.Update, workers),
+		handle:  handle,
+		pending: make(map[int64]int),
+		perUser: min(maxPendingPerUser, queueSize),
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan tgbotapi.Update, queueSize)
+		p.wg.Add(1)
+		go p.run(p.queues[i])
+	}
+	return p
+}
+
+func (p *workerPool) run(queue <-chan tgbotapi.Update) {
+	defer p.wg.Done()
+	for update := range queue {
+		p.handle(update)
+		p.done(senderID(update))
+	}
+}
+
+// Submit ставит обновление в очередь воркера его отправителя и не ждет: если у
+// отправителя уже maxPendingPerUser необработанных обновлений или очередь воркера
+// заполнена, обновление отбрасывается и Submit возвращает false. Так прием обновлений
+// не останавливается из-за одного пользователя
+func (p *workerPool) Submit(update tgbotapi.Update) bool {
+	userID := senderID(update)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pending[userID] < p.perUser {
+		select {
+		case p.queues[p.shard(userID)] <- update:
+			p.pending[userID]++
+			return true
+		default:
+		}
+	}
+
+	// Не пишем в лог каждое отброшенное обновление, иначе лог заполнит тот же флуд
+	if dropped := p.dropped.Add(1); dropped == 1 || dropped%100 == 0 {
+		log.Printf("Dropped update from user %d: queue is full (%d dropped in total)", userID, dropped)
+	}
+	return false
+}
+
+// Dropped возвращает число обновлений, отброшенных Submit
+func (p *workerPool) Dropped() uint64 {
+	return p.dropped.Load()
+}
+
+// Stop закрывает очереди и ждет, пока воркеры обработают уже принятые обновления.
+// После Stop вызывать Submit нельзя
+func (p *workerPool) Stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.wg.Wait()
+
+	if dropped := p.Dropped(); dropped > 0 {
+		log.Printf("Dropped %d updates because of full queues", dropped)
+	}
+}
+
+// done отмечает обновление отправителя обработанным
+func (p *workerPool) done(userID int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.pending[userID] <= 1 {
+		delete(p.pending, userID)
+		return
+	}
+	p.pending[userID]--
+}
+
+// shard выбирает воркера по ID отправителя
+func (p *workerPool) shard(userID int64) int {
+	return int(uint64(userID) % uint64(len(p.queues)))
+}
+
+// senderID возвращает ID отправителя обновления; у обновлений без отправителя он равен 0,
+// и они идут первому воркеру
+func senderID(update tgbotapi.Update) int64 {
+	if from := update.SentFrom(); from != nil {
+		return from.ID
+	}
+	return 0
+}
@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// testUpdate - сообщение пользователя userID с номером id
+func testUpdate(userID int64, id int) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: id,
+		Message:  &tgbotapi.Message{From: &tgbotapi.User{ID: userID}},
+	}
+}
+
+func TestWorkerPoolKeepsUserOrder(t *testing.T) {
+	var mu sync.Mutex
+	handled := make(map[int64][]int)
+	pool := newWorkerPool(3, 100, func(update tgbotapi.Update) {
+		mu.Lock()
+		defer mu.Unlock()
+		userID := update.SentFrom().ID
+		handled[userID] = append(handled[userID], update.UpdateID)
+	})
+
+	// Обновления пользователей чередуются, и каждое отправляется, когда у пользователя
+	// меньше maxPendingPerUser необработанных
+	const perUser = 50
+	for i := 0; i < perUser; i++ {
+		for userID := int64(1); userID <= 4; userID++ {
+			for !pool.Submit(testUpdate(userID, i)) {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+	pool.Stop()
+
+	for userID := int64(1); userID <= 4; userID++ {
+		ids := handled[userID]
+		if len(ids) != perUser {
+			t.Fatalf("user %d: handled %d updates, want %d", userID, len(ids), perUser)
+		}
+		for i, id := range ids {
+			if id != i {
+				t.Fatalf("user %d: updates handled out of order: %v", userID, ids)
+			}
+		}
+	}
+}
+
+func TestWorkerPoolSlowUserDoesNotBlockOthers(t *testing.T) {
+	const slowUser, sameShardUser, otherUser = 1, 3, 2
+
+	release := make(chan struct{})
+	handled := make(chan int64, 100)
+	pool := newWorkerPool(2, 100, func(update tgbotapi.Update) {
+		userID := update.SentFrom().ID
+		if userID == slowUser {
+			<-release
+		}
+		handled <- userID
+	})
+	defer pool.Stop()
+
+	// Медленный пользователь занимает своего воркера, лишние обновления отбрасываются
+	// сразу, а не останавливают прием
+	done := make(chan int)
+	go func() {
+		accepted := 0
+		for i := 0; i < 3*maxPendingPerUser; i++ {
+			if pool.Submit(testUpdate(slowUser, i)) {
+				accepted++
+			}
+		}
+		done <- accepted
+	}()
+	select {
+	case accepted := <-done:
+		if accepted != maxPendingPerUser {
+			t.Errorf("accepted %d updates of slow user, want %d", accepted, maxPendingPerUser)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Submit blocked on slow user")
+	}
+	if dropped := pool.Dropped(); dropped != 2*maxPendingPerUser {
+		t.Errorf("Dropped = %d, want %d", dropped, 2*maxPendingPerUser)
+	}
+
+	// Другой воркер свободен, а очередь общего воркера не занята целиком
+	if !pool.Submit(testUpdate(otherUser, 0)) {
+		t.Fatal("update of other user dropped")
+	}
+	if !pool.Submit(testUpdate(sameShardUser, 0)) {
+		t.Fatal("update of user on the same worker dropped")
+	}
+	select {
+	case userID := <-handled:
+		if userID != otherUser {
+			t.Fatalf("handled update of user %d, want %d", userID, otherUser)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("update of other user is not handled while slow user is busy")
+	}
+
+	close(release)
+}
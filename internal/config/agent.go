@@ -0,0 +1,61 @@
+package config
+
+import (
+	"github.com/joho/godotenv"
+)
+
+// AgentConfig - конфигурация агента (cmd/agent), который выпускает конфигурации
+// на своем хосте по запросам бота
+type AgentConfig struct {
+	Listen string
+	// Сертификат агента и CA, которым подписаны сертификаты ботов
+	TLSCert  string
+	TLSKey   string
+	ClientCA string
+	Debug    bool
+	// Server - локальный сервер, которым управляет агент
+	Server Server
+}
+
+// LoadAgent загружает конфигурацию агента из окружения
+func LoadAgent() (*AgentConfig, error) {
+	// Загружаем .env файл если он существует
+	if err := godotenv.Load(); err != nil {
+		// Игнорируем ошибку если файл не найден
+	}
+
+	cfg := &AgentConfig{
+		Listen:   getEnv("AGENT_LISTEN", ":8443"),
+		TLSCert:  getEnv("AGENT_TLS_CERT", ""),
+		TLSKey:   getEnv("AGENT_TLS_KEY", ""),
+		ClientCA: getEnv("AGENT_CLIENT_CA", ""),
+		Debug:    getBoolEnv("DEBUG", false),
+		Server: Server{
+			Name:               getEnv("AGENT_SERVER_NAME", DefaultServerName),
+			Backend:            getEnv("OVPN_BACKEND", BackendScript),
+			ScriptsPath:        getEnv("SCRIPTS_PATH", "./scripts"),
+			ConfigsPath:        getEnv("CONFIGS_PATH", "./.ovpn"),
+			ConfigPrefix:       getEnv("CONFIG_PREFIX", ""),
+			OpenVPNDir:         getEnv("OPENVPN_DIR", "/etc/openvpn"),
+			PKIPath:            getEnv("PKI_PATH", "/etc/openvpn/easy-rsa/pki"),
+			ClientTemplatePath: getEnv("CLIENT_TEMPLATE_PATH", ""),
+			RemoteHost:         getEnv("REMOTE_HOST", ""),
+			StatusPath:         getEnv("STATUS_PATH", "/var/log/openvpn/status.log"),
+			ManagementAddr:     getEnv("MANAGEMENT_ADDR", ""),
+			ManagementPassword: getEnv("MANAGEMENT_PASSWORD", ""),
+			CCDPath:            getEnv("CCD_PATH", "/etc/openvpn/ccd"),
+		},
+	}
+
+	if cfg.TLSCert == "" || cfg.TLSKey == "" {
+		return nil, &ConfigError{Field: "AGENT_TLS_CERT", Message: "Agent TLS certificate and key are required"}
+	}
+	if cfg.ClientCA == "" {
+		return nil, &ConfigError{Field: "AGENT_CLIENT_CA", Message: "Client CA is required for mutual TLS"}
+	}
+	if cfg.Server.Backend == BackendAgent || !validBackend(cfg.Server.Backend) {
+		return nil, &ConfigError{Field: "OVPN_BACKEND", Message: "OVPN_BACKEND must be \"script\", \"native\" or \"memory\" for agent"}
+	}
+
+	return cfg, nil
+}
@@ -1,22 +1,109 @@
 package config
 
 import (
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"go-ovpn-bot/internal/generator"
+	"go-ovpn-bot/internal/i18n"
 )
 
 type Config struct {
-	BotToken      string
-	DatabasePath  string
-	ScriptsPath   string
-	ConfigsPath   string
-	ConfigPrefix  string
-	Debug         bool
+	BotToken     string
+	DatabasePath string
+	ScriptsPath  string
+	ConfigsPath  string
+	ConfigPrefix string
+	Debug        bool
+	// Бэкенд выпуска сертификатов: "script" (add.sh/remove.sh), "native" (Go PKI)
+	// или "memory" (пробный запуск без OpenVPN)
+	Backend    string
+	OpenVPNDir string
+	PKIPath    string
+	// Шаблон клиентского профиля (text/template) и адрес сервера для профилей.
+	// Используются только бэкендом native: add.sh собирает профиль сам
+	ClientTemplatePath string
+	RemoteHost         string
+	// Путь к status.log OpenVPN для команды /status
+	StatusPath string
+	// Management интерфейс OpenVPN: "host:port" или "unix:/path"
+	ManagementAddr     string
+	ManagementPassword string
+	// Директория client-config-dir, в которой блокируются клиенты сверх квоты трафика
+	CCDPath string
+	// Интервал сбора статистики трафика
+	UsageInterval time.Duration
+	// Интервал проверки сроков действия конфигураций и заблаговременность уведомления
+	ExpiryCheckInterval time.Duration
+	ExpiryNotice        time.Duration
+	// Сколько бот ждет ответа пользователя в многошаговом диалоге (например, кода после /code)
+	ConversationTimeout time.Duration
+	// Число воркеров, параллельно обрабатывающих обновления, и размер очереди каждого
+	Workers         int
+	UpdateQueueSize int
+	// Сколько при остановке ждать завершения начатой обработки (например, выпуска сертификата)
+	ShutdownTimeout time.Duration
+	// Ограничение частоты запросов одного пользователя: в минуту (0 - без ограничения)
+	// и сколько запросов можно отправить подряд
+	RateLimit      int
+	RateLimitBurst int
+	// Реестр VPN серверов: JSON файл или один сервер из переменных выше
+	ServersFile string
+	Servers     []Server
+	// Сертификат бота и CA агентов для серверов с бэкендом "agent"
+	AgentClientCert string
+	AgentClientKey  string
+	AgentCA         string
+	// Telegram ID администраторов, которым доступны команды управления ботом
+	AdminIDs []int64
+	// Формат кодов активации: длина, алфавит и размер групп (XXXX-XXXX), 0 - без групп
+	CodeLength    int
+	CodeAlphabet  string
+	CodeGroupSize int
+	// Защита от перебора кодов: число неудачных попыток за окно до блокировки,
+	// первая и максимальная длительность блокировки (удваивается с каждой новой)
+	CodeMaxFailures   int
+	CodeFailureWindow time.Duration
+	CodeLockout       time.Duration
+	CodeLockoutMax    time.Duration
+	// Порог неудачных попыток всех пользователей за окно, после которого ввод кодов
+	// временно закрыт для всех, а администраторы получают предупреждение
+	CodeGlobalMaxFailures int
+	CodeGlobalWindow      time.Duration
+	// Способ получения обновлений: "polling" (long polling) или "webhook"
+	UpdateMode string
+	// Webhook: публичный URL, по которому Telegram присылает обновления, адрес для
+	// прослушивания и секретный путь обработчика
+	WebhookURL    string
+	WebhookListen string
+	WebhookPath   string
+	// Сертификат и ключ TLS; без них HTTPS завершается на reverse proxy.
+	// Самоподписанный сертификат дополнительно загружается в Telegram
+	WebhookCert       string
+	WebhookKey        string
+	WebhookSelfSigned bool
+	// Секрет, который Telegram передает в заголовке X-Telegram-Bot-Api-Secret-Token
+	WebhookSecret string
+	// Язык сообщений, если пользователь не выбрал язык и его язык в Telegram не поддерживается
+	DefaultLanguage string
 }
 
+const (
+	BackendScript = "script"
+	BackendNative = "native"
+	BackendMemory = "memory"
+	BackendAgent  = "agent"
+)
+
+const (
+	UpdateModePolling = "polling"
+	UpdateModeWebhook = "webhook"
+)
+
 func Load() (*Config, error) {
 	// Загружаем .env файл если он существует
 	if err := godotenv.Load(); err != nil {
@@ -24,21 +111,189 @@ func Load() (*Config, error) {
 	}
 
 	cfg := &Config{
-		BotToken:     getEnv("BOT_TOKEN", ""),
-		DatabasePath: getEnv("DATABASE_PATH", "./data/bot.db"),
-		ScriptsPath:  getEnv("SCRIPTS_PATH", "./scripts"),
-		ConfigsPath:  getEnv("CONFIGS_PATH", "./.ovpn"),
-		ConfigPrefix: getEnv("CONFIG_PREFIX", ""),
-		Debug:        getBoolEnv("DEBUG", false),
+		BotToken:              getEnv("BOT_TOKEN", ""),
+		DatabasePath:          getEnv("DATABASE_PATH", "./data/bot.db"),
+		ScriptsPath:           getEnv("SCRIPTS_PATH", "./scripts"),
+		ConfigsPath:           getEnv("CONFIGS_PATH", "./.ovpn"),
+		ConfigPrefix:          getEnv("CONFIG_PREFIX", ""),
+		Debug:                 getBoolEnv("DEBUG", false),
+		Backend:               getEnv("OVPN_BACKEND", BackendScript),
+		OpenVPNDir:            getEnv("OPENVPN_DIR", "/etc/openvpn"),
+		PKIPath:               getEnv("PKI_PATH", "/etc/openvpn/easy-rsa/pki"),
+		ClientTemplatePath:    getEnv("CLIENT_TEMPLATE_PATH", ""),
+		RemoteHost:            getEnv("REMOTE_HOST", ""),
+		StatusPath:            getEnv("STATUS_PATH", "/var/log/openvpn/status.log"),
+		ManagementAddr:        getEnv("MANAGEMENT_ADDR", ""),
+		ManagementPassword:    getEnv("MANAGEMENT_PASSWORD", ""),
+		CCDPath:               getEnv("CCD_PATH", "/etc/openvpn/ccd"),
+		UsageInterval:         getDurationEnv("USAGE_INTERVAL", 5*time.Minute),
+		ExpiryCheckInterval:   getDurationEnv("EXPIRY_CHECK_INTERVAL", time.Hour),
+		ExpiryNotice:          getDurationEnv("EXPIRY_NOTICE", 72*time.Hour),
+		ConversationTimeout:   getDurationEnv("CONVERSATION_TIMEOUT", 10*time.Minute),
+		Workers:               getIntEnv("WORKERS", 8),
+		UpdateQueueSize:       getIntEnv("UPDATE_QUEUE_SIZE", 100),
+		ShutdownTimeout:       getDurationEnv("SHUTDOWN_TIMEOUT", 30*time.Second),
+		RateLimit:             getIntEnv("RATE_LIMIT", 30),
+		RateLimitBurst:        getIntEnv("RATE_LIMIT_BURST", 10),
+		ServersFile:           getEnv("SERVERS_FILE", ""),
+		AgentClientCert:       getEnv("AGENT_CLIENT_CERT", ""),
+		AgentClientKey:        getEnv("AGENT_CLIENT_KEY", ""),
+		AgentCA:               getEnv("AGENT_CA", ""),
+		CodeLength:            getIntEnv("CODE_LENGTH", 10),
+		CodeAlphabet:          codeAlphabet(getEnv("CODE_ALPHABET", "alphanumeric")),
+		CodeGroupSize:         getIntEnv("CODE_GROUP_SIZE", 0),
+		CodeMaxFailures:       getIntEnv("CODE_MAX_FAILURES", 5),
+		CodeFailureWindow:     getDurationEnv("CODE_FAILURE_WINDOW", time.Hour),
+		CodeLockout:           getDurationEnv("CODE_LOCKOUT", 15*time.Minute),
+		CodeLockoutMax:        getDurationEnv("CODE_LOCKOUT_MAX", 24*time.Hour),
+		CodeGlobalMaxFailures: getIntEnv("CODE_GLOBAL_MAX_FAILURES", 50),
+		CodeGlobalWindow:      getDurationEnv("CODE_GLOBAL_WINDOW", 10*time.Minute),
+		UpdateMode:            getEnv("UPDATE_MODE", UpdateModePolling),
+		WebhookURL:            getEnv("WEBHOOK_URL", ""),
+		WebhookListen:         getEnv("WEBHOOK_LISTEN", ":8080"),
+		WebhookPath:           getEnv("WEBHOOK_PATH", ""),
+		WebhookCert:           getEnv("WEBHOOK_CERT", ""),
+		WebhookKey:            getEnv("WEBHOOK_KEY", ""),
+		WebhookSelfSigned:     getBoolEnv("WEBHOOK_SELF_SIGNED", false),
+		WebhookSecret:         getEnv("WEBHOOK_SECRET", ""),
+		DefaultLanguage:       strings.ToLower(getEnv("DEFAULT_LANGUAGE", i18n.DefaultLanguage)),
 	}
 
 	if cfg.BotToken == "" {
 		return nil, &ConfigError{Field: "BOT_TOKEN", Message: "Bot token is required"}
 	}
 
+	if !validBackend(cfg.Backend) {
+		return nil, &ConfigError{Field: "OVPN_BACKEND", Message: "OVPN_BACKEND must be \"script\", \"native\", \"memory\" or \"agent\""}
+	}
+
+	servers, err := loadServers(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Servers = servers
+
+	adminIDs, err := getInt64ListEnv("ADMIN_IDS")
+	if err != nil {
+		return nil, &ConfigError{Field: "ADMIN_IDS", Message: "ADMIN_IDS must be a comma-separated list of Telegram IDs"}
+	}
+	cfg.AdminIDs = adminIDs
+
+	if cfg.UsageInterval <= 0 {
+		return nil, &ConfigError{Field: "USAGE_INTERVAL", Message: "USAGE_INTERVAL must be positive"}
+	}
+
+	if cfg.ExpiryCheckInterval <= 0 {
+		return nil, &ConfigError{Field: "EXPIRY_CHECK_INTERVAL", Message: "EXPIRY_CHECK_INTERVAL must be positive"}
+	}
+
+	if cfg.Workers <= 0 || cfg.UpdateQueueSize <= 0 {
+		return nil, &ConfigError{Field: "WORKERS", Message: "WORKERS and UPDATE_QUEUE_SIZE must be positive"}
+	}
+
+	if cfg.ShutdownTimeout <= 0 {
+		return nil, &ConfigError{Field: "SHUTDOWN_TIMEOUT", Message: "SHUTDOWN_TIMEOUT must be positive"}
+	}
+
+	if cfg.RateLimit < 0 || cfg.RateLimit > 0 && cfg.RateLimitBurst <= 0 {
+		return nil, &ConfigError{Field: "RATE_LIMIT", Message: "RATE_LIMIT must not be negative and RATE_LIMIT_BURST must be positive"}
+	}
+
+	if cfg.ConversationTimeout <= 0 {
+		return nil, &ConfigError{Field: "CONVERSATION_TIMEOUT", Message: "CONVERSATION_TIMEOUT must be positive"}
+	}
+
+	if cfg.CodeMaxFailures <= 0 || cfg.CodeGlobalMaxFailures <= 0 {
+		return nil, &ConfigError{Field: "CODE_MAX_FAILURES", Message: "CODE_MAX_FAILURES and CODE_GLOBAL_MAX_FAILURES must be positive"}
+	}
+
+	if cfg.CodeFailureWindow <= 0 || cfg.CodeGlobalWindow <= 0 || cfg.CodeLockout <= 0 || cfg.CodeLockoutMax < cfg.CodeLockout {
+		return nil, &ConfigError{Field: "CODE_LOCKOUT", Message: "Code lockout windows must be positive and CODE_LOCKOUT_MAX must not be less than CODE_LOCKOUT"}
+	}
+
+	if _, err := generator.New(cfg.CodeOptions()); err != nil {
+		return nil, &ConfigError{Field: "CODE_ALPHABET", Message: "Invalid activation code format: " + err.Error()}
+	}
+
+	if err := validateUpdateMode(cfg); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
 
+// validateUpdateMode проверяет способ получения обновлений и настройки webhook
+func validateUpdateMode(cfg *Config) error {
+	switch cfg.UpdateMode {
+	case UpdateModePolling:
+		return nil
+	case UpdateModeWebhook:
+	default:
+		return &ConfigError{Field: "UPDATE_MODE", Message: "UPDATE_MODE must be \"polling\" or \"webhook\""}
+	}
+
+	if u, err := url.Parse(cfg.WebhookURL); err != nil || u.Scheme != "https" || u.Host == "" {
+		return &ConfigError{Field: "WEBHOOK_URL", Message: "WEBHOOK_URL must be an https:// URL in webhook mode"}
+	}
+	if cfg.WebhookListen == "" {
+		return &ConfigError{Field: "WEBHOOK_LISTEN", Message: "WEBHOOK_LISTEN is required in webhook mode"}
+	}
+	if !strings.HasPrefix(cfg.WebhookPath, "/") || len(cfg.WebhookPath) < 2 {
+		return &ConfigError{Field: "WEBHOOK_PATH", Message: "WEBHOOK_PATH must start with / and should contain a hard to guess part"}
+	}
+	if (cfg.WebhookCert == "") != (cfg.WebhookKey == "") {
+		return &ConfigError{Field: "WEBHOOK_CERT", Message: "WEBHOOK_CERT and WEBHOOK_KEY must be set together"}
+	}
+	if cfg.WebhookSelfSigned && cfg.WebhookCert == "" {
+		return &ConfigError{Field: "WEBHOOK_SELF_SIGNED", Message: "WEBHOOK_SELF_SIGNED requires WEBHOOK_CERT"}
+	}
+	if !validWebhookSecret(cfg.WebhookSecret) {
+		return &ConfigError{Field: "WEBHOOK_SECRET", Message: "WEBHOOK_SECRET must be 1-256 characters A-Z, a-z, 0-9, _ and -"}
+	}
+	return nil
+}
+
+// validWebhookSecret проверяет секрет по правилам Telegram для secret_token
+func validWebhookSecret(secret string) bool {
+	if len(secret) == 0 || len(secret) > 256 {
+		return false
+	}
+	for _, c := range secret {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// CodeOptions возвращает параметры генератора кодов активации
+func (c *Config) CodeOptions() generator.Options {
+	return generator.Options{
+		Length:    c.CodeLength,
+		Alphabet:  c.CodeAlphabet,
+		GroupSize: c.CodeGroupSize,
+	}
+}
+
+// codeAlphabet раскрывает названия встроенных алфавитов, остальные значения используются как есть
+func codeAlphabet(value string) string {
+	switch strings.ToLower(value) {
+	case "alphanumeric":
+		return generator.Alphanumeric
+	case "readable":
+		return generator.Readable
+	}
+	return value
+}
+
+func validBackend(backend string) bool {
+	switch backend {
+	case BackendScript, BackendNative, BackendMemory, BackendAgent:
+		return true
+	}
+	return false
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -55,6 +310,50 @@ func getBoolEnv(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+func getIntEnv(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getInt64ListEnv разбирает список чисел, разделенных запятыми
+func getInt64ListEnv(key string) ([]int64, error) {
+	var values []int64
+	for _, field := range strings.Split(os.Getenv(key), ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		value, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// IsAdmin проверяет, входит ли пользователь Telegram в список администраторов
+func (c *Config) IsAdmin(telegramID int64) bool {
+	for _, id := range c.AdminIDs {
+		if id == telegramID {
+			return true
+		}
+	}
+	return false
+}
 
 type ConfigError struct {
 	Field   string
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Server описывает VPN сервер (локацию), на котором бот выпускает конфигурации
+type Server struct {
+	Name   string `json:"name"`
+	Region string `json:"region"`
+	// Capacity - максимальное число конфигураций на сервере, 0 - без ограничений
+	Capacity int `json:"capacity"`
+
+	Backend            string `json:"backend"`
+	ScriptsPath        string `json:"scripts_path"`
+	ConfigsPath        string `json:"configs_path"`
+	ConfigPrefix       string `json:"config_prefix"`
+	OpenVPNDir         string `json:"openvpn_dir"`
+	PKIPath            string `json:"pki_path"`
+	ClientTemplatePath string `json:"client_template_path"`
+	RemoteHost         string `json:"remote_host"`
+	StatusPath         string `json:"status_path"`
+	ManagementAddr     string `json:"management_addr"`
+	ManagementPassword string `json:"management_password"`
+	CCDPath            string `json:"ccd_path"`
+
+	// Параметры удаленного агента (бэкенд "agent")
+	AgentURL  string `json:"agent_url"`
+	AgentCert string `json:"agent_cert"`
+	AgentKey  string `json:"agent_key"`
+	AgentCA   string `json:"agent_ca"`
+}
+
+// DefaultServerName - имя сервера, если SERVERS_FILE не задан
+const DefaultServerName = "default"
+
+// loadServers читает реестр серверов из JSON файла. Незаданные поля
+// наследуются из глобальных переменных окружения
+func loadServers(cfg *Config) ([]Server, error) {
+	defaults := Server{
+		Backend:            cfg.Backend,
+		ScriptsPath:        cfg.ScriptsPath,
+		ConfigsPath:        cfg.ConfigsPath,
+		ConfigPrefix:       cfg.ConfigPrefix,
+		OpenVPNDir:         cfg.OpenVPNDir,
+		PKIPath:            cfg.PKIPath,
+		ClientTemplatePath: cfg.ClientTemplatePath,
+		RemoteHost:         cfg.RemoteHost,
+		StatusPath:         cfg.StatusPath,
+		ManagementAddr:     cfg.ManagementAddr,
+		ManagementPassword: cfg.ManagementPassword,
+		CCDPath:            cfg.CCDPath,
+		AgentCert:          cfg.AgentClientCert,
+		AgentKey:           cfg.AgentClientKey,
+		AgentCA:            cfg.AgentCA,
+	}
+
+	if cfg.ServersFile == "" {
+		server := defaults
+		server.Name = DefaultServerName
+		return []Server{server}, nil
+	}
+
+	data, err := os.ReadFile(cfg.ServersFile)
+	if err != nil {
+		return nil, &ConfigError{Field: "SERVERS_FILE", Message: fmt.Sprintf("Failed to read servers file: %v", err)}
+	}
+
+	var servers []Server
+	if err := json.Unmarshal(data, &servers); err != nil {
+		return nil, &ConfigError{Field: "SERVERS_FILE", Message: fmt.Sprintf("Failed to parse servers file: %v", err)}
+	}
+	if len(servers) == 0 {
+		return nil, &ConfigError{Field: "SERVERS_FILE", Message: "Servers file must contain at least one server"}
+	}
+
+	seen := make(map[string]bool)
+	for i := range servers {
+		s := &servers[i]
+		if s.Name == "" {
+			return nil, &ConfigError{Field: "SERVERS_FILE", Message: fmt.Sprintf("Server #%d has no name", i+1)}
+		}
+		if seen[s.Name] {
+			return nil, &ConfigError{Field: "SERVERS_FILE", Message: fmt.Sprintf("Duplicate server name %q", s.Name)}
+		}
+		seen[s.Name] = true
+
+		inherit(&s.Backend, defaults.Backend)
+		inherit(&s.ScriptsPath, defaults.ScriptsPath)
+		inherit(&s.ConfigsPath, defaults.ConfigsPath)
+		inherit(&s.ConfigPrefix, defaults.ConfigPrefix)
+		inherit(&s.OpenVPNDir, defaults.OpenVPNDir)
+		inherit(&s.PKIPath, defaults.PKIPath)
+		inherit(&s.ClientTemplatePath, defaults.ClientTemplatePath)
+		inherit(&s.RemoteHost, defaults.RemoteHost)
+		inherit(&s.StatusPath, defaults.StatusPath)
+		inherit(&s.ManagementAddr, defaults.ManagementAddr)
+		inherit(&s.ManagementPassword, defaults.ManagementPassword)
+		inherit(&s.CCDPath, defaults.CCDPath)
+		inherit(&s.AgentCert, defaults.AgentCert)
+		inherit(&s.AgentKey, defaults.AgentKey)
+		inherit(&s.AgentCA, defaults.AgentCA)
+
+		if !validBackend(s.Backend) {
+			return nil, &ConfigError{Field: "SERVERS_FILE", Message: fmt.Sprintf("Server %q has unknown backend %q", s.Name, s.Backend)}
+		}
+		if s.Backend == BackendAgent && s.AgentURL == "" {
+			return nil, &ConfigError{Field: "SERVERS_FILE", Message: fmt.Sprintf("Server %q uses agent backend without agent_url", s.Name)}
+		}
+	}
+
+	return servers, nil
+}
+
+func inherit(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
@@ -0,0 +1,273 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UserSummary - пользователь с количеством конфигураций для списков
+type UserSummary struct {
+	User
+	ConfigCount int `json:"config_count"`
+}
+
+// ListUsers возвращает всех пользователей без загрузки конфигураций
+func (db *DB) ListUsers() ([]UserSummary, error) {
+	return db.listUsers(-1, 0)
+}
+
+// ListUsersPage возвращает страницу пользователей и общее число пользователей
+func (db *DB) ListUsersPage(offset, limit int) ([]UserSummary, int, error) {
+	var total int
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM users").Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	users, err := db.listUsers(limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// listUsers выбирает пользователей по порядку ID, limit -1 - без ограничения
+func (db *DB) listUsers(limit, offset int) ([]UserSummary, error) {
+	rows, err := db.conn.Query(
+		`SELECT `+prefixColumns("u", userColumns)+`, COUNT(c.id)
+		FROM users u LEFT JOIN configs c ON c.user_id = u.id
+		GROUP BY u.id ORDER BY u.id LIMIT ? OFFSET ?`,
+		limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []UserSummary
+	for rows.Next() {
+		var summary UserSummary
+		var configCount int
+		user, err := scanUser(scanTail(rows, &configCount))
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		summary.User = user
+		summary.ConfigCount = configCount
+		users = append(users, summary)
+	}
+
+	return users, rows.Err()
+}
+
+// SetUserBanned блокирует или разблокирует пользователя
+func (db *DB) SetUserBanned(userID int64, banned bool) error {
+	if _, err := db.conn.Exec("UPDATE users SET banned = ? WHERE id = ?", banned, userID); err != nil {
+		return fmt.Errorf("failed to update user ban: %w", err)
+	}
+	return nil
+}
+
+// UserRedemption - активация кода пользователем
+type UserRedemption struct {
+	Code       string    `json:"code"`
+	Batch      string    `json:"batch"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+// GetUserRedemptions возвращает коды, активированные пользователем
+func (db *DB) GetUserRedemptions(userID int64) ([]UserRedemption, error) {
+	rows, err := db.conn.Query(
+		`SELECT a.code, a.batch, r.redeemed_at
+		FROM redemptions r JOIN activation_codes a ON a.id = r.code_id
+		WHERE r.user_id = ? ORDER BY r.redeemed_at`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query redemptions: %w", err)
+	}
+	defer rows.Close()
+
+	var redemptions []UserRedemption
+	for rows.Next() {
+		var r UserRedemption
+		if err := rows.Scan(&r.Code, &r.Batch, &r.RedeemedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan redemption: %w", err)
+		}
+		redemptions = append(redemptions, r)
+	}
+
+	return redemptions, rows.Err()
+}
+
+// CodeFilter - условия выборки кодов активации; пустые поля не учитываются
+type CodeFilter struct {
+	Status string
+	Batch  string
+}
+
+// ListActivationCodes возвращает коды активации по фильтру
+func (db *DB) ListActivationCodes(filter CodeFilter) ([]ActivationCode, error) {
+	query := "SELECT " + codeColumns + " FROM activation_codes WHERE 1 = 1"
+	var args []interface{}
+	if filter.Status != "" {
+		query += " AND status = ?"
+		args = append(args, filter.Status)
+	}
+	if filter.Batch != "" {
+		query += " AND batch = ?"
+		args = append(args, filter.Batch)
+	}
+	query += " ORDER BY id"
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query activation codes: %w", err)
+	}
+	defer rows.Close()
+
+	var codes []ActivationCode
+	for rows.Next() {
+		code, err := scanActivationCode(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan activation code: %w", err)
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, rows.Err()
+}
+
+// RevokeActivationCodes отзывает активные коды по фильтру и возвращает их количество.
+// Использованные коды не меняются, чтобы сохранить историю
+func (db *DB) RevokeActivationCodes(codes []string, batch string) (int64, error) {
+	if len(codes) == 0 && batch == "" {
+		return 0, fmt.Errorf("no codes to revoke")
+	}
+
+	query := "UPDATE activation_codes SET status = ? WHERE status = ?"
+	args := []interface{}{CodeStatusRevoked, CodeStatusActive}
+	if batch != "" {
+		query += " AND batch = ?"
+		args = append(args, batch)
+	}
+	if len(codes) > 0 {
+		query += " AND code IN (?" + strings.Repeat(", ?", len(codes)-1) + ")"
+		for _, code := range codes {
+			args = append(args, code)
+		}
+	}
+
+	result, err := db.conn.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke activation codes: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ReassignConfig передает конфигурацию другому пользователю
+func (db *DB) ReassignConfig(configID, userID int64) error {
+	result, err := db.conn.Exec("UPDATE configs SET user_id = ? WHERE id = ?", userID, configID)
+	if err != nil {
+		return fmt.Errorf("failed to reassign config: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("config not found")
+	}
+	return nil
+}
+
+// Stats - сводная статистика для администратора
+type Stats struct {
+	Users           int            `json:"users"`
+	BannedUsers     int            `json:"banned_users"`
+	Configs         int            `json:"configs"`
+	BlockedConfigs  int            `json:"blocked_configs"`
+	ConfigsByServer map[string]int `json:"configs_by_server"`
+	CodesByStatus   map[string]int `json:"codes_by_status"`
+	Redemptions     int            `json:"redemptions"`
+	MonthlyUsage    Usage          `json:"monthly_usage"`
+}
+
+// GetStats собирает статистику по пользователям, конфигурациям, кодам и трафику за месяц
+func (db *DB) GetStats(now time.Time) (*Stats, error) {
+	stats := &Stats{
+		ConfigsByServer: make(map[string]int),
+		CodesByStatus:   make(map[string]int),
+	}
+
+	if err := db.conn.QueryRow(
+		"SELECT COUNT(*), COALESCE(SUM(banned), 0) FROM users",
+	).Scan(&stats.Users, &stats.BannedUsers); err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+	if err := db.conn.QueryRow(
+		"SELECT COUNT(*), COALESCE(SUM(blocked), 0) FROM configs",
+	).Scan(&stats.Configs, &stats.BlockedConfigs); err != nil {
+		return nil, fmt.Errorf("failed to count configs: %w", err)
+	}
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM redemptions").Scan(&stats.Redemptions); err != nil {
+		return nil, fmt.Errorf("failed to count redemptions: %w", err)
+	}
+	if err := db.conn.QueryRow(
+		"SELECT COALESCE(SUM(bytes_received), 0), COALESCE(SUM(bytes_sent), 0) FROM usage_monthly WHERE month = ?",
+		QuotaPeriod(now),
+	).Scan(&stats.MonthlyUsage.BytesReceived, &stats.MonthlyUsage.BytesSent); err != nil {
+		return nil, fmt.Errorf("failed to query monthly usage: %w", err)
+	}
+
+	for _, group := range []struct {
+		query  string
+		counts map[string]int
+	}{
+		{"SELECT server, COUNT(*) FROM configs GROUP BY server", stats.ConfigsByServer},
+		{"SELECT status, COUNT(*) FROM activation_codes GROUP BY status", stats.CodesByStatus},
+	} {
+		if err := db.countGroups(group.query, group.counts); err != nil {
+			return nil, err
+		}
+	}
+
+	return stats, nil
+}
+
+func (db *DB) countGroups(query string, counts map[string]int) error {
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return fmt.Errorf("failed to query stats: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var count int
+		if err := rows.Scan(&key, &count); err != nil {
+			return fmt.Errorf("failed to scan stats: %w", err)
+		}
+		counts[key] = count
+	}
+	return rows.Err()
+}
+
+// prefixColumns добавляет псевдоним таблицы к списку колонок ("id, name" -> "u.id, u.name")
+func prefixColumns(alias, columns string) string {
+	parts := strings.Split(columns, ", ")
+	for i, part := range parts {
+		parts[i] = alias + "." + part
+	}
+	return strings.Join(parts, ", ")
+}
+
+// tailScanner дописывает дополнительные колонки после колонок основной сущности
+type tailScanner struct {
+	row  rowScanner
+	tail []interface{}
+}
+
+func scanTail(row rowScanner, tail ...interface{}) rowScanner {
+	return tailScanner{row: row, tail: tail}
+}
+
+func (s tailScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.tail...)...)
+}
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Действия журнала аудита
+const (
+	// AuditCodeFailed - введен несуществующий код или код в неверном формате
+	AuditCodeFailed = "code_failed"
+	// AuditCodeRejected - существующий код не принят (использован, отозван, истек)
+	AuditCodeRejected = "code_rejected"
+	AuditCodeRedeemed = "code_redeemed"
+	// AuditCodeLockout - ввод кодов заблокирован после серии неудачных попыток
+	AuditCodeLockout = "code_lockout"
+	// AuditConfigDownload - пользователь повторно получил профиль конфигурации (файлом или QR-кодом)
+	AuditConfigDownload = "config_download"
+)
+
+// AuditEntry - запись журнала аудита
+type AuditEntry struct {
+	ID int64 `json:"id"`
+	// UserID - пользователь, 0 - действие без пользователя
+	UserID     int64     `json:"user_id"`
+	TelegramID int64     `json:"telegram_id"`
+	Action     string    `json:"action"`
+	Detail     string    `json:"detail"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AuditFilter - условия выборки журнала аудита, пустые поля не ограничивают выборку
+type AuditFilter struct {
+	Action string
+	UserID int64
+	Since  time.Time
+	// Limit - максимальное число последних записей, 0 - без ограничения
+	Limit int
+}
+
+// AddAuditEntry добавляет запись в журнал аудита
+func (db *DB) AddAuditEntry(userID int64, action, detail string, at time.Time) error {
+	var user interface{}
+	if userID != 0 {
+		user = userID
+	}
+
+	_, err := db.conn.Exec(
+		"INSERT INTO audit_log (user_id, action, detail, created_at) VALUES (?, ?, ?, ?)",
+		user, action, detail, at.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add audit entry: %w", err)
+	}
+	return nil
+}
+
+// CountAuditEntries считает записи журнала по фильтру
+func (db *DB) CountAuditEntries(filter AuditFilter) (int, error) {
+	where, args := filter.conditions()
+
+	var count int
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM audit_log a"+where, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+	return count, nil
+}
+
+// ListAuditEntries возвращает записи журнала по фильтру, новые первыми
+func (db *DB) ListAuditEntries(filter AuditFilter) ([]AuditEntry, error) {
+	where, args := filter.conditions()
+	query := `SELECT a.id, COALESCE(a.user_id, 0), COALESCE(u.telegram_id, 0), a.action, a.detail, a.created_at
+		FROM audit_log a LEFT JOIN users u ON u.id = a.user_id` + where + ` ORDER BY a.id DESC`
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		if err := rows.Scan(&entry.ID, &entry.UserID, &entry.TelegramID, &entry.Action, &entry.Detail, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (f AuditFilter) conditions() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if f.Action != "" {
+		conditions = append(conditions, "a.action = ?")
+		args = append(args, f.Action)
+	}
+	if f.UserID != 0 {
+		conditions = append(conditions, "a.user_id = ?")
+		args = append(args, f.UserID)
+	}
+	if !f.Since.IsZero() {
+		conditions = append(conditions, "a.created_at >= ?")
+		args = append(args, f.Since.UTC())
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// CodeLockout - состояние блокировки ввода кодов активации
+type CodeLockout struct {
+	// LockedUntil - окончание последней блокировки или момент ее сброса;
+	// неудачные попытки до этого момента не учитываются. nil - блокировок не было
+	LockedUntil *time.Time
+	// Lockouts - число блокировок подряд с последней успешной активации
+	Lockouts int
+}
+
+// Locked проверяет, действует ли блокировка в момент now
+func (l CodeLockout) Locked(now time.Time) bool {
+	return l.LockedUntil != nil && l.LockedUntil.After(now)
+}
+
+// GetCodeLockout возвращает состояние блокировки ввода кодов пользователя
+func (db *DB) GetCodeLockout(userID int64) (*CodeLockout, error) {
+	var lockout CodeLockout
+	var lockedUntil sql.NullTime
+	err := db.conn.QueryRow(
+		"SELECT code_locked_until, code_lockouts FROM users WHERE id = ?",
+		userID,
+	).Scan(&lockedUntil, &lockout.Lockouts)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get code lockout: %w", err)
+	}
+
+	if lockedUntil.Valid {
+		lockout.LockedUntil = &lockedUntil.Time
+	}
+	return &lockout, nil
+}
+
+// LockCodeEntry блокирует ввод кодов до until и увеличивает счетчик блокировок
+func (db *DB) LockCodeEntry(userID int64, until time.Time) error {
+	_, err := db.conn.Exec(
+		"UPDATE users SET code_locked_until = ?, code_lockouts = code_lockouts + 1 WHERE id = ?",
+		until.UTC().Truncate(time.Second), userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to lock code entry: %w", err)
+	}
+	return nil
+}
+
+// ResetCodeLockout снимает блокировку, сбрасывает счетчик блокировок
+// и перестает учитывать неудачные попытки до now
+func (db *DB) ResetCodeLockout(userID int64, now time.Time) error {
+	_, err := db.conn.Exec(
+		"UPDATE users SET code_locked_until = ?, code_lockouts = 0 WHERE id = ?",
+		now.UTC(), userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reset code lockout: %w", err)
+	}
+	return nil
+}
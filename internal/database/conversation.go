@@ -0,0 +1,75 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Conversation - состояние многошагового диалога пользователя в чате
+type Conversation struct {
+	ChatID int64
+	UserID int64
+	// State - шаг диалога, например ожидание кода активации
+	State string
+	// Payload - данные диалога в JSON, накопленные на предыдущих шагах
+	Payload   string
+	ExpiresAt time.Time
+	UpdatedAt time.Time
+}
+
+// Expired проверяет, истекло ли время ожидания ответа
+func (c *Conversation) Expired(now time.Time) bool {
+	return !c.ExpiresAt.After(now)
+}
+
+// GetConversation возвращает диалог пользователя в чате, включая истекший, или nil, если диалога нет
+func (db *DB) GetConversation(chatID, userID int64) (*Conversation, error) {
+	var conv Conversation
+	err := db.conn.QueryRow(
+		"SELECT chat_id, user_id, state, payload, expires_at, updated_at FROM conversations WHERE chat_id = ? AND user_id = ?",
+		chatID, userID,
+	).Scan(&conv.ChatID, &conv.UserID, &conv.State, &conv.Payload, &conv.ExpiresAt, &conv.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	return &conv, nil
+}
+
+// SaveConversation создает или заменяет диалог пользователя в чате
+func (db *DB) SaveConversation(conv Conversation) error {
+	_, err := db.conn.Exec(
+		`INSERT INTO conversations (chat_id, user_id, state, payload, expires_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (chat_id, user_id) DO UPDATE SET
+			state = excluded.state,
+			payload = excluded.payload,
+			expires_at = excluded.expires_at,
+			updated_at = excluded.updated_at`,
+		conv.ChatID, conv.UserID, conv.State, conv.Payload, conv.ExpiresAt.UTC(), conv.UpdatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save conversation: %w", err)
+	}
+	return nil
+}
+
+// DeleteConversation завершает диалог пользователя в чате
+func (db *DB) DeleteConversation(chatID, userID int64) error {
+	if _, err := db.conn.Exec("DELETE FROM conversations WHERE chat_id = ? AND user_id = ?", chatID, userID); err != nil {
+		return fmt.Errorf("failed to delete conversation: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredConversations удаляет диалоги, время ожидания которых истекло
+func (db *DB) DeleteExpiredConversations(now time.Time) (int64, error) {
+	result, err := db.conn.Exec("DELETE FROM conversations WHERE expires_at <= ?", now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired conversations: %w", err)
+	}
+	return result.RowsAffected()
+}
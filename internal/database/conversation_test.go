@@ -0,0 +1,46 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConversationsArePerUser(t *testing.T) {
+	db := newTestDB(t)
+	alice := newTestUser(t, db, 1)
+	bob := newTestUser(t, db, 2)
+
+	const groupID = -100
+	now := time.Now()
+	for _, user := range []*User{alice, bob} {
+		if err := db.SaveConversation(Conversation{
+			ChatID:    groupID,
+			UserID:    user.ID,
+			State:     "awaiting_code",
+			ExpiresAt: now.Add(time.Minute),
+			UpdatedAt: now,
+		}); err != nil {
+			t.Fatalf("SaveConversation: %v", err)
+		}
+	}
+
+	if err := db.DeleteConversation(groupID, bob.ID); err != nil {
+		t.Fatalf("DeleteConversation: %v", err)
+	}
+
+	conv, err := db.GetConversation(groupID, alice.ID)
+	if err != nil {
+		t.Fatalf("GetConversation: %v", err)
+	}
+	if conv == nil || conv.UserID != alice.ID {
+		t.Fatalf("conversation of the other user was removed: %+v", conv)
+	}
+
+	conv, err = db.GetConversation(groupID, bob.ID)
+	if err != nil {
+		t.Fatalf("GetConversation: %v", err)
+	}
+	if conv != nil {
+		t.Fatalf("conversation was not deleted: %+v", conv)
+	}
+}
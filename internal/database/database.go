@@ -2,9 +2,11 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -14,147 +16,220 @@ type DB struct {
 }
 
 type User struct {
-	ID       int64  `json:"id"`
-	Username string `json:"username"`
-	Limit    int    `json:"limit"`
-	Configs  []Config `json:"configs"`
+	ID         int64  `json:"id"`
+	TelegramID int64  `json:"telegram_id"`
+	Username   string `json:"username"`
+	Limit      int    `json:"limit"`
+	// TrafficQuota - месячная квота трафика в байтах, 0 - без ограничений
+	TrafficQuota int64 `json:"traffic_quota"`
+	// ExpiresAt - окончание доступа по коду с ограниченным сроком, nil - бессрочно
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Banned - пользователь заблокирован администратором
+	Banned bool `json:"banned"`
+	// Language - язык, выбранный командой /language, пусто - язык Telegram
+	Language string `json:"language,omitempty"`
+	// TelegramLanguage - language_code из последнего обновления Telegram
+	TelegramLanguage string    `json:"telegram_language,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	Configs          []Config  `json:"configs"`
+}
+
+// ErrUserNotFound возвращается, если пользователя нет в базе данных
+var ErrUserNotFound = errors.New("user not found")
+
+// userColumns - колонки users в порядке, ожидаемом scanUser
+const userColumns = "id, telegram_id, username, limit_count, traffic_quota, expires_at, banned, language, telegram_language, created_at"
+
+// scanUser читает строку, выбранную по userColumns
+func scanUser(row rowScanner) (User, error) {
+	var user User
+	var username sql.NullString
+	var limit sql.NullInt64
+	var expiresAt sql.NullTime
+	var createdAt sql.NullTime
+	if err := row.Scan(&user.ID, &user.TelegramID, &username, &limit, &user.TrafficQuota,
+		&expiresAt, &user.Banned, &user.Language, &user.TelegramLanguage, &createdAt); err != nil {
+		return user, err
+	}
+	user.Username = username.String
+	user.Limit = int(limit.Int64)
+	if expiresAt.Valid {
+		user.ExpiresAt = &expiresAt.Time
+	}
+	user.CreatedAt = createdAt.Time
+	return user, nil
 }
 
 type Config struct {
-	ID       int64  `json:"id"`
-	UserID   int64  `json:"user_id"`
-	Name     string `json:"name"`
+	ID     int64  `json:"id"`
+	UserID int64  `json:"user_id"`
+	Server string `json:"server"`
+	Name   string `json:"name"`
+	// Label - название, которое пользователь дал конфигурации; Name остается именем клиента OpenVPN
+	Label    string `json:"label,omitempty"`
 	FilePath string `json:"file_path"`
+	// Blocked - конфигурация заблокирована из-за превышения квоты трафика
+	Blocked bool `json:"blocked"`
+	// ExpiresAt - момент автоматического отзыва конфигурации, nil - бессрочно
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// configColumns - колонки configs в порядке, ожидаемом scanConfig
+const configColumns = "id, user_id, server, name, label, file_path, blocked, expires_at, created_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanConfig читает строку, выбранную по configColumns
+func scanConfig(row rowScanner) (Config, error) {
+	var config Config
+	var expiresAt sql.NullTime
+	var createdAt sql.NullTime
+	if err := row.Scan(&config.ID, &config.UserID, &config.Server, &config.Name, &config.Label, &config.FilePath,
+		&config.Blocked, &expiresAt, &createdAt); err != nil {
+		return config, err
+	}
+	if expiresAt.Valid {
+		config.ExpiresAt = &expiresAt.Time
+	}
+	config.CreatedAt = createdAt.Time
+	return config, nil
 }
 
 type ActivationCode struct {
 	ID     int64  `json:"id"`
 	Code   string `json:"code"`
-	Status string `json:"status"` // "active", "used"
+	Status string `json:"status"` // "active", "used", "revoked"
 	Limit  int    `json:"limit"`
+	// TrafficQuota - месячная квота в байтах, добавляемая к квоте пользователя
+	TrafficQuota int64 `json:"traffic_quota"`
+	// DurationDays - срок доступа в днях, на который код продлевает подписку, 0 - бессрочно
+	DurationDays int `json:"duration_days"`
+	// ExpiresAt - после этого момента код нельзя активировать, nil - бессрочно
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// MaxUses - сколько пользователей может активировать код, 0 - без ограничений
+	MaxUses int `json:"max_uses"`
+	Uses    int `json:"uses"`
+	// Batch - партия (кампания), к которой относится код, Note - заметка администратора
+	Batch     string    `json:"batch"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CodeOptions - параметры создаваемого кода активации
+type CodeOptions struct {
+	Limit        int
+	TrafficQuota int64
+	DurationDays int
+	MaxUses      int
+	ExpiresAt    *time.Time
+	Batch        string
+	Note         string
 }
 
+// New открывает базу данных и применяет недостающие миграции
 func New(dbPath string) (*DB, error) {
+	db, err := Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return db, nil
+}
+
+// Open открывает базу данных без применения миграций (для ovpn-admin migrate)
+func Open(dbPath string) (*DB, error) {
 	// Создаем директорию для базы данных если она не существует
 	dir := filepath.Dir(dbPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
-	conn, err := sql.Open("sqlite3", dbPath)
+	// Транзакции сразу берут блокировку на запись (BEGIN IMMEDIATE), а конкурирующие
+	// запросы ждут ее освобождения вместо немедленной ошибки "database is locked"
+	conn, err := sql.Open("sqlite3", dbPath+"?_busy_timeout=5000&_txlock=immediate")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	db := &DB{conn: conn}
-	
-	if err := db.migrate(); err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to migrate database: %w", err)
-	}
-
-	return db, nil
+	return &DB{conn: conn}, nil
 }
 
 func (db *DB) Close() error {
 	return db.conn.Close()
 }
 
-func (db *DB) migrate() error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			telegram_id INTEGER UNIQUE NOT NULL,
-			username TEXT,
-			limit_count INTEGER DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS configs (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER NOT NULL,
-			name TEXT NOT NULL,
-			file_path TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
-		)`,
-		`CREATE TABLE IF NOT EXISTS activation_codes (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			code TEXT UNIQUE NOT NULL,
-			status TEXT DEFAULT 'active',
-			limit_count INTEGER NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_configs_user_id ON configs (user_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_activation_codes_code ON activation_codes (code)`,
+func (db *DB) GetOrCreateUser(telegramID int64, username string) (*User, error) {
+	// Сначала пытаемся найти пользователя
+	user, err := db.GetUserByTelegramID(telegramID)
+	if err == nil {
+		return user, nil
+	} else if !errors.Is(err, ErrUserNotFound) {
+		return nil, err
 	}
 
-	for _, query := range queries {
-		if _, err := db.conn.Exec(query); err != nil {
-			return fmt.Errorf("failed to execute migration: %w", err)
-		}
+	// Пользователь не найден, создаем нового
+	result, err := db.conn.Exec(
+		"INSERT INTO users (telegram_id, username, limit_count) VALUES (?, ?, 0)",
+		telegramID, username,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	return nil
+	userID, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user ID: %w", err)
+	}
+
+	return &User{
+		ID:         userID,
+		TelegramID: telegramID,
+		Username:   username,
+		Limit:      0,
+		CreatedAt:  time.Now().UTC(),
+		Configs:    []Config{},
+	}, nil
 }
 
-func (db *DB) GetOrCreateUser(telegramID int64, username string) (*User, error) {
-	// Сначала пытаемся найти пользователя
-	var userID int64
-	var dbUsername sql.NullString
-	var limit int
-	
-	err := db.conn.QueryRow(
-		"SELECT id, username, limit_count FROM users WHERE telegram_id = ?",
-		telegramID,
-	).Scan(&userID, &dbUsername, &limit)
-	
+// GetUserByTelegramID возвращает пользователя с его конфигурациями, не создавая нового
+func (db *DB) GetUserByTelegramID(telegramID int64) (*User, error) {
+	return db.getUser("telegram_id = ?", telegramID)
+}
+
+// GetUserByID возвращает пользователя по внутреннему ID
+func (db *DB) GetUserByID(userID int64) (*User, error) {
+	return db.getUser("id = ?", userID)
+}
+
+func (db *DB) getUser(condition string, arg interface{}) (*User, error) {
+	user, err := scanUser(db.conn.QueryRow("SELECT "+userColumns+" FROM users WHERE "+condition, arg))
 	if err == sql.ErrNoRows {
-		// Пользователь не найден, создаем нового
-		result, err := db.conn.Exec(
-			"INSERT INTO users (telegram_id, username, limit_count) VALUES (?, ?, 0)",
-			telegramID, username,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create user: %w", err)
-		}
-		
-		userID, err = result.LastInsertId()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get user ID: %w", err)
-		}
-		
-		return &User{
-			ID:       userID,
-			Username: username,
-			Limit:    0,
-			Configs:  []Config{},
-		}, nil
+		return nil, ErrUserNotFound
 	} else if err != nil {
 		return nil, fmt.Errorf("failed to query user: %w", err)
 	}
 
 	// Пользователь найден, получаем его конфиги
-	configs, err := db.GetUserConfigs(userID)
+	configs, err := db.GetUserConfigs(user.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user configs: %w", err)
 	}
+	user.Configs = configs
 
-	usernameStr := ""
-	if dbUsername.Valid {
-		usernameStr = dbUsername.String
-	}
-
-	return &User{
-		ID:       userID,
-		Username: usernameStr,
-		Limit:    limit,
-		Configs:  configs,
-	}, nil
+	return &user, nil
 }
 
 func (db *DB) GetUserConfigs(userID int64) ([]Config, error) {
 	rows, err := db.conn.Query(
-		"SELECT id, name, file_path FROM configs WHERE user_id = ? ORDER BY created_at DESC",
+		"SELECT "+configColumns+" FROM configs WHERE user_id = ? ORDER BY created_at DESC",
 		userID,
 	)
 	if err != nil {
@@ -164,21 +239,22 @@ func (db *DB) GetUserConfigs(userID int64) ([]Config, error) {
 
 	var configs []Config
 	for rows.Next() {
-		var config Config
-		if err := rows.Scan(&config.ID, &config.Name, &config.FilePath); err != nil {
+		config, err := scanConfig(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan config: %w", err)
 		}
-		config.UserID = userID
 		configs = append(configs, config)
 	}
 
 	return configs, nil
 }
 
-func (db *DB) CreateConfig(userID int64, name, filePath string) (*Config, error) {
+func (db *DB) CreateConfig(userID int64, server, name, filePath string) (*Config, error) {
+	// Конфигурация действует до окончания подписки пользователя
 	result, err := db.conn.Exec(
-		"INSERT INTO configs (user_id, name, file_path) VALUES (?, ?, ?)",
-		userID, name, filePath,
+		`INSERT INTO configs (user_id, server, name, file_path, expires_at)
+		VALUES (?, ?, ?, ?, (SELECT expires_at FROM users WHERE id = ?))`,
+		userID, server, name, filePath, userID,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create config: %w", err)
@@ -189,12 +265,7 @@ func (db *DB) CreateConfig(userID int64, name, filePath string) (*Config, error)
 		return nil, fmt.Errorf("failed to get config ID: %w", err)
 	}
 
-	return &Config{
-		ID:       configID,
-		UserID:   userID,
-		Name:     name,
-		FilePath: filePath,
-	}, nil
+	return db.GetConfigByID(configID)
 }
 
 func (db *DB) DeleteConfig(configID int64) error {
@@ -206,11 +277,10 @@ func (db *DB) DeleteConfig(configID int64) error {
 }
 
 func (db *DB) GetConfigByID(configID int64) (*Config, error) {
-	var config Config
-	err := db.conn.QueryRow(
-		"SELECT id, user_id, name, file_path FROM configs WHERE id = ?",
+	config, err := scanConfig(db.conn.QueryRow(
+		"SELECT "+configColumns+" FROM configs WHERE id = ?",
 		configID,
-	).Scan(&config.ID, &config.UserID, &config.Name, &config.FilePath)
+	))
 	
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("config not found")
@@ -221,33 +291,57 @@ func (db *DB) GetConfigByID(configID int64) (*Config, error) {
 	return &config, nil
 }
 
-// GetActivationCodeByCode получает код активации по коду
-func (db *DB) GetActivationCodeByCode(code string) (*ActivationCode, error) {
-	var activationCode ActivationCode
-	err := db.conn.QueryRow(
-		"SELECT id, code, status, limit_count FROM activation_codes WHERE code = ?",
-		code,
-	).Scan(&activationCode.ID, &activationCode.Code, &activationCode.Status, &activationCode.Limit)
-	
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("activation code not found")
-	} else if err != nil {
-		return nil, fmt.Errorf("failed to query activation code: %w", err)
+// SetConfigLabel переименовывает конфигурацию для пользователя; пустая строка убирает название
+func (db *DB) SetConfigLabel(configID int64, label string) error {
+	if _, err := db.conn.Exec("UPDATE configs SET label = ? WHERE id = ?", label, configID); err != nil {
+		return fmt.Errorf("failed to update config label: %w", err)
+	}
+	return nil
+}
+
+// ListConfigs возвращает все конфигурации
+func (db *DB) ListConfigs() ([]Config, error) {
+	rows, err := db.conn.Query("SELECT " + configColumns + " FROM configs ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query configs: %w", err)
+	}
+	defer rows.Close()
+
+	var configs []Config
+	for rows.Next() {
+		config, err := scanConfig(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan config: %w", err)
+		}
+		configs = append(configs, config)
 	}
 
-	return &activationCode, nil
+	return configs, rows.Err()
 }
 
-// UseActivationCode помечает код как использованный
-func (db *DB) UseActivationCode(codeID int64) error {
-	_, err := db.conn.Exec(
-		"UPDATE activation_codes SET status = 'used' WHERE id = ?",
-		codeID,
-	)
+// CountConfigsByServer возвращает количество конфигураций на сервере.
+// Для сервера по умолчанию учитываются и старые записи без сервера
+func (db *DB) CountConfigsByServer(server string, isDefault bool) (int, error) {
+	query := "SELECT COUNT(*) FROM configs WHERE server = ?"
+	if isDefault {
+		query += " OR server = ''"
+	}
+
+	var count int
+	if err := db.conn.QueryRow(query, server).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count configs: %w", err)
+	}
+	return count, nil
+}
+
+// ActivationCodeExists проверяет, занят ли код, для повторной генерации при коллизии
+func (db *DB) ActivationCodeExists(code string) (bool, error) {
+	var exists bool
+	err := db.conn.QueryRow("SELECT EXISTS(SELECT 1 FROM activation_codes WHERE code = ?)", code).Scan(&exists)
 	if err != nil {
-		return fmt.Errorf("failed to use activation code: %w", err)
+		return false, fmt.Errorf("failed to check activation code: %w", err)
 	}
-	return nil
+	return exists, nil
 }
 
 // UpdateUserLimit обновляет лимит пользователя
@@ -262,11 +356,34 @@ func (db *DB) UpdateUserLimit(userID int64, newLimit int) error {
 	return nil
 }
 
+// SetUserLanguage сохраняет язык, выбранный пользователем; пустая строка - язык Telegram
+func (db *DB) SetUserLanguage(userID int64, language string) error {
+	if _, err := db.conn.Exec("UPDATE users SET language = ? WHERE id = ?", language, userID); err != nil {
+		return fmt.Errorf("failed to update user language: %w", err)
+	}
+	return nil
+}
+
+// SetUserTelegramLanguage сохраняет language_code Telegram, чтобы фоновые уведомления
+// приходили на языке пользователя
+func (db *DB) SetUserTelegramLanguage(userID int64, language string) error {
+	if _, err := db.conn.Exec("UPDATE users SET telegram_language = ? WHERE id = ?", language, userID); err != nil {
+		return fmt.Errorf("failed to update user telegram language: %w", err)
+	}
+	return nil
+}
+
 // CreateActivationCode создает новый код активации
-func (db *DB) CreateActivationCode(code string, limit int) (*ActivationCode, error) {
+func (db *DB) CreateActivationCode(code string, opts CodeOptions) (*ActivationCode, error) {
+	var expiresAt interface{}
+	if opts.ExpiresAt != nil {
+		expiresAt = opts.ExpiresAt.UTC()
+	}
+
 	result, err := db.conn.Exec(
-		"INSERT INTO activation_codes (code, limit_count) VALUES (?, ?)",
-		code, limit,
+		`INSERT INTO activation_codes (code, limit_count, traffic_quota, duration_days, max_uses, expires_at, batch, note)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		code, opts.Limit, opts.TrafficQuota, opts.DurationDays, opts.MaxUses, expiresAt, opts.Batch, opts.Note,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create activation code: %w", err)
@@ -278,9 +395,15 @@ func (db *DB) CreateActivationCode(code string, limit int) (*ActivationCode, err
 	}
 
 	return &ActivationCode{
-		ID:     codeID,
-		Code:   code,
-		Status: "active",
-		Limit:  limit,
+		ID:           codeID,
+		Code:         code,
+		Status:       "active",
+		Limit:        opts.Limit,
+		TrafficQuota: opts.TrafficQuota,
+		DurationDays: opts.DurationDays,
+		ExpiresAt:    opts.ExpiresAt,
+		MaxUses:      opts.MaxUses,
+		Batch:        opts.Batch,
+		Note:         opts.Note,
 	}, nil
 }
@@ -0,0 +1,27 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// newTestDB создает базу во временной директории и применяет все миграции
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := New(filepath.Join(t.TempDir(), "bot.db"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// newTestUser регистрирует пользователя с telegramID
+func newTestUser(t *testing.T, db *DB, telegramID int64) *User {
+	t.Helper()
+	user, err := db.GetOrCreateUser(telegramID, "")
+	if err != nil {
+		t.Fatalf("GetOrCreateUser: %v", err)
+	}
+	return user
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ExpiringConfig - конфигурация с истекающим сроком и Telegram ID владельца для уведомлений
+type ExpiringConfig struct {
+	Config
+	TelegramID int64
+}
+
+// ListExpiringConfigs возвращает конфигурации, срок которых истекает до before
+// и о которых владелец еще не был предупрежден
+func (db *DB) ListExpiringConfigs(before time.Time) ([]ExpiringConfig, error) {
+	return db.listExpiryConfigs(
+		"c.expires_at IS NOT NULL AND c.expires_at <= ? AND c.expiry_notified = 0",
+		before.UTC(),
+	)
+}
+
+// ListExpiredConfigs возвращает конфигурации, срок которых истек к моменту now
+func (db *DB) ListExpiredConfigs(now time.Time) ([]ExpiringConfig, error) {
+	return db.listExpiryConfigs("c.expires_at IS NOT NULL AND c.expires_at <= ?", now.UTC())
+}
+
+func (db *DB) listExpiryConfigs(condition string, at time.Time) ([]ExpiringConfig, error) {
+	rows, err := db.conn.Query(
+		`SELECT c.id, c.user_id, c.server, c.name, c.file_path, c.blocked, c.expires_at, u.telegram_id
+		FROM configs c JOIN users u ON u.id = c.user_id
+		WHERE `+condition+` ORDER BY c.expires_at`,
+		at,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expiring configs: %w", err)
+	}
+	defer rows.Close()
+
+	var configs []ExpiringConfig
+	for rows.Next() {
+		var config ExpiringConfig
+		var expiresAt time.Time
+		if err := rows.Scan(&config.ID, &config.UserID, &config.Server, &config.Name, &config.FilePath,
+			&config.Blocked, &expiresAt, &config.TelegramID); err != nil {
+			return nil, fmt.Errorf("failed to scan config: %w", err)
+		}
+		config.ExpiresAt = &expiresAt
+		configs = append(configs, config)
+	}
+
+	return configs, rows.Err()
+}
+
+// MarkExpiryNotified запоминает, что владелец предупрежден об окончании срока конфигурации
+func (db *DB) MarkExpiryNotified(configID int64) error {
+	if _, err := db.conn.Exec("UPDATE configs SET expiry_notified = 1 WHERE id = ?", configID); err != nil {
+		return fmt.Errorf("failed to mark expiry notified: %w", err)
+	}
+	return nil
+}
+
+// extendSubscription продлевает доступ пользователя на days дней от текущего окончания
+// (или от now, если доступ уже истек) и переносит срок всех его конфигураций.
+// Бессрочный доступ (expires_at NULL при ненулевом лимите или существующих конфигурациях)
+// код со сроком не ограничивает: пользователь и конфигурации не меняются, возвращается nil.
+// Срок получает только пользователь, у которого доступа еще не было
+func extendSubscription(tx *sql.Tx, userID int64, days int, now time.Time) (*time.Time, error) {
+	var (
+		current   sql.NullTime
+		hasAccess bool
+	)
+	if err := tx.QueryRow(
+		`SELECT expires_at,
+			COALESCE(limit_count, 0) > 0 OR EXISTS (SELECT 1 FROM configs WHERE user_id = users.id)
+		FROM users WHERE id = ?`,
+		userID,
+	).Scan(&current, &hasAccess); err != nil {
+		return nil, fmt.Errorf("failed to query subscription: %w", err)
+	}
+	if !current.Valid && hasAccess {
+		return nil, nil
+	}
+
+	start := now.UTC()
+	if current.Valid && current.Time.After(start) {
+		start = current.Time.UTC()
+	}
+	expiresAt := start.AddDate(0, 0, days).Truncate(time.Second)
+
+	if _, err := tx.Exec("UPDATE users SET expires_at = ? WHERE id = ?", expiresAt, userID); err != nil {
+		return nil, fmt.Errorf("failed to update subscription: %w", err)
+	}
+	if _, err := tx.Exec(
+		"UPDATE configs SET expires_at = ?, expiry_notified = 0 WHERE user_id = ?",
+		expiresAt, userID,
+	); err != nil {
+		return nil, fmt.Errorf("failed to update config expiry: %w", err)
+	}
+
+	return &expiresAt, nil
+}
@@ -0,0 +1,393 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Migration - шаг изменения схемы. Версии идут по возрастанию без пропусков;
+// примененные миграции нельзя изменять, новые добавляются в конец списка
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+}
+
+// MigrationStatus - состояние миграции в базе данных
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// migrations - история схемы. Первые миграции повторяют схему, которая создавалась
+// до появления версий, и идемпотентны, поэтому старые bot.db обновляются без ошибок
+var migrations = []Migration{
+	{1, "initial schema", func(tx *sql.Tx) error {
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS users (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				telegram_id INTEGER UNIQUE NOT NULL,
+				username TEXT,
+				limit_count INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE IF NOT EXISTS configs (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL,
+				name TEXT NOT NULL,
+				file_path TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+			)`,
+			`CREATE TABLE IF NOT EXISTS activation_codes (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				code TEXT UNIQUE NOT NULL,
+				status TEXT DEFAULT 'active',
+				limit_count INTEGER NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_configs_user_id ON configs (user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_activation_codes_code ON activation_codes (code)`,
+		)
+	}},
+	{2, "config server", func(tx *sql.Tx) error {
+		return addColumn(tx, "configs", "server", "TEXT NOT NULL DEFAULT ''")
+	}},
+	{3, "traffic usage", func(tx *sql.Tx) error {
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS usage_counters (
+				config_id INTEGER PRIMARY KEY,
+				session_key TEXT NOT NULL,
+				bytes_received INTEGER NOT NULL DEFAULT 0,
+				bytes_sent INTEGER NOT NULL DEFAULT 0,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (config_id) REFERENCES configs (id) ON DELETE CASCADE
+			)`,
+			`CREATE TABLE IF NOT EXISTS usage_hourly (
+				config_id INTEGER NOT NULL,
+				hour DATETIME NOT NULL,
+				bytes_received INTEGER NOT NULL DEFAULT 0,
+				bytes_sent INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (config_id, hour),
+				FOREIGN KEY (config_id) REFERENCES configs (id) ON DELETE CASCADE
+			)`,
+			`CREATE TABLE IF NOT EXISTS usage_daily (
+				config_id INTEGER NOT NULL,
+				day DATE NOT NULL,
+				bytes_received INTEGER NOT NULL DEFAULT 0,
+				bytes_sent INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (config_id, day),
+				FOREIGN KEY (config_id) REFERENCES configs (id) ON DELETE CASCADE
+			)`,
+		)
+	}},
+	{4, "traffic quotas", func(tx *sql.Tx) error {
+		if err := execAll(tx,
+			`CREATE TABLE IF NOT EXISTS usage_monthly (
+				user_id INTEGER NOT NULL,
+				month TEXT NOT NULL,
+				bytes_received INTEGER NOT NULL DEFAULT 0,
+				bytes_sent INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (user_id, month),
+				FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+			)`,
+		); err != nil {
+			return err
+		}
+		return addColumns(tx, []column{
+			{"configs", "blocked", "INTEGER NOT NULL DEFAULT 0"},
+			{"users", "traffic_quota", "INTEGER NOT NULL DEFAULT 0"},
+			{"users", "quota_period", "TEXT NOT NULL DEFAULT ''"},
+			{"users", "quota_warned", "INTEGER NOT NULL DEFAULT 0"},
+			{"activation_codes", "traffic_quota", "INTEGER NOT NULL DEFAULT 0"},
+		})
+	}},
+	{5, "expiring configs", func(tx *sql.Tx) error {
+		return addColumns(tx, []column{
+			{"activation_codes", "duration_days", "INTEGER NOT NULL DEFAULT 0"},
+			{"users", "expires_at", "DATETIME"},
+			{"configs", "expires_at", "DATETIME"},
+			{"configs", "expiry_notified", "INTEGER NOT NULL DEFAULT 0"},
+		})
+	}},
+	{6, "activation code expiry", func(tx *sql.Tx) error {
+		return addColumn(tx, "activation_codes", "expires_at", "DATETIME")
+	}},
+	{7, "multi-use codes and redemptions", func(tx *sql.Tx) error {
+		if err := addColumns(tx, []column{
+			{"activation_codes", "max_uses", "INTEGER NOT NULL DEFAULT 1"},
+			{"activation_codes", "uses", "INTEGER NOT NULL DEFAULT 0"},
+			{"activation_codes", "batch", "TEXT NOT NULL DEFAULT ''"},
+			{"activation_codes", "note", "TEXT NOT NULL DEFAULT ''"},
+		}); err != nil {
+			return err
+		}
+		return execAll(tx,
+			`UPDATE activation_codes SET uses = 1 WHERE status = 'used'`,
+			`CREATE TABLE IF NOT EXISTS redemptions (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				code_id INTEGER NOT NULL,
+				user_id INTEGER NOT NULL,
+				redeemed_at DATETIME NOT NULL,
+				UNIQUE (code_id, user_id),
+				FOREIGN KEY (code_id) REFERENCES activation_codes (id) ON DELETE CASCADE,
+				FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_redemptions_user_id ON redemptions (user_id)`,
+			`CREATE INDEX IF NOT EXISTS idx_activation_codes_batch ON activation_codes (batch)`,
+		)
+	}},
+	{8, "user ban", func(tx *sql.Tx) error {
+		return addColumn(tx, "users", "banned", "INTEGER NOT NULL DEFAULT 0")
+	}},
+	{9, "audit log and code lockouts", func(tx *sql.Tx) error {
+		if err := addColumns(tx, []column{
+			{"users", "code_locked_until", "DATETIME"},
+			{"users", "code_lockouts", "INTEGER NOT NULL DEFAULT 0"},
+		}); err != nil {
+			return err
+		}
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS audit_log (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER,
+				action TEXT NOT NULL,
+				detail TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log (action, created_at)`,
+			`CREATE INDEX IF NOT EXISTS idx_audit_log_user_id ON audit_log (user_id, action, created_at)`,
+		)
+	}},
+	{10, "conversations", func(tx *sql.Tx) error {
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS conversations (
+				chat_id INTEGER PRIMARY KEY,
+				user_id INTEGER NOT NULL,
+				state TEXT NOT NULL,
+				payload TEXT NOT NULL DEFAULT '',
+				expires_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL,
+				FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+			)`,
+		)
+	}},
+	{11, "user language", func(tx *sql.Tx) error {
+		return addColumns(tx, []column{
+			{"users", "language", "TEXT NOT NULL DEFAULT ''"},
+			{"users", "telegram_language", "TEXT NOT NULL DEFAULT ''"},
+		})
+	}},
+	{12, "config label", func(tx *sql.Tx) error {
+		return addColumns(tx, []column{
+			{"configs", "label", "TEXT NOT NULL DEFAULT ''"},
+		})
+	}},
+	// В групповом чате у каждого участника свой диалог, поэтому ключ - чат и пользователь
+	{13, "conversations per user", func(tx *sql.Tx) error {
+		return execAll(tx,
+			`CREATE TABLE conversations_new (
+				chat_id INTEGER NOT NULL,
+				user_id INTEGER NOT NULL,
+				state TEXT NOT NULL,
+				payload TEXT NOT NULL DEFAULT '',
+				expires_at DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL,
+				PRIMARY KEY (chat_id, user_id),
+				FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+			)`,
+			`INSERT INTO conversations_new SELECT chat_id, user_id, state, payload, expires_at, updated_at FROM conversations`,
+			`DROP TABLE conversations`,
+			`ALTER TABLE conversations_new RENAME TO conversations`,
+		)
+	}},
+}
+
+// Migrations возвращает все известные миграции по возрастанию версии
+func Migrations() []Migration {
+	return migrations
+}
+
+// Migrate применяет все недостающие миграции и возвращает их количество
+func (db *DB) Migrate() (int, error) {
+	return db.MigrateTo(0)
+}
+
+// MigrateTo применяет недостающие миграции до версии target включительно
+// (0 - до последней). Каждая миграция выполняется в отдельной транзакции
+func (db *DB) MigrateTo(target int) (int, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if target > 0 && m.Version > target {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := db.applyMigration(m); err != nil {
+			return count, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// MigrationStatus возвращает состояние всех миграций
+func (db *DB) MigrationStatus() ([]MigrationStatus, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if appliedAt, ok := applied[m.Version]; ok {
+			appliedAt := appliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// SchemaVersion возвращает версию последней примененной миграции
+func (db *DB) SchemaVersion() (int, error) {
+	if err := db.ensureMigrationsTable(); err != nil {
+		return 0, err
+	}
+
+	var version int
+	if err := db.conn.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to query schema version: %w", err)
+	}
+	return version, nil
+}
+
+func (db *DB) ensureMigrationsTable() error {
+	if _, err := db.conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) appliedMigrations() (map[int]time.Time, error) {
+	if err := db.ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.conn.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan migration: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func (db *DB) applyMigration(m Migration) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := m.Up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		m.Version, m.Name, time.Now().UTC().Truncate(time.Second),
+	); err != nil {
+		return fmt.Errorf("failed to record migration: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+func execAll(tx *sql.Tx, queries ...string) error {
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute migration: %w", err)
+		}
+	}
+	return nil
+}
+
+type column struct {
+	table, name, definition string
+}
+
+func addColumns(tx *sql.Tx, columns []column) error {
+	for _, c := range columns {
+		if err := addColumn(tx, c.table, c.name, c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumn добавляет колонку, если ее еще нет: базы, созданные до версионирования
+// схемы, уже могут содержать часть колонок
+func addColumn(tx *sql.Tx, table, name, definition string) error {
+	exists, err := columnExists(tx, table, name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, name, definition)
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, name, err)
+	}
+	return nil
+}
+
+func columnExists(tx *sql.Tx, table, name string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			colName   string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &colName, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return false, fmt.Errorf("failed to scan table info: %w", err)
+		}
+		if colName == name {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
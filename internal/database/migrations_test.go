@@ -0,0 +1,168 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// baselineSchema - схема, которую создавали версии бота до появления миграций
+var baselineSchema = []string{
+	`CREATE TABLE users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		telegram_id INTEGER UNIQUE NOT NULL,
+		username TEXT,
+		limit_count INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE configs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		file_path TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+	)`,
+	`CREATE TABLE activation_codes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		code TEXT UNIQUE NOT NULL,
+		status TEXT DEFAULT 'active',
+		limit_count INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX idx_configs_user_id ON configs (user_id)`,
+	`CREATE INDEX idx_activation_codes_code ON activation_codes (code)`,
+	`INSERT INTO users (id, telegram_id, username, limit_count) VALUES (1, 100, 'alice', 3)`,
+	`INSERT INTO configs (user_id, name, file_path) VALUES (1, 'client_a', '/etc/openvpn/client/client_a.ovpn')`,
+	`INSERT INTO activation_codes (code, status, limit_count) VALUES ('USED-CODE', 'used', 2), ('FREE-CODE', 'active', 1)`,
+}
+
+// openTestDB открывает базу во временной директории без применения миграций
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "bot.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// migrateTwice применяет все миграции, затем проверяет, что повторный запуск ничего не меняет
+func migrateTwice(t *testing.T, db *DB, want int) {
+	t.Helper()
+	if applied, err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	} else if applied != want {
+		t.Errorf("Migrate applied %d migrations, want %d", applied, want)
+	}
+	if applied, err := db.Migrate(); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	} else if applied != 0 {
+		t.Errorf("second Migrate applied %d migrations, want 0", applied)
+	}
+
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus: %v", err)
+	}
+	if len(statuses) != len(migrations) {
+		t.Fatalf("MigrationStatus returned %d migrations, want %d", len(statuses), len(migrations))
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("migration %d (%s) is not applied", status.Version, status.Name)
+		}
+	}
+
+	version, err := db.SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion: %v", err)
+	}
+	if last := migrations[len(migrations)-1].Version; version != last {
+		t.Errorf("SchemaVersion = %d, want %d", version, last)
+	}
+}
+
+func TestMigrationVersions(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Fatalf("migration %q has version %d, want %d", m.Name, m.Version, i+1)
+		}
+	}
+}
+
+func TestMigrateBaselineDatabase(t *testing.T) {
+	db := openTestDB(t)
+	for _, query := range baselineSchema {
+		if _, err := db.conn.Exec(query); err != nil {
+			t.Fatalf("failed to create baseline schema: %v", err)
+		}
+	}
+
+	migrateTwice(t, db, len(migrations))
+
+	// Колонки, добавленные миграциями
+	columns := []column{
+		{table: "users", name: "traffic_quota"},
+		{table: "users", name: "expires_at"},
+		{table: "users", name: "banned"},
+		{table: "users", name: "language"},
+		{table: "configs", name: "server"},
+		{table: "configs", name: "blocked"},
+		{table: "configs", name: "label"},
+		{table: "activation_codes", name: "duration_days"},
+		{table: "activation_codes", name: "max_uses"},
+		{table: "activation_codes", name: "uses"},
+	}
+	tx, err := db.conn.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %v", err)
+	}
+	for _, c := range columns {
+		if exists, err := columnExists(tx, c.table, c.name); err != nil {
+			tx.Rollback()
+			t.Fatalf("columnExists: %v", err)
+		} else if !exists {
+			t.Errorf("column %s.%s is missing", c.table, c.name)
+		}
+	}
+	tx.Rollback()
+
+	// Данные старой базы сохранились
+	user, err := db.GetUserByTelegramID(100)
+	if err != nil {
+		t.Fatalf("GetUserByTelegramID: %v", err)
+	}
+	if user.Username != "alice" || user.Limit != 3 {
+		t.Errorf("user = %+v", user)
+	}
+	if len(user.Configs) != 1 || user.Configs[0].Name != "client_a" || user.Configs[0].Label != "" {
+		t.Errorf("configs = %+v", user.Configs)
+	}
+
+	// Использованный код считается исчерпанным, свободный можно активировать
+	used, err := getActivationCode(db.conn, "USED-CODE")
+	if err != nil {
+		t.Fatalf("getActivationCode: %v", err)
+	}
+	if used.Uses != 1 || used.MaxUses != 1 {
+		t.Errorf("used code = %+v", used)
+	}
+	if _, err := db.RedeemCode(user.ID, "FREE-CODE"); err != nil {
+		t.Errorf("RedeemCode: %v", err)
+	}
+}
+
+func TestMigrateUnversionedDatabase(t *testing.T) {
+	// Базы, обновленные до появления версий, уже содержат часть колонок,
+	// но не содержат schema_migrations
+	db := openTestDB(t)
+	if _, err := db.MigrateTo(5); err != nil {
+		t.Fatalf("MigrateTo: %v", err)
+	}
+	if _, err := db.conn.Exec("DROP TABLE schema_migrations"); err != nil {
+		t.Fatalf("failed to drop schema_migrations: %v", err)
+	}
+
+	migrateTwice(t, db, len(migrations))
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Уровни предупреждений о расходе квоты трафика (в процентах)
+const (
+	QuotaWarnLevel  = 80
+	QuotaBlockLevel = 100
+)
+
+// QuotaState - состояние месячной квоты трафика пользователя
+type QuotaState struct {
+	UserID     int64
+	TelegramID int64
+	Quota      int64
+	// Period - месяц (YYYY-MM), к которому относится Warned
+	Period string
+	// Warned - последний уровень предупреждения в периоде (0, 80 или 100)
+	Warned int
+}
+
+// QuotaPeriod возвращает идентификатор месячного периода квоты
+func QuotaPeriod(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// ListQuotaStates возвращает пользователей с заданной квотой трафика, а также
+// пользователей без квоты с заблокированными конфигурациями: квоту могли снять,
+// и блокировку нужно убрать
+func (db *DB) ListQuotaStates() ([]QuotaState, error) {
+	rows, err := db.conn.Query(
+		`SELECT id, telegram_id, traffic_quota, quota_period, quota_warned FROM users
+		WHERE traffic_quota > 0
+			OR EXISTS (SELECT 1 FROM configs c WHERE c.user_id = users.id AND c.blocked = 1)`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quotas: %w", err)
+	}
+	defer rows.Close()
+
+	var states []QuotaState
+	for rows.Next() {
+		var state QuotaState
+		if err := rows.Scan(&state.UserID, &state.TelegramID, &state.Quota, &state.Period, &state.Warned); err != nil {
+			return nil, fmt.Errorf("failed to scan quota: %w", err)
+		}
+		states = append(states, state)
+	}
+
+	return states, rows.Err()
+}
+
+// GetQuotaState возвращает состояние квоты пользователя
+func (db *DB) GetQuotaState(userID int64) (*QuotaState, error) {
+	var state QuotaState
+	err := db.conn.QueryRow(
+		"SELECT id, telegram_id, traffic_quota, quota_period, quota_warned FROM users WHERE id = ?",
+		userID,
+	).Scan(&state.UserID, &state.TelegramID, &state.Quota, &state.Period, &state.Warned)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to query quota: %w", err)
+	}
+	return &state, nil
+}
+
+// GetUserMonthlyUsage возвращает трафик всех конфигураций пользователя за календарный месяц,
+// включая уже удаленные конфигурации
+func (db *DB) GetUserMonthlyUsage(userID int64, now time.Time) (Usage, error) {
+	var usage Usage
+	err := db.conn.QueryRow(
+		"SELECT bytes_received, bytes_sent FROM usage_monthly WHERE user_id = ? AND month = ?",
+		userID, QuotaPeriod(now),
+	).Scan(&usage.BytesReceived, &usage.BytesSent)
+	if err == sql.ErrNoRows {
+		return usage, nil
+	}
+	if err != nil {
+		return usage, fmt.Errorf("failed to query monthly usage: %w", err)
+	}
+	return usage, nil
+}
+
+// SetQuotaWarning запоминает уровень предупреждения в периоде
+func (db *DB) SetQuotaWarning(userID int64, period string, level int) error {
+	if _, err := db.conn.Exec(
+		"UPDATE users SET quota_period = ?, quota_warned = ? WHERE id = ?",
+		period, level, userID,
+	); err != nil {
+		return fmt.Errorf("failed to update quota warning: %w", err)
+	}
+	return nil
+}
+
+// SetUserTrafficQuota задает месячную квоту пользователя в байтах (0 - без ограничений)
+// и сбрасывает предупреждения, чтобы они начались заново для новой квоты
+func (db *DB) SetUserTrafficQuota(userID int64, quota int64) error {
+	if _, err := db.conn.Exec(
+		"UPDATE users SET traffic_quota = ?, quota_warned = 0 WHERE id = ?",
+		quota, userID,
+	); err != nil {
+		return fmt.Errorf("failed to update traffic quota: %w", err)
+	}
+	return nil
+}
+
+// SetConfigBlocked блокирует или разблокирует конфигурацию
+func (db *DB) SetConfigBlocked(configID int64, blocked bool) error {
+	if _, err := db.conn.Exec(
+		"UPDATE configs SET blocked = ? WHERE id = ?",
+		blocked, configID,
+	); err != nil {
+		return fmt.Errorf("failed to update config block: %w", err)
+	}
+	return nil
+}
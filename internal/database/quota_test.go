@@ -0,0 +1,78 @@
+package database
+
+import "testing"
+
+// quotaStates возвращает состояния квот, проиндексированные по ID пользователя
+func quotaStates(t *testing.T, db *DB) map[int64]QuotaState {
+	t.Helper()
+	states, err := db.ListQuotaStates()
+	if err != nil {
+		t.Fatalf("ListQuotaStates: %v", err)
+	}
+	byUser := make(map[int64]QuotaState, len(states))
+	for _, state := range states {
+		byUser[state.UserID] = state
+	}
+	return byUser
+}
+
+func TestSetUserTrafficQuota(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, 1001)
+
+	if _, ok := quotaStates(t, db)[user.ID]; ok {
+		t.Fatalf("user without quota listed")
+	}
+
+	if err := db.SetUserTrafficQuota(user.ID, 10<<30); err != nil {
+		t.Fatalf("SetUserTrafficQuota: %v", err)
+	}
+	if err := db.SetQuotaWarning(user.ID, "2026-10", 80); err != nil {
+		t.Fatalf("SetQuotaWarning: %v", err)
+	}
+	state, ok := quotaStates(t, db)[user.ID]
+	if !ok || state.Quota != 10<<30 || state.Warned != 80 {
+		t.Fatalf("state = %+v, listed %v", state, ok)
+	}
+
+	// новая квота заменяет старую, а не прибавляется к ней, и сбрасывает предупреждения
+	if err := db.SetUserTrafficQuota(user.ID, 5<<30); err != nil {
+		t.Fatalf("SetUserTrafficQuota: %v", err)
+	}
+	state = quotaStates(t, db)[user.ID]
+	if state.Quota != 5<<30 || state.Warned != 0 {
+		t.Fatalf("state = %+v", state)
+	}
+
+	if err := db.SetUserTrafficQuota(user.ID, 0); err != nil {
+		t.Fatalf("SetUserTrafficQuota: %v", err)
+	}
+	if _, ok := quotaStates(t, db)[user.ID]; ok {
+		t.Fatalf("user with cleared quota and no blocked configs listed")
+	}
+}
+
+func TestListQuotaStatesIncludesBlockedWithoutQuota(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, 1001)
+	cfg, err := db.CreateConfig(user.ID, "main", "client1", "/tmp/client1.ovpn")
+	if err != nil {
+		t.Fatalf("CreateConfig: %v", err)
+	}
+	if err := db.SetConfigBlocked(cfg.ID, true); err != nil {
+		t.Fatalf("SetConfigBlocked: %v", err)
+	}
+
+	// квоту сняли, но конфигурация осталась заблокированной: бот должен ее увидеть и разблокировать
+	state, ok := quotaStates(t, db)[user.ID]
+	if !ok || state.Quota != 0 {
+		t.Fatalf("state = %+v, listed %v", state, ok)
+	}
+
+	if err := db.SetConfigBlocked(cfg.ID, false); err != nil {
+		t.Fatalf("SetConfigBlocked: %v", err)
+	}
+	if _, ok := quotaStates(t, db)[user.ID]; ok {
+		t.Fatalf("user listed after unblock")
+	}
+}
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Ошибки активации кода
+var (
+	ErrCodeNotFound = errors.New("activation code not found")
+	ErrCodeUsed     = errors.New("activation code already used")
+	ErrCodeExpired  = errors.New("activation code expired")
+	// ErrCodeRedeemed - пользователь уже активировал этот многоразовый код
+	ErrCodeRedeemed = errors.New("activation code already redeemed by user")
+	ErrCodeRevoked  = errors.New("activation code revoked")
+)
+
+// Статусы кодов активации
+const (
+	CodeStatusActive  = "active"
+	CodeStatusUsed    = "used"
+	CodeStatusRevoked = "revoked"
+)
+
+// Redemption - результат активации кода: сам код и новые параметры пользователя
+type Redemption struct {
+	Code ActivationCode
+	// Limit - новый лимит конфигураций пользователя
+	Limit int
+	// TrafficQuota - новая месячная квота пользователя в байтах
+	TrafficQuota int64
+	// ExpiresAt - новое окончание доступа, nil - бессрочно
+	ExpiresAt *time.Time
+}
+
+// codeColumns - колонки activation_codes в порядке, ожидаемом getActivationCode
+const codeColumns = "id, code, status, limit_count, traffic_quota, duration_days, expires_at, max_uses, uses, batch, note, created_at"
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func getActivationCode(q queryRower, code string) (*ActivationCode, error) {
+	activationCode, err := scanActivationCode(q.QueryRow(
+		"SELECT "+codeColumns+" FROM activation_codes WHERE code = ?",
+		code,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrCodeNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to query activation code: %w", err)
+	}
+
+	return &activationCode, nil
+}
+
+// scanActivationCode читает строку, выбранную по codeColumns
+func scanActivationCode(row rowScanner) (ActivationCode, error) {
+	var activationCode ActivationCode
+	var expiresAt sql.NullTime
+	var createdAt sql.NullTime
+	if err := row.Scan(&activationCode.ID, &activationCode.Code, &activationCode.Status, &activationCode.Limit,
+		&activationCode.TrafficQuota, &activationCode.DurationDays, &expiresAt,
+		&activationCode.MaxUses, &activationCode.Uses, &activationCode.Batch, &activationCode.Note,
+		&createdAt); err != nil {
+		return activationCode, err
+	}
+	if expiresAt.Valid {
+		activationCode.ExpiresAt = &expiresAt.Time
+	}
+	activationCode.CreatedAt = createdAt.Time
+	return activationCode, nil
+}
+
+// RedeemCode активирует код для пользователя в одной транзакции: счетчик активаций
+// увеличивается условным UPDATE, поэтому при одновременных попытках код не засчитывается
+// сверх max_uses, а один пользователь не может активировать его дважды.
+// Возвращает ErrCodeNotFound, ErrCodeUsed, ErrCodeExpired, ErrCodeRedeemed или ErrCodeRevoked
+func (db *DB) RedeemCode(userID int64, code string) (*Redemption, error) {
+	now := time.Now().UTC()
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Код исчерпан, когда число активаций достигает max_uses
+	result, err := tx.Exec(
+		`UPDATE activation_codes SET
+			uses = uses + 1,
+			status = CASE WHEN max_uses > 0 AND uses + 1 >= max_uses THEN 'used' ELSE status END
+		WHERE code = ? AND status = 'active'
+			AND (max_uses = 0 OR uses < max_uses)
+			AND (expires_at IS NULL OR expires_at > ?)
+			AND NOT EXISTS (SELECT 1 FROM redemptions r WHERE r.code_id = activation_codes.id AND r.user_id = ?)`,
+		code, now, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use activation code: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to use activation code: %w", err)
+	}
+
+	activationCode, err := getActivationCode(tx, code)
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		var redeemed bool
+		if err := tx.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM redemptions WHERE code_id = ? AND user_id = ?)",
+			activationCode.ID, userID,
+		).Scan(&redeemed); err != nil {
+			return nil, fmt.Errorf("failed to query redemptions: %w", err)
+		}
+
+		switch {
+		case redeemed:
+			return nil, ErrCodeRedeemed
+		case activationCode.Status == CodeStatusRevoked:
+			return nil, ErrCodeRevoked
+		case activationCode.Status == "active" && activationCode.ExpiresAt != nil && !activationCode.ExpiresAt.After(now):
+			return nil, ErrCodeExpired
+		default:
+			return nil, ErrCodeUsed
+		}
+	}
+
+	if _, err := tx.Exec(
+		"INSERT INTO redemptions (code_id, user_id, redeemed_at) VALUES (?, ?, ?)",
+		activationCode.ID, userID, now.Truncate(time.Second),
+	); err != nil {
+		return nil, fmt.Errorf("failed to record redemption: %w", err)
+	}
+
+	redemption := &Redemption{Code: *activationCode}
+
+	// Срок проверяется до начисления лимита: extendSubscription отличает
+	// бессрочный доступ от пользователя, у которого доступа еще не было
+	if activationCode.DurationDays > 0 {
+		expiresAt, err := extendSubscription(tx, userID, activationCode.DurationDays, now)
+		if err != nil {
+			return nil, err
+		}
+		redemption.ExpiresAt = expiresAt
+	}
+
+	if _, err := tx.Exec(
+		"UPDATE users SET limit_count = COALESCE(limit_count, 0) + ? WHERE id = ?",
+		activationCode.Limit, userID,
+	); err != nil {
+		return nil, fmt.Errorf("failed to update user limit: %w", err)
+	}
+
+	// Увеличение квоты снимает блокировку при следующей проверке, поэтому
+	// предупреждения начинаются заново
+	if activationCode.TrafficQuota > 0 {
+		if _, err := tx.Exec(
+			"UPDATE users SET traffic_quota = traffic_quota + ?, quota_warned = 0 WHERE id = ?",
+			activationCode.TrafficQuota, userID,
+		); err != nil {
+			return nil, fmt.Errorf("failed to update traffic quota: %w", err)
+		}
+	}
+
+	var expiresAt sql.NullTime
+	if err := tx.QueryRow(
+		"SELECT limit_count, traffic_quota, expires_at FROM users WHERE id = ?",
+		userID,
+	).Scan(&redemption.Limit, &redemption.TrafficQuota, &expiresAt); err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	if expiresAt.Valid {
+		redemption.ExpiresAt = &expiresAt.Time
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit redemption: %w", err)
+	}
+	return redemption, nil
+}
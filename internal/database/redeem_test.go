@@ -0,0 +1,204 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// redeemConcurrently активирует code одновременно от имени каждого из users и
+// возвращает число успешных активаций
+func redeemConcurrently(t *testing.T, db *DB, users []*User, code string) int {
+	t.Helper()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		redeems int
+	)
+	for _, user := range users {
+		wg.Add(1)
+		go func(user *User) {
+			defer wg.Done()
+			_, err := db.RedeemCode(user.ID, code)
+			switch {
+			case err == nil:
+				mu.Lock()
+				redeems++
+				mu.Unlock()
+			case !errors.Is(err, ErrCodeUsed):
+				t.Errorf("RedeemCode(%d): %v", user.ID, err)
+			}
+		}(user)
+	}
+	wg.Wait()
+	return redeems
+}
+
+// codeUsage возвращает счетчик активаций кода и число записей о них
+func codeUsage(t *testing.T, db *DB, code string) (uses, redemptions int) {
+	t.Helper()
+	if err := db.conn.QueryRow(
+		`SELECT c.uses, (SELECT COUNT(*) FROM redemptions r WHERE r.code_id = c.id)
+		FROM activation_codes c WHERE c.code = ?`,
+		code,
+	).Scan(&uses, &redemptions); err != nil {
+		t.Fatalf("failed to query code usage: %v", err)
+	}
+	return uses, redemptions
+}
+
+// newTestUsers регистрирует n пользователей
+func newTestUsers(t *testing.T, db *DB, n int) []*User {
+	t.Helper()
+	users := make([]*User, n)
+	for i := range users {
+		users[i] = newTestUser(t, db, int64(1000+i))
+	}
+	return users
+}
+
+func TestRedeemCodeConcurrent(t *testing.T) {
+	const workers = 16
+
+	tests := []struct {
+		maxUses int
+		want    int
+	}{
+		{maxUses: 1, want: 1},
+		{maxUses: 5, want: 5},
+		{maxUses: 0, want: workers},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("max_uses=%d", tt.maxUses), func(t *testing.T) {
+			db := newTestDB(t)
+			users := newTestUsers(t, db, workers)
+			code := "TEST-CODE"
+			if _, err := db.CreateActivationCode(code, CodeOptions{Limit: 1, MaxUses: tt.maxUses}); err != nil {
+				t.Fatalf("CreateActivationCode: %v", err)
+			}
+
+			if got := redeemConcurrently(t, db, users, code); got != tt.want {
+				t.Errorf("redeemed %d times, want %d", got, tt.want)
+			}
+			uses, redemptions := codeUsage(t, db, code)
+			if uses != tt.want || redemptions != tt.want {
+				t.Errorf("uses = %d, redemptions = %d, want %d", uses, redemptions, tt.want)
+			}
+
+			// Лимит начисляется только тем, кто активировал код
+			var credited int
+			if err := db.conn.QueryRow("SELECT COALESCE(SUM(limit_count), 0) FROM users").Scan(&credited); err != nil {
+				t.Fatalf("failed to query limits: %v", err)
+			}
+			if credited != tt.want {
+				t.Errorf("credited limit = %d, want %d", credited, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedeemCodeOncePerUser(t *testing.T) {
+	const attempts = 8
+
+	db := newTestDB(t)
+	user := newTestUser(t, db, 1)
+	code := "TEST-CODE"
+	if _, err := db.CreateActivationCode(code, CodeOptions{Limit: 1}); err != nil {
+		t.Fatalf("CreateActivationCode: %v", err)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		redeemed int
+	)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := db.RedeemCode(user.ID, code)
+			switch {
+			case err == nil:
+				mu.Lock()
+				redeemed++
+				mu.Unlock()
+			case !errors.Is(err, ErrCodeRedeemed):
+				t.Errorf("RedeemCode: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if redeemed != 1 {
+		t.Errorf("redeemed %d times, want 1", redeemed)
+	}
+	if uses, redemptions := codeUsage(t, db, code); uses != 1 || redemptions != 1 {
+		t.Errorf("uses = %d, redemptions = %d, want 1", uses, redemptions)
+	}
+}
+
+func TestRedeemDurationCode(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.CreateActivationCode("DAYS", CodeOptions{Limit: 1, DurationDays: 30}); err != nil {
+		t.Fatalf("CreateActivationCode: %v", err)
+	}
+
+	// Новый пользователь без доступа получает срок от момента активации
+	newcomer := newTestUser(t, db, 1)
+	redemption, err := db.RedeemCode(newcomer.ID, "DAYS")
+	if err != nil {
+		t.Fatalf("RedeemCode(newcomer): %v", err)
+	}
+	if redemption.ExpiresAt == nil || time.Until(*redemption.ExpiresAt) < 29*24*time.Hour {
+		t.Errorf("newcomer expires at %v, want about 30 days from now", redemption.ExpiresAt)
+	}
+
+	// Бессрочный пользователь с конфигурацией остается бессрочным
+	permanent := newTestUser(t, db, 2)
+	if err := db.UpdateUserLimit(permanent.ID, 2); err != nil {
+		t.Fatalf("UpdateUserLimit: %v", err)
+	}
+	config, err := db.CreateConfig(permanent.ID, "main", "client1", "/tmp/client1.ovpn")
+	if err != nil {
+		t.Fatalf("CreateConfig: %v", err)
+	}
+	redemption, err = db.RedeemCode(permanent.ID, "DAYS")
+	if err != nil {
+		t.Fatalf("RedeemCode(permanent): %v", err)
+	}
+	if redemption.ExpiresAt != nil || redemption.Limit != 3 {
+		t.Errorf("permanent redemption = expires %v, limit %d", redemption.ExpiresAt, redemption.Limit)
+	}
+	user, err := db.GetUserByID(permanent.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if user.ExpiresAt != nil {
+		t.Errorf("permanent user expires at %v", user.ExpiresAt)
+	}
+	if config, err = db.GetConfigByID(config.ID); err != nil {
+		t.Fatalf("GetConfigByID: %v", err)
+	} else if config.ExpiresAt != nil {
+		t.Errorf("config of permanent user expires at %v", config.ExpiresAt)
+	}
+
+	// Повторный код продлевает срок от текущего окончания
+	if _, err := db.CreateActivationCode("MORE-DAYS", CodeOptions{DurationDays: 10}); err != nil {
+		t.Fatalf("CreateActivationCode: %v", err)
+	}
+	previous, err := db.GetUserByID(newcomer.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	redemption, err = db.RedeemCode(newcomer.ID, "MORE-DAYS")
+	if err != nil {
+		t.Fatalf("RedeemCode(newcomer, MORE-DAYS): %v", err)
+	}
+	if want := previous.ExpiresAt.AddDate(0, 0, 10); redemption.ExpiresAt == nil || !redemption.ExpiresAt.Equal(want) {
+		t.Errorf("extended expiry = %v, want %v", redemption.ExpiresAt, want)
+	}
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	hourLayout = "2006-01-02 15:00:00"
+	dayLayout  = "2006-01-02"
+)
+
+// Usage - трафик конфигурации за период. BytesReceived - получено сервером
+// от клиента (загрузка), BytesSent - отправлено клиенту (скачивание)
+type Usage struct {
+	BytesReceived int64 `json:"bytes_received"`
+	BytesSent     int64 `json:"bytes_sent"`
+}
+
+// Total возвращает суммарный трафик
+func (u Usage) Total() int64 {
+	return u.BytesReceived + u.BytesSent
+}
+
+// UsageSummary - трафик конфигурации за последние сутки, неделю и месяц
+type UsageSummary struct {
+	Day   Usage `json:"day"`
+	Week  Usage `json:"week"`
+	Month Usage `json:"month"`
+}
+
+// RecordUsageSample сохраняет текущие счетчики сессии клиента. Счетчики OpenVPN
+// накапливаются с начала сессии, поэтому в сводные таблицы попадает разница
+// с предыдущим замером; новая сессия (другой sessionKey) учитывается целиком.
+// Замеры приходят и из опроса status, и из уведомлений management интерфейса,
+// поэтому устаревший замер той же сессии пропускается
+func (db *DB) RecordUsageSample(configID int64, sessionKey string, bytesReceived, bytesSent int64, at time.Time) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		lastKey      string
+		lastReceived int64
+		lastSent     int64
+	)
+	err = tx.QueryRow(
+		"SELECT session_key, bytes_received, bytes_sent FROM usage_counters WHERE config_id = ?",
+		configID,
+	).Scan(&lastKey, &lastReceived, &lastSent)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to query usage counters: %w", err)
+	}
+
+	deltaReceived, deltaSent := bytesReceived, bytesSent
+	if err == nil && lastKey == sessionKey {
+		deltaReceived -= lastReceived
+		deltaSent -= lastSent
+	}
+	// Счетчики не уменьшаются в пределах сессии: меньшие значения - устаревший
+	// замер (опрос status, прочитанный до уведомления management интерфейса)
+	if deltaReceived < 0 || deltaSent < 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO usage_counters (config_id, session_key, bytes_received, bytes_sent, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (config_id) DO UPDATE SET
+			session_key = excluded.session_key,
+			bytes_received = excluded.bytes_received,
+			bytes_sent = excluded.bytes_sent,
+			updated_at = excluded.updated_at`,
+		configID, sessionKey, bytesReceived, bytesSent, at.UTC(),
+	); err != nil {
+		return fmt.Errorf("failed to update usage counters: %w", err)
+	}
+
+	if deltaReceived > 0 || deltaSent > 0 {
+		utc := at.UTC()
+		if _, err := tx.Exec(
+			`INSERT INTO usage_hourly (config_id, hour, bytes_received, bytes_sent) VALUES (?, ?, ?, ?)
+			ON CONFLICT (config_id, hour) DO UPDATE SET
+				bytes_received = bytes_received + excluded.bytes_received,
+				bytes_sent = bytes_sent + excluded.bytes_sent`,
+			configID, utc.Format(hourLayout), deltaReceived, deltaSent,
+		); err != nil {
+			return fmt.Errorf("failed to update hourly usage: %w", err)
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO usage_daily (config_id, day, bytes_received, bytes_sent) VALUES (?, ?, ?, ?)
+			ON CONFLICT (config_id, day) DO UPDATE SET
+				bytes_received = bytes_received + excluded.bytes_received,
+				bytes_sent = bytes_sent + excluded.bytes_sent`,
+			configID, utc.Format(dayLayout), deltaReceived, deltaSent,
+		); err != nil {
+			return fmt.Errorf("failed to update daily usage: %w", err)
+		}
+		// Трафик для квоты считается по пользователю, чтобы удаление
+		// конфигурации не обнуляло израсходованный объем
+		if _, err := tx.Exec(
+			`INSERT INTO usage_monthly (user_id, month, bytes_received, bytes_sent)
+			SELECT user_id, ?, ?, ? FROM configs WHERE id = ? AND true
+			ON CONFLICT (user_id, month) DO UPDATE SET
+				bytes_received = bytes_received + excluded.bytes_received,
+				bytes_sent = bytes_sent + excluded.bytes_sent`,
+			QuotaPeriod(utc), deltaReceived, deltaSent, configID,
+		); err != nil {
+			return fmt.Errorf("failed to update monthly usage: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit usage: %w", err)
+	}
+	return nil
+}
+
+// GetUsageSummary возвращает трафик конфигурации за сутки (по часам), неделю и месяц (по дням)
+func (db *DB) GetUsageSummary(configID int64, now time.Time) (*UsageSummary, error) {
+	utc := now.UTC()
+	var summary UsageSummary
+
+	if err := db.conn.QueryRow(
+		`SELECT COALESCE(SUM(bytes_received), 0), COALESCE(SUM(bytes_sent), 0)
+		FROM usage_hourly WHERE config_id = ? AND hour > ?`,
+		configID, utc.Add(-24*time.Hour).Format(hourLayout),
+	).Scan(&summary.Day.BytesReceived, &summary.Day.BytesSent); err != nil {
+		return nil, fmt.Errorf("failed to query daily usage: %w", err)
+	}
+
+	for _, period := range []struct {
+		days  int
+		usage *Usage
+	}{
+		{7, &summary.Week},
+		{30, &summary.Month},
+	} {
+		if err := db.conn.QueryRow(
+			`SELECT COALESCE(SUM(bytes_received), 0), COALESCE(SUM(bytes_sent), 0)
+			FROM usage_daily WHERE config_id = ? AND day > ?`,
+			configID, utc.AddDate(0, 0, -period.days).Format(dayLayout),
+		).Scan(&period.usage.BytesReceived, &period.usage.BytesSent); err != nil {
+			return nil, fmt.Errorf("failed to query usage: %w", err)
+		}
+	}
+
+	return &summary, nil
+}
+
+// PruneHourlyUsage удаляет почасовую статистику старше before; дневная сохраняется
+func (db *DB) PruneHourlyUsage(before time.Time) error {
+	if _, err := db.conn.Exec(
+		"DELETE FROM usage_hourly WHERE hour < ?",
+		before.UTC().Format(hourLayout),
+	); err != nil {
+		return fmt.Errorf("failed to prune hourly usage: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordUsageSample(t *testing.T) {
+	db := newTestDB(t)
+	user := newTestUser(t, db, 1001)
+	cfg, err := db.CreateConfig(user.ID, "main", "client1", "/tmp/client1.ovpn")
+	if err != nil {
+		t.Fatalf("CreateConfig: %v", err)
+	}
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	samples := []struct {
+		session        string
+		received, sent int64
+		wantTotal      int64
+	}{
+		{"s1", 100, 200, 300},
+		// Уведомление об отключении с итоговыми счетчиками
+		{"s1", 150, 250, 400},
+		// Опрос status, прочитанный до уведомления, не должен учитываться повторно
+		{"s1", 120, 220, 400},
+		// Новая сессия учитывается целиком
+		{"s2", 10, 20, 430},
+	}
+	for i, sample := range samples {
+		if err := db.RecordUsageSample(cfg.ID, sample.session, sample.received, sample.sent, now); err != nil {
+			t.Fatalf("RecordUsageSample #%d: %v", i, err)
+		}
+		usage, err := db.GetUserMonthlyUsage(user.ID, now)
+		if err != nil {
+			t.Fatalf("GetUserMonthlyUsage: %v", err)
+		}
+		if usage.Total() != sample.wantTotal {
+			t.Errorf("after sample #%d total = %d, want %d", i, usage.Total(), sample.wantTotal)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Package generator генерирует криптографически стойкие случайные строки:
+// коды активации и имена клиентов OpenVPN
+package generator
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Алфавиты для генерации
+const (
+	// Alphanumeric - латинские буквы в обоих регистрах и цифры
+	Alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// Readable - заглавные буквы и цифры без похожих символов (0/O, 1/I/L),
+	// удобен для кодов, которые вводят вручную
+	Readable = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+)
+
+// DefaultAttempts - число попыток GenerateUnique по умолчанию
+const DefaultAttempts = 10
+
+// ErrExhausted возвращается, если за отведенное число попыток не найдено уникальное значение
+var ErrExhausted = errors.New("no unique value generated")
+
+// Options - параметры генератора
+type Options struct {
+	// Length - число случайных символов без учета разделителей
+	Length int
+	// Alphabet - допустимые символы, по умолчанию Alphanumeric
+	Alphabet string
+	// GroupSize разбивает значение на группы через Separator (XXXX-XXXX), 0 - без групп
+	GroupSize int
+	// Separator - разделитель групп, по умолчанию "-"
+	Separator string
+	// Prefix добавляется к значению без изменений
+	Prefix string
+}
+
+// Generator генерирует случайные строки по заданным параметрам
+type Generator struct {
+	opts Options
+}
+
+// New проверяет параметры и создает генератор
+func New(opts Options) (*Generator, error) {
+	if opts.Alphabet == "" {
+		opts.Alphabet = Alphanumeric
+	}
+	if opts.Separator == "" {
+		opts.Separator = "-"
+	}
+
+	if opts.Length <= 0 {
+		return nil, fmt.Errorf("length must be positive, got %d", opts.Length)
+	}
+	if opts.GroupSize < 0 {
+		return nil, fmt.Errorf("group size must not be negative, got %d", opts.GroupSize)
+	}
+	if len(opts.Alphabet) < 2 || len(opts.Alphabet) > 256 {
+		return nil, fmt.Errorf("alphabet must contain from 2 to 256 characters, got %d", len(opts.Alphabet))
+	}
+	seen := make(map[byte]bool)
+	for i := 0; i < len(opts.Alphabet); i++ {
+		c := opts.Alphabet[i]
+		if c >= 0x80 {
+			return nil, fmt.Errorf("alphabet must contain only ASCII characters")
+		}
+		if seen[c] {
+			return nil, fmt.Errorf("alphabet contains duplicate character %q", c)
+		}
+		if opts.GroupSize > 0 && strings.IndexByte(opts.Separator, c) >= 0 {
+			return nil, fmt.Errorf("alphabet contains separator character %q", c)
+		}
+		seen[c] = true
+	}
+
+	return &Generator{opts: opts}, nil
+}
+
+// MustNew создает генератор с параметрами, известными на этапе компиляции
+func MustNew(opts Options) *Generator {
+	g, err := New(opts)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// Generate возвращает новое случайное значение
+func (g *Generator) Generate() (string, error) {
+	raw, err := randomString(g.opts.Length, g.opts.Alphabet)
+	if err != nil {
+		return "", err
+	}
+	return g.opts.Prefix + g.group(raw), nil
+}
+
+// GenerateUnique генерирует значения, пока exists не сообщит, что значение свободно.
+// Уникальность при вставке все равно должна гарантироваться ограничением UNIQUE
+func (g *Generator) GenerateUnique(exists func(string) (bool, error)) (string, error) {
+	for attempt := 0; attempt < DefaultAttempts; attempt++ {
+		value, err := g.Generate()
+		if err != nil {
+			return "", err
+		}
+
+		taken, err := exists(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to check uniqueness: %w", err)
+		}
+		if !taken {
+			return value, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w after %d attempts", ErrExhausted, DefaultAttempts)
+}
+
+// Normalize приводит введенное пользователем значение к виду, в котором оно генерируется:
+// убирает пробелы и расставляет разделители групп заново
+func (g *Generator) Normalize(value string) string {
+	value = strings.TrimSpace(value)
+	value = strings.TrimPrefix(value, g.opts.Prefix)
+	if g.opts.GroupSize > 0 {
+		value = strings.ReplaceAll(value, g.opts.Separator, "")
+	}
+	value = strings.Join(strings.Fields(value), "")
+	if strings.ToUpper(g.opts.Alphabet) == g.opts.Alphabet {
+		// В алфавите без строчных букв регистр ввода не важен
+		value = strings.ToUpper(value)
+	}
+	return g.opts.Prefix + g.group(value)
+}
+
+// Valid проверяет, что значение могло быть получено этим генератором
+func (g *Generator) Valid(value string) bool {
+	if !strings.HasPrefix(value, g.opts.Prefix) {
+		return false
+	}
+	value = strings.TrimPrefix(value, g.opts.Prefix)
+	if g.opts.GroupSize > 0 {
+		if value != g.group(strings.ReplaceAll(value, g.opts.Separator, "")) {
+			return false
+		}
+		value = strings.ReplaceAll(value, g.opts.Separator, "")
+	}
+
+	if len(value) != g.opts.Length {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if strings.IndexByte(g.opts.Alphabet, value[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// group разбивает строку на группы по GroupSize символов
+func (g *Generator) group(value string) string {
+	size := g.opts.GroupSize
+	if size <= 0 || len(value) <= size {
+		return value
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(value); i += size {
+		if i > 0 {
+			sb.WriteString(g.opts.Separator)
+		}
+		end := i + size
+		if end > len(value) {
+			end = len(value)
+		}
+		sb.WriteString(value[i:end])
+	}
+	return sb.String()
+}
+
+// randomString выбирает length символов алфавита равновероятно. Байты, которые
+// дали бы смещение распределения при взятии остатка, отбрасываются
+func randomString(length int, alphabet string) (string, error) {
+	n := len(alphabet)
+	limit := 256 - 256%n
+
+	result := make([]byte, 0, length)
+	buf := make([]byte, length+length/2)
+	for len(result) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("failed to read random bytes: %w", err)
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			result = append(result, alphabet[int(b)%n])
+			if len(result) == length {
+				break
+			}
+		}
+	}
+
+	return string(result), nil
+}
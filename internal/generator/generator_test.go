@@ -0,0 +1,187 @@
+package generator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGenerateAlphabetAndLength(t *testing.T) {
+	tests := []Options{
+		{Length: 8},
+		{Length: 12, Alphabet: Readable},
+		{Length: 2, Alphabet: "01"},
+	}
+
+	for _, opts := range tests {
+		g := MustNew(opts)
+		alphabet := opts.Alphabet
+		if alphabet == "" {
+			alphabet = Alphanumeric
+		}
+
+		for i := 0; i < 100; i++ {
+			value, err := g.Generate()
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			if len(value) != opts.Length {
+				t.Fatalf("len(%q) = %d, want %d", value, len(value), opts.Length)
+			}
+			if i := strings.IndexFunc(value, func(r rune) bool { return !strings.ContainsRune(alphabet, r) }); i >= 0 {
+				t.Fatalf("%q contains %q outside of alphabet %q", value, value[i], alphabet)
+			}
+			if !g.Valid(value) {
+				t.Fatalf("Valid(%q) = false for generated value", value)
+			}
+		}
+	}
+}
+
+func TestGenerateGroups(t *testing.T) {
+	tests := []struct {
+		opts    Options
+		pattern string
+	}{
+		{Options{Length: 12, Alphabet: Readable, GroupSize: 4}, "XXXX-XXXX-XXXX"},
+		{Options{Length: 10, Alphabet: Readable, GroupSize: 4}, "XXXX-XXXX-XX"},
+		{Options{Length: 6, Alphabet: Readable, GroupSize: 3, Separator: "_", Prefix: "VPN-"}, "VPN-XXX_XXX"},
+		{Options{Length: 4, Alphabet: Readable, GroupSize: 4}, "XXXX"},
+	}
+
+	for _, tt := range tests {
+		g := MustNew(tt.opts)
+		value, err := g.Generate()
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+
+		if len(value) != len(tt.pattern) {
+			t.Fatalf("%q does not match %q", value, tt.pattern)
+		}
+		for i := range tt.pattern {
+			if tt.pattern[i] != 'X' && value[i] != tt.pattern[i] {
+				t.Fatalf("%q does not match %q", value, tt.pattern)
+			}
+		}
+		if !g.Valid(value) {
+			t.Fatalf("Valid(%q) = false for generated value", value)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	g := MustNew(Options{Length: 12, Alphabet: Readable, GroupSize: 4})
+
+	for i := 0; i < 20; i++ {
+		value, err := g.Generate()
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+
+		compact := strings.ReplaceAll(value, "-", "")
+		inputs := []string{
+			value,
+			"  " + value + "\n",
+			strings.ToLower(value),
+			compact,
+			compact[:6] + " " + compact[6:],
+			compact[:3] + "-" + compact[3:],
+		}
+		for _, input := range inputs {
+			if got := g.Normalize(input); got != value {
+				t.Errorf("Normalize(%q) = %q, want %q", input, got, value)
+			}
+		}
+	}
+}
+
+func TestNormalizeKeepsCase(t *testing.T) {
+	// В алфавите со строчными буквами регистр значим
+	g := MustNew(Options{Length: 8, Prefix: "vpn-"})
+	if got := g.Normalize(" vpn-aBcD1234 "); got != "vpn-aBcD1234" {
+		t.Errorf("Normalize = %q", got)
+	}
+	if got := g.Normalize("aBcD1234"); got != "vpn-aBcD1234" {
+		t.Errorf("Normalize without prefix = %q", got)
+	}
+}
+
+func TestValid(t *testing.T) {
+	g := MustNew(Options{Length: 8, Alphabet: Readable, GroupSize: 4})
+
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"ABCD-EFGH", true},
+		{"ABCDEFGH", false},
+		{"ABC-DEFGH", false},
+		{"ABCD-EFG", false},
+		{"ABCD-EFGHJ", false},
+		{"abcd-efgh", false},
+		{"ABCD-EFG0", false},
+		{"ABCD_EFGH", false},
+	}
+
+	for _, tt := range tests {
+		if got := g.Valid(tt.value); got != tt.want {
+			t.Errorf("Valid(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateUnique(t *testing.T) {
+	g := MustNew(Options{Length: 8})
+
+	taken := 3
+	calls := 0
+	value, err := g.GenerateUnique(func(string) (bool, error) {
+		calls++
+		return calls <= taken, nil
+	})
+	if err != nil {
+		t.Fatalf("GenerateUnique: %v", err)
+	}
+	if calls != taken+1 || !g.Valid(value) {
+		t.Errorf("GenerateUnique = %q after %d checks", value, calls)
+	}
+}
+
+func TestGenerateUniqueExhausted(t *testing.T) {
+	g := MustNew(Options{Length: 8})
+
+	calls := 0
+	_, err := g.GenerateUnique(func(string) (bool, error) {
+		calls++
+		return true, nil
+	})
+	if !errors.Is(err, ErrExhausted) {
+		t.Fatalf("GenerateUnique error = %v, want ErrExhausted", err)
+	}
+	if calls != DefaultAttempts {
+		t.Errorf("checked %d values, want %d", calls, DefaultAttempts)
+	}
+
+	failure := errors.New("database is locked")
+	if _, err := g.GenerateUnique(func(string) (bool, error) { return false, failure }); !errors.Is(err, failure) {
+		t.Errorf("GenerateUnique error = %v, want %v", err, failure)
+	}
+}
+
+func TestNewRejectsInvalidOptions(t *testing.T) {
+	tests := []Options{
+		{Length: 0},
+		{Length: 8, GroupSize: -1},
+		{Length: 8, Alphabet: "A"},
+		{Length: 8, Alphabet: "AAB"},
+		{Length: 8, Alphabet: "ABC-", GroupSize: 2},
+		{Length: 8, Alphabet: "ÄBC"},
+	}
+
+	for _, opts := range tests {
+		if _, err := New(opts); err == nil {
+			t.Errorf("New(%+v) succeeded", opts)
+		}
+	}
+}
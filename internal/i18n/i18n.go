@@ -0,0 +1,200 @@
+// Package i18n переводит сообщения бота. Каталоги - JSON файлы locales/<язык>.json,
+// встроенные в бинарник; во всех каталогах должны быть одинаковые ключи
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// DefaultLanguage - язык по умолчанию, если язык пользователя не поддерживается
+const DefaultLanguage = "ru"
+
+// NameKey - ключ каталога с названием языка на нем самом, например "English"
+const NameKey = "language.name"
+
+// verbPattern находит глаголы fmt: количество и порядок аргументов в переводах должны совпадать
+var verbPattern = regexp.MustCompile(`%(\[\d+\])?[-+# 0]*\d*(\.\d+)?[a-zA-Z%]`)
+
+// Bundle - загруженные каталоги всех языков
+type Bundle struct {
+	defaultLanguage string
+	catalogs        map[string]map[string]string
+}
+
+// Load читает встроенные каталоги и проверяет, что в них одинаковые ключи и глаголы fmt.
+// defaultLanguage должен быть среди загруженных языков
+func Load(defaultLanguage string) (*Bundle, error) {
+	files, err := locales.ReadDir("locales")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read locales: %w", err)
+	}
+
+	b := &Bundle{
+		defaultLanguage: defaultLanguage,
+		catalogs:        make(map[string]map[string]string),
+	}
+	for _, file := range files {
+		data, err := locales.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.Name(), err)
+		}
+
+		var catalog map[string]string
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", file.Name(), err)
+		}
+		b.catalogs[strings.TrimSuffix(file.Name(), ".json")] = catalog
+	}
+
+	reference, ok := b.catalogs[defaultLanguage]
+	if !ok {
+		return nil, fmt.Errorf("no catalog for default language %q", defaultLanguage)
+	}
+	for lang, catalog := range b.catalogs {
+		if err := compareCatalogs(reference, catalog); err != nil {
+			return nil, fmt.Errorf("catalog %s differs from %s: %w", lang, defaultLanguage, err)
+		}
+	}
+
+	return b, nil
+}
+
+// compareCatalogs проверяет, что в каталогах одинаковые ключи, а в переводах - те же глаголы fmt
+func compareCatalogs(reference, catalog map[string]string) error {
+	var missing, extra, verbs []string
+	for key, text := range reference {
+		translated, ok := catalog[key]
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+		if !sameVerbs(text, translated) {
+			verbs = append(verbs, key)
+		}
+	}
+	for key := range catalog {
+		if _, ok := reference[key]; !ok {
+			extra = append(extra, key)
+		}
+	}
+
+	var problems []string
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		problems = append(problems, "missing keys "+strings.Join(missing, ", "))
+	}
+	if len(extra) > 0 {
+		sort.Strings(extra)
+		problems = append(problems, "extra keys "+strings.Join(extra, ", "))
+	}
+	if len(verbs) > 0 {
+		sort.Strings(verbs)
+		problems = append(problems, "different format verbs in "+strings.Join(verbs, ", "))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// sameVerbs проверяет, что строки передают аргументы в том же порядке и теми же глаголами.
+// Переставлять аргументы в переводе можно только явными индексами (%[2]s)
+func sameVerbs(a, b string) bool {
+	return strings.Join(formatArgs(a), " ") == strings.Join(formatArgs(b), " ")
+}
+
+// formatArgs возвращает глаголы строки в виде "номер аргумента:глагол", упорядоченные
+// по номеру аргумента с учетом явных индексов
+func formatArgs(format string) []string {
+	var args []string
+	next := 1
+	for _, match := range verbPattern.FindAllStringSubmatch(format, -1) {
+		verb := match[0]
+		if verb == "%%" {
+			continue
+		}
+		if index := match[1]; index != "" {
+			next, _ = strconv.Atoi(strings.Trim(index, "[]"))
+			verb = strings.Replace(verb, index, "", 1)
+		}
+		args = append(args, fmt.Sprintf("%03d:%s", next, verb))
+		next++
+	}
+	sort.Strings(args)
+	return args
+}
+
+// Languages возвращает коды поддерживаемых языков: сначала язык по умолчанию, затем по алфавиту
+func (b *Bundle) Languages() []string {
+	languages := make([]string, 0, len(b.catalogs))
+	for lang := range b.catalogs {
+		if lang != b.defaultLanguage {
+			languages = append(languages, lang)
+		}
+	}
+	sort.Strings(languages)
+	return append([]string{b.defaultLanguage}, languages...)
+}
+
+// Supported проверяет, что для языка есть каталог
+func (b *Bundle) Supported(lang string) bool {
+	_, ok := b.catalogs[lang]
+	return ok
+}
+
+// Match подбирает язык по language_code Telegram ("en", "pt-br"): сначала точное
+// совпадение, затем основной язык, иначе язык по умолчанию
+func (b *Bundle) Match(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(code, "_", "-"))
+	if b.Supported(code) {
+		return code
+	}
+	if base, _, ok := strings.Cut(code, "-"); ok && b.Supported(base) {
+		return base
+	}
+	return b.defaultLanguage
+}
+
+// Localizer возвращает переводчик для языка; неподдерживаемый язык заменяется языком по умолчанию
+func (b *Bundle) Localizer(lang string) *Localizer {
+	if !b.Supported(lang) {
+		lang = b.defaultLanguage
+	}
+	return &Localizer{lang: lang, messages: b.catalogs[lang]}
+}
+
+// Localizer переводит сообщения на один язык
+type Localizer struct {
+	lang     string
+	messages map[string]string
+}
+
+// Language возвращает код языка
+func (l *Localizer) Language() string {
+	return l.lang
+}
+
+// T возвращает перевод key, подставляя args через fmt.Sprintf.
+// Неизвестный ключ возвращается как есть, чтобы ошибка была заметна в сообщении
+func (l *Localizer) T(key string, args ...interface{}) string {
+	text, ok := l.messages[key]
+	if !ok {
+		log.Printf("Missing translation %q for language %s", key, l.lang)
+		return key
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
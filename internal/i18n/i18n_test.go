@@ -0,0 +1,168 @@
+package i18n
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// readCatalogs читает встроенные каталоги без проверок Load
+func readCatalogs(t *testing.T) map[string]map[string]string {
+	t.Helper()
+	files, err := locales.ReadDir("locales")
+	if err != nil {
+		t.Fatalf("failed to read locales: %v", err)
+	}
+
+	catalogs := make(map[string]map[string]string)
+	for _, file := range files {
+		data, err := locales.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", file.Name(), err)
+		}
+		var catalog map[string]string
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			t.Fatalf("failed to parse %s: %v", file.Name(), err)
+		}
+		catalogs[strings.TrimSuffix(file.Name(), ".json")] = catalog
+	}
+	return catalogs
+}
+
+func TestCatalogsHaveSameKeys(t *testing.T) {
+	catalogs := readCatalogs(t)
+	if len(catalogs) < 2 {
+		t.Fatalf("found %d catalogs", len(catalogs))
+	}
+
+	// Каждый каталог сравнивается с каждым, чтобы ошибка называла оба языка
+	for lang, catalog := range catalogs {
+		for other, reference := range catalogs {
+			if lang == other {
+				continue
+			}
+			if err := compareCatalogs(reference, catalog); err != nil {
+				t.Errorf("catalog %s differs from %s: %v", lang, other, err)
+			}
+		}
+
+		for key, text := range catalog {
+			if strings.TrimSpace(text) == "" {
+				t.Errorf("catalog %s: empty translation of %s", lang, key)
+			}
+		}
+		if catalog[NameKey] == "" {
+			t.Errorf("catalog %s: no %s", lang, NameKey)
+		}
+	}
+}
+
+func TestLoadEveryDefaultLanguage(t *testing.T) {
+	for lang := range readCatalogs(t) {
+		b, err := Load(lang)
+		if err != nil {
+			t.Fatalf("Load(%s): %v", lang, err)
+		}
+		if languages := b.Languages(); languages[0] != lang {
+			t.Errorf("Load(%s).Languages() = %v", lang, languages)
+		}
+	}
+
+	if _, err := Load("xx"); err == nil {
+		t.Error("Load succeeded for language without catalog")
+	}
+}
+
+func TestCompareCatalogs(t *testing.T) {
+	reference := map[string]string{"a": "%s of %d", "b": "text"}
+
+	tests := []struct {
+		catalog map[string]string
+		problem string
+	}{
+		{map[string]string{"a": "%s из %d", "b": "текст"}, ""},
+		{map[string]string{"a": "%[2]d: %[1]s", "b": "текст"}, ""},
+		{map[string]string{"a": "%d: %s", "b": "текст"}, "different format verbs in a"},
+		{map[string]string{"a": "%[2]s: %[1]d", "b": "текст"}, "different format verbs in a"},
+		{map[string]string{"a": "%s of %d"}, "missing keys b"},
+		{map[string]string{"a": "%s of %d", "b": "text", "c": "extra"}, "extra keys c"},
+		{map[string]string{"a": "%s of %s", "b": "text"}, "different format verbs in a"},
+	}
+
+	for _, tt := range tests {
+		err := compareCatalogs(reference, tt.catalog)
+		switch {
+		case tt.problem == "" && err != nil:
+			t.Errorf("compareCatalogs(%v) = %v", tt.catalog, err)
+		case tt.problem != "" && (err == nil || !strings.Contains(err.Error(), tt.problem)):
+			t.Errorf("compareCatalogs(%v) = %v, want %q", tt.catalog, err, tt.problem)
+		}
+	}
+}
+
+// keyPattern находит ключи, переданные в T и notifyAdmins строковым литералом
+var keyPattern = regexp.MustCompile(`(?:\.T|notifyAdmins)\("([a-z0-9_.]+)"`)
+
+func TestSourceKeysExist(t *testing.T) {
+	catalog := readCatalogs(t)[DefaultLanguage]
+
+	found := 0
+	for _, dir := range []string{"../../internal", "../../cmd"} {
+		err := filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+				return err
+			}
+			data, err := os.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			for _, match := range keyPattern.FindAllStringSubmatch(string(data), -1) {
+				found++
+				if _, ok := catalog[match[1]]; !ok {
+					t.Errorf("%s: unknown key %s", name, match[1])
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("failed to scan %s: %v", dir, err)
+		}
+	}
+	if found == 0 {
+		t.Fatal("no translation keys found in source")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	b, err := Load(DefaultLanguage)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	tests := map[string]string{
+		"en":    "en",
+		"en-US": "en",
+		"uk":    "uk",
+		"uk_UA": "uk",
+		"ru":    "ru",
+		"de":    DefaultLanguage,
+		"":      DefaultLanguage,
+	}
+	for code, want := range tests {
+		if got := b.Match(code); got != want {
+			t.Errorf("Match(%q) = %q, want %q", code, got, want)
+		}
+	}
+
+	if got := b.Localizer("de").Language(); got != DefaultLanguage {
+		t.Errorf("Localizer(de).Language() = %q", got)
+	}
+	if got := b.Localizer("en").T("no.such.key"); got != "no.such.key" {
+		t.Errorf("T of unknown key = %q", got)
+	}
+}
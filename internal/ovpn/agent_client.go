@@ -0,0 +1,214 @@
+package ovpn
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// AgentCreateRequest - тело запроса POST /v1/clients
+type AgentCreateRequest struct {
+	Owner string `json:"owner"`
+}
+
+// AgentCreateResponse - ответ агента на создание клиента
+type AgentCreateResponse struct {
+	Name       string `json:"name"`
+	ConfigPath string `json:"config_path"`
+}
+
+// AgentRevokeRequest - тело запроса POST /v1/clients/revoke
+type AgentRevokeRequest struct {
+	Name       string `json:"name"`
+	ConfigPath string `json:"config_path"`
+}
+
+// AgentDisconnectRequest - тело запроса POST /v1/clients/disconnect
+type AgentDisconnectRequest struct {
+	Name string `json:"name"`
+}
+
+// AgentBlockRequest - тело запроса POST /v1/clients/block
+type AgentBlockRequest struct {
+	Name    string `json:"name"`
+	Blocked bool   `json:"blocked"`
+}
+
+// AgentListResponse - ответ на GET /v1/clients
+type AgentListResponse struct {
+	Clients []string `json:"clients"`
+}
+
+// AgentStatusResponse - ответ на GET /v1/status
+type AgentStatusResponse struct {
+	Server  string `json:"server"`
+	Clients int    `json:"clients"`
+}
+
+// AgentConnectedResponse - ответ на GET /v1/connected
+type AgentConnectedResponse struct {
+	Clients []ConnectedClient `json:"clients"`
+}
+
+// AgentErrorResponse возвращается агентом при любой ошибке
+type AgentErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// AgentClient выпускает конфигурации на удаленном сервере через API агента (cmd/agent)
+type AgentClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewAgentClient создает клиента агента с взаимным TLS: certFile/keyFile - сертификат бота,
+// caFile - CA, которым подписан сертификат агента
+func NewAgentClient(baseURL, certFile, keyFile, caFile string) (*AgentClient, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load agent client certificate: %w", err)
+	}
+
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read agent CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+
+	return NewAgentClientWithTLS(baseURL, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS12,
+	}), nil
+}
+
+// NewAgentClientWithTLS создает клиента агента с готовой TLS конфигурацией
+func NewAgentClientWithTLS(baseURL string, tlsConfig *tls.Config) *AgentClient {
+	return &AgentClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client: &http.Client{
+			Timeout:   2 * time.Minute,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}
+}
+
+// CreateClient создает клиента на удаленном сервере.
+// Возвращаемый путь относится к файловой системе агента
+func (a *AgentClient) CreateClient(opts ClientOptions) (string, string, error) {
+	var resp AgentCreateResponse
+	if err := a.do(http.MethodPost, "/v1/clients", AgentCreateRequest{Owner: opts.Owner}, &resp); err != nil {
+		return "", "", fmt.Errorf("failed to create client: %w", err)
+	}
+	return resp.Name, resp.ConfigPath, nil
+}
+
+// RemoveClient отзывает клиента на удаленном сервере
+func (a *AgentClient) RemoveClient(clientName, configPath string) error {
+	req := AgentRevokeRequest{Name: clientName, ConfigPath: configPath}
+	if err := a.do(http.MethodPost, "/v1/clients/revoke", req, nil); err != nil {
+		return fmt.Errorf("failed to remove client: %w", err)
+	}
+	return nil
+}
+
+// DisconnectClient разрывает соединение клиента на удаленном сервере
+func (a *AgentClient) DisconnectClient(clientName string) error {
+	if err := a.do(http.MethodPost, "/v1/clients/disconnect", AgentDisconnectRequest{Name: clientName}, nil); err != nil {
+		return fmt.Errorf("failed to disconnect client: %w", err)
+	}
+	return nil
+}
+
+// ListClients возвращает клиентов удаленного сервера
+func (a *AgentClient) ListClients() ([]string, error) {
+	var resp AgentListResponse
+	if err := a.do(http.MethodGet, "/v1/clients", nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to list clients: %w", err)
+	}
+	return resp.Clients, nil
+}
+
+// ReadConfigFile скачивает .ovpn с удаленного сервера
+func (a *AgentClient) ReadConfigFile(configPath string) ([]byte, error) {
+	httpResp, err := a.client.Get(a.baseURL + "/v1/config?path=" + url.QueryEscape(configPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read config: %w", decodeAgentError(httpResp))
+	}
+	return io.ReadAll(httpResp.Body)
+}
+
+// Status возвращает состояние удаленного сервера
+func (a *AgentClient) Status() (*AgentStatusResponse, error) {
+	var resp AgentStatusResponse
+	if err := a.do(http.MethodGet, "/v1/status", nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to get agent status: %w", err)
+	}
+	return &resp, nil
+}
+
+// ConnectedClients возвращает клиентов, подключенных к удаленному серверу
+func (a *AgentClient) ConnectedClients() ([]ConnectedClient, error) {
+	var resp AgentConnectedResponse
+	if err := a.do(http.MethodGet, "/v1/connected", nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to get connected clients: %w", err)
+	}
+	return resp.Clients, nil
+}
+
+func (a *AgentClient) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, a.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	httpResp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode >= 300 {
+		return decodeAgentError(httpResp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(httpResp.Body).Decode(out)
+}
+
+func decodeAgentError(resp *http.Response) error {
+	var agentErr AgentErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&agentErr); err != nil || agentErr.Error == "" {
+		return fmt.Errorf("agent returned %s", resp.Status)
+	}
+	return fmt.Errorf("agent returned %s: %s", resp.Status, agentErr.Error)
+}
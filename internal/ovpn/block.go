@@ -0,0 +1,137 @@
+package ovpn
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Blocker - провижинер, который умеет временно запрещать подключение клиента
+// без отзыва сертификата (например, при превышении квоты трафика)
+type Blocker interface {
+	BlockClient(clientName string) error
+	UnblockClient(clientName string) error
+}
+
+// disableDirective запрещает подключение клиента в его client-config-dir файле
+const disableDirective = "disable"
+
+// SetCCDPath задает директорию client-config-dir сервера (директива client-config-dir в server.conf)
+func (s *Service) SetCCDPath(ccdPath string) {
+	s.ccdPath = ccdPath
+}
+
+// BlockClient добавляет директиву disable в CCD файл клиента. Уже подключенный
+// клиент отключается, а новые подключения отклоняются сервером
+func (s *Service) BlockClient(clientName string) error {
+	if s.ccdPath == "" {
+		return fmt.Errorf("client-config-dir is not configured")
+	}
+
+	path := filepath.Join(s.ccdPath, filepath.Base(clientName))
+	lines, err := readCCD(path)
+	if err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if strings.TrimSpace(line) == disableDirective {
+			return s.DisconnectClient(clientName)
+		}
+	}
+
+	lines = append(lines, disableDirective)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write client config dir file: %w", err)
+	}
+
+	return s.DisconnectClient(clientName)
+}
+
+// UnblockClient убирает директиву disable; остальные директивы CCD файла сохраняются
+func (s *Service) UnblockClient(clientName string) error {
+	if s.ccdPath == "" {
+		return fmt.Errorf("client-config-dir is not configured")
+	}
+
+	path := filepath.Join(s.ccdPath, filepath.Base(clientName))
+	lines, err := readCCD(path)
+	if err != nil {
+		return err
+	}
+
+	var kept []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) != disableDirective {
+			kept = append(kept, line)
+		}
+	}
+
+	if len(kept) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove client config dir file: %w", err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(kept, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write client config dir file: %w", err)
+	}
+	return nil
+}
+
+// readCCD возвращает непустые строки CCD файла; отсутствующий файл считается пустым
+func readCCD(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client config dir file: %w", err)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// BlockClient помечает клиента заблокированным
+func (m *MemoryProvisioner) BlockClient(clientName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.clients[clientName]; !exists {
+		return fmt.Errorf("client %s not found", clientName)
+	}
+	m.blocked[clientName] = true
+	return nil
+}
+
+// UnblockClient снимает блокировку клиента
+func (m *MemoryProvisioner) UnblockClient(clientName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.blocked, clientName)
+	return nil
+}
+
+// BlockClient блокирует клиента на удаленном сервере
+func (a *AgentClient) BlockClient(clientName string) error {
+	if err := a.do(http.MethodPost, "/v1/clients/block", AgentBlockRequest{Name: clientName, Blocked: true}, nil); err != nil {
+		return fmt.Errorf("failed to block client: %w", err)
+	}
+	return nil
+}
+
+// UnblockClient снимает блокировку клиента на удаленном сервере
+func (a *AgentClient) UnblockClient(clientName string) error {
+	if err := a.do(http.MethodPost, "/v1/clients/block", AgentBlockRequest{Name: clientName, Blocked: false}, nil); err != nil {
+		return fmt.Errorf("failed to unblock client: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,380 @@
+package ovpn
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrManagementClosed возвращается командами после закрытия соединения
+var ErrManagementClosed = errors.New("management connection closed")
+
+// Типы уведомлений management интерфейса (строки, начинающиеся с '>')
+const (
+	EventClient       = "CLIENT"
+	EventByteCount    = "BYTECOUNT"
+	EventByteCountCli = "BYTECOUNT_CLI"
+	EventInfo         = "INFO"
+)
+
+// ManagementEvent - асинхронное уведомление от OpenVPN
+type ManagementEvent struct {
+	Type string
+	// Client заполнен для >CLIENT: уведомлений
+	Client *ClientEvent
+	// ByteCount заполнен для >BYTECOUNT: и >BYTECOUNT_CLI: уведомлений
+	ByteCount *ByteCountEvent
+	// Raw - текст уведомления без префикса ">TYPE:"
+	Raw string
+}
+
+// ClientEvent - событие клиента (CONNECT, REAUTH, ESTABLISHED, DISCONNECT, ADDRESS)
+type ClientEvent struct {
+	Action   string
+	ClientID int64
+	KeyID    int64
+	// Env - переменные окружения клиента (common_name, trusted_ip, bytes_received и т.д.)
+	Env map[string]string
+}
+
+// CommonName возвращает CN клиента из переменных окружения события
+func (e *ClientEvent) CommonName() string {
+	return e.Env["common_name"]
+}
+
+// Session возвращает сессию клиента в том же виде, что и status: адрес и время
+// подключения совпадают со строкой CLIENT_LIST. Счетчики трафика есть только в DISCONNECT
+func (e *ClientEvent) Session() ConnectedClient {
+	client := ConnectedClient{
+		CommonName:     e.CommonName(),
+		VirtualAddress: e.Env["ifconfig_pool_remote_ip"],
+		VirtualIPv6:    e.Env["ifconfig_pool_remote_ip6"],
+		ClientID:       e.ClientID,
+	}
+
+	ip := e.Env["trusted_ip"]
+	if ip == "" {
+		ip = e.Env["trusted_ip6"]
+	}
+	client.RealAddress = ip
+	if port := e.Env["trusted_port"]; port != "" {
+		client.RealAddress = ip + ":" + port
+	}
+
+	client.BytesReceived, _ = strconv.ParseInt(e.Env["bytes_received"], 10, 64)
+	client.BytesSent, _ = strconv.ParseInt(e.Env["bytes_sent"], 10, 64)
+	if ts, err := strconv.ParseInt(e.Env["time_unix"], 10, 64); err == nil {
+		client.ConnectedSince = time.Unix(ts, 0)
+	}
+	return client
+}
+
+// ByteCountEvent - счетчики трафика. ClientID равен -1 для >BYTECOUNT: (режим клиента).
+// Для сервера BytesIn получено от клиента, BytesOut отправлено ему
+type ByteCountEvent struct {
+	ClientID int64
+	BytesIn  int64
+	BytesOut int64
+}
+
+// Management - клиент management интерфейса OpenVPN (директива management в server.conf)
+type Management struct {
+	conn net.Conn
+
+	// cmdMu сериализует команды: ответы приходят в порядке запросов
+	cmdMu     sync.Mutex
+	responses chan string
+	events    chan ManagementEvent
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// Текущее многострочное >CLIENT: уведомление
+	pendingClient *ClientEvent
+}
+
+// DialManagement подключается к management интерфейсу. address - "host:port"
+// или "unix:/path/to/socket"; password нужен, если сервер запущен с файлом пароля
+func DialManagement(address, password string, timeout time.Duration) (*Management, error) {
+	network := "tcp"
+	if strings.HasPrefix(address, "unix:") {
+		network = "unix"
+		address = strings.TrimPrefix(address, "unix:")
+	}
+
+	conn, err := net.DialTimeout(network, address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to management interface: %w", err)
+	}
+
+	return NewManagement(conn, password)
+}
+
+// NewManagement запускает протокол management поверх готового соединения
+func NewManagement(conn net.Conn, password string) (*Management, error) {
+	reader := bufio.NewReader(conn)
+
+	if password != "" {
+		// Приглашение "ENTER PASSWORD:" приходит без перевода строки
+		conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		prompt, err := reader.ReadString(':')
+		if err != nil || !strings.Contains(prompt, "ENTER PASSWORD") {
+			conn.Close()
+			return nil, fmt.Errorf("management interface did not ask for password")
+		}
+		if _, err := fmt.Fprintf(conn, "%s\n", password); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to send management password: %w", err)
+		}
+		reply, err := reader.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to authenticate: %w", err)
+		}
+		if !strings.HasPrefix(strings.TrimSpace(reply), "SUCCESS") {
+			conn.Close()
+			return nil, fmt.Errorf("management authentication failed: %s", strings.TrimSpace(reply))
+		}
+		conn.SetReadDeadline(time.Time{})
+	}
+
+	m := &Management{
+		conn:      conn,
+		responses: make(chan string, 64),
+		events:    make(chan ManagementEvent, 256),
+		done:      make(chan struct{}),
+	}
+	go m.readLoop(reader)
+
+	return m, nil
+}
+
+// Events возвращает канал асинхронных уведомлений. Канал закрывается после разрыва соединения.
+// Если уведомления не вычитываются, новые отбрасываются
+func (m *Management) Events() <-chan ManagementEvent {
+	return m.events
+}
+
+// Done закрывается, когда соединение разорвано
+func (m *Management) Done() <-chan struct{} {
+	return m.done
+}
+
+// Close закрывает соединение
+func (m *Management) Close() error {
+	err := m.conn.Close()
+	m.shutdown()
+	return err
+}
+
+// Status возвращает подключенных клиентов (команда "status 3")
+func (m *Management) Status() ([]ConnectedClient, error) {
+	lines, err := m.commandMultiline("status 3")
+	if err != nil {
+		return nil, err
+	}
+	return ParseStatus(strings.NewReader(strings.Join(lines, "\n")))
+}
+
+// Kill отключает всех клиентов с указанным CN
+func (m *Management) Kill(commonName string) error {
+	_, err := m.command("kill " + commonName)
+	return err
+}
+
+// ClientKill отключает клиента по CID (из status или >CLIENT: уведомлений)
+func (m *Management) ClientKill(clientID int64) error {
+	_, err := m.command(fmt.Sprintf("client-kill %d", clientID))
+	return err
+}
+
+// ByteCount включает уведомления >BYTECOUNT_CLI: с интервалом в секундах (0 - выключить)
+func (m *Management) ByteCount(interval int) error {
+	_, err := m.command(fmt.Sprintf("bytecount %d", interval))
+	return err
+}
+
+// command отправляет команду с однострочным ответом SUCCESS:/ERROR:
+func (m *Management) command(cmd string) (string, error) {
+	m.cmdMu.Lock()
+	defer m.cmdMu.Unlock()
+
+	if err := m.send(cmd); err != nil {
+		return "", err
+	}
+
+	line, err := m.readResponse()
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(line, "ERROR:") {
+		return "", fmt.Errorf("management command %q failed: %s", cmd, strings.TrimSpace(strings.TrimPrefix(line, "ERROR:")))
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "SUCCESS:")), nil
+}
+
+// commandMultiline отправляет команду, ответ на которую заканчивается строкой END
+func (m *Management) commandMultiline(cmd string) ([]string, error) {
+	m.cmdMu.Lock()
+	defer m.cmdMu.Unlock()
+
+	if err := m.send(cmd); err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for {
+		line, err := m.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(line, "ERROR:") {
+			return nil, fmt.Errorf("management command %q failed: %s", cmd, strings.TrimSpace(strings.TrimPrefix(line, "ERROR:")))
+		}
+		if line == "END" {
+			return lines, nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+func (m *Management) send(cmd string) error {
+	select {
+	case <-m.done:
+		return ErrManagementClosed
+	default:
+	}
+
+	m.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := io.WriteString(m.conn, cmd+"\n"); err != nil {
+		return fmt.Errorf("failed to send management command: %w", err)
+	}
+	return nil
+}
+
+func (m *Management) readResponse() (string, error) {
+	select {
+	case line, ok := <-m.responses:
+		if !ok {
+			return "", ErrManagementClosed
+		}
+		return line, nil
+	case <-time.After(30 * time.Second):
+		return "", fmt.Errorf("management command timed out")
+	}
+}
+
+// readLoop разделяет поток на ответы команд и асинхронные уведомления
+// (строки, начинающиеся с '>'): уведомления могут прийти между строками ответа
+func (m *Management) readLoop(reader *bufio.Reader) {
+	// Каналы закрывает только readLoop - единственный отправитель
+	defer func() {
+		m.shutdown()
+		close(m.responses)
+		close(m.events)
+	}()
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if strings.HasPrefix(line, ">") {
+			m.handleNotification(line[1:])
+			continue
+		}
+
+		select {
+		case m.responses <- line:
+		case <-m.done:
+			return
+		}
+	}
+}
+
+func (m *Management) handleNotification(line string) {
+	eventType, payload, _ := strings.Cut(line, ":")
+
+	switch eventType {
+	case EventClient:
+		m.handleClientNotification(payload)
+	case EventByteCount, EventByteCountCli:
+		fields := strings.Split(payload, ",")
+		event := &ByteCountEvent{ClientID: -1}
+		if eventType == EventByteCountCli {
+			if len(fields) != 3 {
+				return
+			}
+			event.ClientID, _ = strconv.ParseInt(fields[0], 10, 64)
+			fields = fields[1:]
+		}
+		if len(fields) != 2 {
+			return
+		}
+		event.BytesIn, _ = strconv.ParseInt(fields[0], 10, 64)
+		event.BytesOut, _ = strconv.ParseInt(fields[1], 10, 64)
+		m.emit(ManagementEvent{Type: eventType, ByteCount: event, Raw: payload})
+	default:
+		m.emit(ManagementEvent{Type: eventType, Raw: payload})
+	}
+}
+
+// handleClientNotification собирает >CLIENT:CONNECT ... >CLIENT:ENV,END в одно событие
+func (m *Management) handleClientNotification(payload string) {
+	fields := strings.SplitN(payload, ",", 2)
+	action := fields[0]
+
+	if action == "ENV" {
+		if m.pendingClient == nil || len(fields) < 2 {
+			return
+		}
+		if fields[1] == "END" {
+			event := m.pendingClient
+			m.pendingClient = nil
+			m.emit(ManagementEvent{Type: EventClient, Client: event, Raw: payload})
+			return
+		}
+		key, value, _ := strings.Cut(fields[1], "=")
+		m.pendingClient.Env[key] = value
+		return
+	}
+
+	event := &ClientEvent{Action: action, ClientID: -1, KeyID: -1, Env: make(map[string]string)}
+	if len(fields) == 2 {
+		ids := strings.Split(fields[1], ",")
+		event.ClientID, _ = strconv.ParseInt(ids[0], 10, 64)
+		// KID есть только у событий аутентификации; у ADDRESS дальше идет адрес
+		if len(ids) > 1 && (action == "CONNECT" || action == "REAUTH") {
+			event.KeyID, _ = strconv.ParseInt(ids[1], 10, 64)
+		}
+	}
+
+	// ADDRESS приходит одной строкой, остальные события сопровождаются блоком ENV
+	if action == "ADDRESS" {
+		m.emit(ManagementEvent{Type: EventClient, Client: event, Raw: payload})
+		return
+	}
+	m.pendingClient = event
+}
+
+func (m *Management) emit(event ManagementEvent) {
+	select {
+	case m.events <- event:
+	default:
+		// Никто не читает уведомления - не блокируем разбор ответов
+	}
+}
+
+func (m *Management) shutdown() {
+	m.closeOnce.Do(func() {
+		close(m.done)
+		m.conn.Close()
+	})
+}
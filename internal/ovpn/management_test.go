@@ -0,0 +1,393 @@
+package ovpn
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testManagementPassword = "mgmt-secret"
+
+// managementStatus - ответ на "status 3" с уведомлениями, которые OpenVPN
+// может прислать между строками ответа
+var managementStatus = strings.Join([]string{
+	"TITLE\tOpenVPN 2.6.8 x86_64-pc-linux-gnu",
+	"TIME\t2024-05-01 12:00:00\t1714564800",
+	"HEADER\tCLIENT_LIST\tCommon Name\tReal Address\tVirtual Address\tVirtual IPv6 Address\tBytes Received\tBytes Sent\tConnected Since\tConnected Since (time_t)\tUsername\tClient ID\tPeer ID\tData Channel Cipher",
+	">BYTECOUNT_CLI:3,1024,2048",
+	"CLIENT_LIST\talice\t203.0.113.5:51000\t10.8.0.2\t\t1024\t2048\t2024-05-01 11:00:00\t1714561200\tUNDEF\t3\t0\tAES-256-GCM",
+	">CLIENT:ESTABLISHED,3",
+	">CLIENT:ENV,common_name=alice",
+	">CLIENT:ENV,END",
+	"HEADER\tROUTING_TABLE\tVirtual Address\tCommon Name\tReal Address\tLast Ref\tLast Ref (time_t)",
+	"ROUTING_TABLE\t10.8.0.2\talice\t203.0.113.5:51000\t2024-05-01 12:00:00\t1714564800",
+	"GLOBAL_STATS\tMax bcast/mcast queue length\t0",
+	"END",
+}, "\n")
+
+// managementSessionEvents - уведомления, которые фейковый сервер присылает после
+// включения bytecount: счетчики сессии alice (CID 3), затем ее отключение
+var managementSessionEvents = strings.Join([]string{
+	">BYTECOUNT_CLI:3,4096,8192",
+	">BYTECOUNT_CLI:7,1,1",
+	">CLIENT:DISCONNECT,3",
+	">CLIENT:ENV,common_name=alice",
+	">CLIENT:ENV,trusted_ip=203.0.113.5",
+	">CLIENT:ENV,trusted_port=51000",
+	">CLIENT:ENV,time_unix=1714561200",
+	">CLIENT:ENV,bytes_received=5000",
+	">CLIENT:ENV,bytes_sent=9000",
+	">CLIENT:ENV,END",
+}, "\n")
+
+// fakeManagement - management интерфейс OpenVPN с фиксированными ответами
+type fakeManagement struct {
+	listener net.Listener
+	password string
+	// hangUp закрывает соединение сразу после входа
+	hangUp bool
+	// commands получает команды в порядке поступления
+	commands chan string
+	// connections - число принятых соединений
+	connections atomic.Int32
+}
+
+func newFakeManagement(t *testing.T, password string, hangUp bool) *fakeManagement {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	f := &fakeManagement{listener: listener, password: password, hangUp: hangUp, commands: make(chan string, 16)}
+	t.Cleanup(func() { listener.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeManagement) addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeManagement) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		f.connections.Add(1)
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeManagement) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	if f.password != "" {
+		fmt.Fprint(conn, "ENTER PASSWORD:")
+		password, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if strings.TrimSpace(password) != f.password {
+			fmt.Fprint(conn, "ERROR: bad password\r\n")
+			return
+		}
+		fmt.Fprint(conn, "SUCCESS: password is correct\r\n")
+	}
+	fmt.Fprint(conn, ">INFO:OpenVPN Management Interface Version 5 -- type 'help' for more info\r\n")
+	if f.hangUp {
+		return
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		f.commands <- cmd
+
+		switch {
+		case cmd == "status 3":
+			fmt.Fprint(conn, strings.ReplaceAll(managementStatus, "\n", "\r\n")+"\r\n")
+		case cmd == "kill alice":
+			fmt.Fprint(conn, ">BYTECOUNT_CLI:3,1024,2048\r\n")
+			fmt.Fprint(conn, "SUCCESS: common name 'alice' found, 1 client(s) killed\r\n")
+		case strings.HasPrefix(cmd, "kill "):
+			fmt.Fprintf(conn, "ERROR: common name '%s' not found\r\n", strings.TrimPrefix(cmd, "kill "))
+		case strings.HasPrefix(cmd, "bytecount "):
+			fmt.Fprint(conn, "SUCCESS: bytecount interval changed\r\n")
+			if cmd != "bytecount 0" {
+				fmt.Fprint(conn, strings.ReplaceAll(managementSessionEvents, "\n", "\r\n")+"\r\n")
+			}
+		case cmd == "client-kill 3":
+			fmt.Fprint(conn, "SUCCESS: client-kill command succeeded\r\n")
+		case strings.HasPrefix(cmd, "client-kill "):
+			fmt.Fprint(conn, "ERROR: client-kill command failed\r\n")
+		case cmd == "status 2":
+			fmt.Fprint(conn, "ERROR: status command failed\r\n")
+		default:
+			fmt.Fprint(conn, "ERROR: unknown command, enter 'help' for more options\r\n")
+		}
+	}
+}
+
+func TestManagementStatus(t *testing.T) {
+	f := newFakeManagement(t, testManagementPassword, false)
+
+	m, err := DialManagement(f.addr(), testManagementPassword, time.Second)
+	if err != nil {
+		t.Fatalf("DialManagement: %v", err)
+	}
+	defer m.Close()
+
+	// Две команды подряд: уведомления не должны сдвигать ответы
+	for i := 0; i < 2; i++ {
+		clients, err := m.Status()
+		if err != nil {
+			t.Fatalf("Status: %v", err)
+		}
+		if len(clients) != 1 {
+			t.Fatalf("Status returned %d clients, want 1", len(clients))
+		}
+		client := clients[0]
+		if client.CommonName != "alice" || client.VirtualAddress != "10.8.0.2" || client.ClientID != 3 ||
+			client.BytesReceived != 1024 || client.BytesSent != 2048 || client.ConnectedSince.Unix() != 1714561200 {
+			t.Errorf("client = %+v", client)
+		}
+	}
+}
+
+func TestManagementCommandErrors(t *testing.T) {
+	f := newFakeManagement(t, "", false)
+
+	m, err := DialManagement(f.addr(), "", time.Second)
+	if err != nil {
+		t.Fatalf("DialManagement: %v", err)
+	}
+	defer m.Close()
+
+	if err := m.Kill("alice"); err != nil {
+		t.Errorf("Kill(alice): %v", err)
+	}
+	if err := m.Kill("bob"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Kill(bob) = %v, want not found", err)
+	}
+	if _, err := m.commandMultiline("status 2"); err == nil {
+		t.Error("failed multiline command returned no error")
+	}
+	if err := m.ClientKill(3); err != nil {
+		t.Errorf("ClientKill(3): %v", err)
+	}
+	if err := m.ClientKill(4); err == nil {
+		t.Error("ClientKill(4) returned no error")
+	}
+
+	for _, want := range []string{"kill alice", "kill bob", "status 2", "client-kill 3", "client-kill 4"} {
+		if cmd := <-f.commands; cmd != want {
+			t.Errorf("server received %q, want %q", cmd, want)
+		}
+	}
+}
+
+func TestManagementWrongPassword(t *testing.T) {
+	f := newFakeManagement(t, testManagementPassword, false)
+
+	if _, err := DialManagement(f.addr(), "wrong", time.Second); err == nil {
+		t.Fatal("DialManagement succeeded with wrong password")
+	}
+}
+
+func TestManagementClosed(t *testing.T) {
+	f := newFakeManagement(t, "", true)
+
+	m, err := DialManagement(f.addr(), "", time.Second)
+	if err != nil {
+		t.Fatalf("DialManagement: %v", err)
+	}
+
+	select {
+	case <-m.done:
+	case <-time.After(time.Second):
+		t.Fatal("connection close was not detected")
+	}
+	if _, err := m.Status(); !errors.Is(err, ErrManagementClosed) {
+		t.Errorf("Status after hang up = %v, want ErrManagementClosed", err)
+	}
+	m.Close()
+}
+
+func TestServiceManagement(t *testing.T) {
+	f := newFakeManagement(t, testManagementPassword, false)
+
+	s := New(t.TempDir(), t.TempDir(), "client")
+	s.SetManagement(f.addr(), testManagementPassword)
+
+	clients, err := s.ConnectedClients()
+	if err != nil {
+		t.Fatalf("ConnectedClients: %v", err)
+	}
+	if len(clients) != 1 || clients[0].CommonName != "alice" {
+		t.Errorf("ConnectedClients = %+v", clients)
+	}
+
+	// Клиент, который уже отключен, не считается ошибкой
+	for _, name := range []string{"alice", "bob"} {
+		if err := s.DisconnectClient(name); err != nil {
+			t.Errorf("DisconnectClient(%s): %v", name, err)
+		}
+	}
+}
+
+// nextEvent ждет следующего уведомления, пропуская >INFO: приветствие
+func nextEvent(t *testing.T, m *Management) ManagementEvent {
+	t.Helper()
+	for {
+		select {
+		case event, ok := <-m.Events():
+			if !ok {
+				t.Fatal("events channel closed")
+			}
+			if event.Type == EventInfo {
+				continue
+			}
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("no management event")
+		}
+	}
+}
+
+func TestManagementEvents(t *testing.T) {
+	f := newFakeManagement(t, "", false)
+
+	m, err := DialManagement(f.addr(), "", time.Second)
+	if err != nil {
+		t.Fatalf("DialManagement: %v", err)
+	}
+	defer m.Close()
+
+	if err := m.ByteCount(5); err != nil {
+		t.Fatalf("ByteCount: %v", err)
+	}
+
+	event := nextEvent(t, m)
+	if event.Type != EventByteCountCli || event.ByteCount == nil ||
+		*event.ByteCount != (ByteCountEvent{ClientID: 3, BytesIn: 4096, BytesOut: 8192}) {
+		t.Fatalf("first event = %+v", event)
+	}
+	nextEvent(t, m)
+
+	// Многострочное уведомление собирается в одно событие
+	event = nextEvent(t, m)
+	if event.Type != EventClient || event.Client == nil {
+		t.Fatalf("client event = %+v", event)
+	}
+	client := event.Client
+	if client.Action != "DISCONNECT" || client.ClientID != 3 || client.KeyID != -1 || client.CommonName() != "alice" {
+		t.Errorf("client event = %+v", client)
+	}
+
+	session := client.Session()
+	if session.CommonName != "alice" || session.RealAddress != "203.0.113.5:51000" || session.ClientID != 3 ||
+		session.BytesReceived != 5000 || session.BytesSent != 9000 || session.ConnectedSince.Unix() != 1714561200 {
+		t.Errorf("session = %+v", session)
+	}
+
+	m.Close()
+	closed := make(chan struct{})
+	go func() {
+		for range m.Events() {
+		}
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("events channel was not closed")
+	}
+}
+
+func TestManagementNotifications(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  []ManagementEvent
+	}{
+		{
+			name:  "client bytecount",
+			lines: []string{"BYTECOUNT:100,200"},
+			want:  []ManagementEvent{{Type: EventByteCount, ByteCount: &ByteCountEvent{ClientID: -1, BytesIn: 100, BytesOut: 200}, Raw: "100,200"}},
+		},
+		{
+			name:  "bytecount without client id",
+			lines: []string{"BYTECOUNT_CLI:3,100"},
+		},
+		{
+			name:  "malformed bytecount",
+			lines: []string{"BYTECOUNT_CLI:1,2,3,4", "BYTECOUNT:1"},
+		},
+		{
+			name:  "address",
+			lines: []string{"CLIENT:ADDRESS,5,10.8.0.6,1"},
+			want: []ManagementEvent{{Type: EventClient, Raw: "ADDRESS,5,10.8.0.6,1",
+				Client: &ClientEvent{Action: "ADDRESS", ClientID: 5, KeyID: -1, Env: map[string]string{}}}},
+		},
+		{
+			name:  "connect with key id",
+			lines: []string{"CLIENT:CONNECT,4,1", "CLIENT:ENV,common_name=bob", "CLIENT:ENV,untrusted_ip=198.51.100.7", "CLIENT:ENV,END"},
+			want: []ManagementEvent{{Type: EventClient, Raw: "ENV,END",
+				Client: &ClientEvent{Action: "CONNECT", ClientID: 4, KeyID: 1,
+					Env: map[string]string{"common_name": "bob", "untrusted_ip": "198.51.100.7"}}}},
+		},
+		{
+			name:  "env without event",
+			lines: []string{"CLIENT:ENV,common_name=bob", "CLIENT:ENV,END"},
+		},
+		{
+			name:  "other",
+			lines: []string{"HOLD:Waiting for hold release:0"},
+			want:  []ManagementEvent{{Type: "HOLD", Raw: "Waiting for hold release:0"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Management{events: make(chan ManagementEvent, 16)}
+			for _, line := range tt.lines {
+				m.handleNotification(line)
+			}
+			close(m.events)
+
+			var got []ManagementEvent
+			for event := range m.events {
+				got = append(got, event)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %s, want %s", describeEvents(got), describeEvents(tt.want))
+			}
+		})
+	}
+}
+
+func describeEvents(events []ManagementEvent) string {
+	var parts []string
+	for _, event := range events {
+		part := fmt.Sprintf("%s %q", event.Type, event.Raw)
+		if event.Client != nil {
+			part += fmt.Sprintf(" client=%+v", *event.Client)
+		}
+		if event.ByteCount != nil {
+			part += fmt.Sprintf(" bytecount=%+v", *event.ByteCount)
+		}
+		parts = append(parts, part)
+	}
+	return "[" + strings.Join(parts, "; ") + "]"
+}
@@ -0,0 +1,96 @@
+package ovpn
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+)
+
+// MemoryProvisioner хранит клиентов и их конфигурации в памяти.
+// Используется в тестах и для пробного запуска бота без OpenVPN сервера
+type MemoryProvisioner struct {
+	configsPath  string
+	configPrefix string
+
+	mu      sync.Mutex
+	clients map[string]string
+	files   map[string][]byte
+	blocked map[string]bool
+}
+
+// NewMemory создает провижинер с пустой "файловой системой" в памяти
+func NewMemory(configsPath, configPrefix string) *MemoryProvisioner {
+	return &MemoryProvisioner{
+		configsPath:  configsPath,
+		configPrefix: configPrefix,
+		clients:      make(map[string]string),
+		files:        make(map[string][]byte),
+		blocked:      make(map[string]bool),
+	}
+}
+
+// CreateClient регистрирует клиента и сохраняет заглушку .ovpn
+func (m *MemoryProvisioner) CreateClient(opts ClientOptions) (string, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clientName, err := generateRandomName(m.configPrefix, func(name string) (bool, error) {
+		_, exists := m.clients[name]
+		return exists, nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	configPath := path.Join(m.configsPath, clientName+".ovpn")
+	m.clients[clientName] = configPath
+	m.files[configPath] = []byte(fmt.Sprintf("# dry-run profile\n# Owner: %s\nclient\nremote 127.0.0.1 1194\n# CN=%s\n", opts.Owner, clientName))
+
+	return clientName, configPath, nil
+}
+
+// RemoveClient удаляет клиента и его конфигурацию
+func (m *MemoryProvisioner) RemoveClient(clientName, configPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.clients[clientName]; !exists {
+		return fmt.Errorf("client %s not found", clientName)
+	}
+
+	delete(m.clients, clientName)
+	delete(m.files, configPath)
+	delete(m.blocked, clientName)
+	return nil
+}
+
+// ListClients возвращает отсортированный список клиентов
+func (m *MemoryProvisioner) ListClients() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clients := make([]string, 0, len(m.clients))
+	for name := range m.clients {
+		clients = append(clients, name)
+	}
+	sort.Strings(clients)
+	return clients, nil
+}
+
+// ReadConfigFile возвращает сохраненную конфигурацию
+func (m *MemoryProvisioner) ReadConfigFile(configPath string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, ok := m.files[configPath]
+	if !ok {
+		return nil, fmt.Errorf("config file not found: %s", configPath)
+	}
+	return data, nil
+}
+
+// ConnectedClients всегда возвращает пустой список: в памяти никто не подключен
+func (m *MemoryProvisioner) ConnectedClients() ([]ConnectedClient, error) {
+	return nil, nil
+}
@@ -0,0 +1,123 @@
+package ovpn
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"go-ovpn-bot/internal/config"
+)
+
+func TestMemoryProvisionerLifecycle(t *testing.T) {
+	m := NewMemory("/etc/openvpn/client", "client")
+
+	name, configPath, err := m.CreateClient(ClientOptions{Owner: "user 42"})
+	if err != nil {
+		t.Fatalf("CreateClient: %v", err)
+	}
+	if !strings.HasPrefix(name, "client") || configPath != "/etc/openvpn/client/"+name+".ovpn" {
+		t.Errorf("CreateClient = %q, %q", name, configPath)
+	}
+
+	data, err := m.ReadConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("ReadConfigFile: %v", err)
+	}
+	if !strings.Contains(string(data), "user 42") || !strings.Contains(string(data), "CN="+name) {
+		t.Errorf("config:\n%s", data)
+	}
+
+	if err := m.BlockClient(name); err != nil {
+		t.Fatalf("BlockClient: %v", err)
+	}
+	if !m.blocked[name] {
+		t.Error("client is not blocked")
+	}
+	if err := m.UnblockClient(name); err != nil {
+		t.Fatalf("UnblockClient: %v", err)
+	}
+	if m.blocked[name] {
+		t.Error("client is still blocked")
+	}
+
+	if err := m.RemoveClient(name, configPath); err != nil {
+		t.Fatalf("RemoveClient: %v", err)
+	}
+	if clients, _ := m.ListClients(); len(clients) != 0 {
+		t.Errorf("ListClients after remove = %v", clients)
+	}
+	if _, err := m.ReadConfigFile(configPath); err == nil {
+		t.Error("config of removed client is still readable")
+	}
+	if err := m.RemoveClient(name, configPath); err == nil {
+		t.Error("RemoveClient of removed client succeeded")
+	}
+	if err := m.BlockClient(name); err == nil {
+		t.Error("BlockClient of removed client succeeded")
+	}
+}
+
+func TestMemoryProvisionerUniqueNames(t *testing.T) {
+	const clients = 50
+
+	m := NewMemory("/configs", "vpn")
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		names []string
+	)
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name, _, err := m.CreateClient(ClientOptions{})
+			if err != nil {
+				t.Errorf("CreateClient: %v", err)
+				return
+			}
+			mu.Lock()
+			names = append(names, name)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	listed, err := m.ListClients()
+	if err != nil {
+		t.Fatalf("ListClients: %v", err)
+	}
+	sort.Strings(names)
+	if strings.Join(listed, ",") != strings.Join(names, ",") || len(listed) != clients {
+		t.Errorf("ListClients returned %d clients, created %d", len(listed), len(names))
+	}
+	for i := 1; i < len(listed); i++ {
+		if listed[i] == listed[i-1] {
+			t.Errorf("duplicate client name %s", listed[i])
+		}
+	}
+}
+
+func TestNewProvisionerMemory(t *testing.T) {
+	registry, err := NewRegistryFromConfig([]config.Server{
+		{Name: "dry-run", Backend: config.BackendMemory, ConfigsPath: "/configs", ConfigPrefix: "client"},
+	})
+	if err != nil {
+		t.Fatalf("NewRegistryFromConfig: %v", err)
+	}
+
+	server, ok := registry.Get("dry-run")
+	if !ok {
+		t.Fatal("server is not registered")
+	}
+	if _, ok := server.Provisioner.(*MemoryProvisioner); !ok {
+		t.Fatalf("provisioner is %T", server.Provisioner)
+	}
+
+	// Пробный запуск бота не подключает клиентов
+	clients, err := server.Provisioner.(StatusSource).ConnectedClients()
+	if err != nil || len(clients) != 0 {
+		t.Errorf("ConnectedClients = %v, %v", clients, err)
+	}
+}
@@ -0,0 +1,94 @@
+package ovpn
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// createClientNative выпускает сертификат через PKI и сохраняет .ovpn с встроенными ключами
+func (s *Service) createClientNative(clientName string, opts ClientOptions) (string, error) {
+	issued, err := s.pki.IssueClient(clientName)
+	if err != nil {
+		return "", fmt.Errorf("failed to issue certificate: %w", err)
+	}
+
+	profile, err := s.renderer.Render(issued, opts.Owner)
+	if err != nil {
+		// Не оставляем действующий сертификат без конфигурации
+		s.pki.RevokeClient(clientName)
+		return "", fmt.Errorf("failed to render client config: %w", err)
+	}
+
+	configPath, err := filepath.Abs(filepath.Join(s.configsPath, clientName+".ovpn"))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve config path: %w", err)
+	}
+
+	if err := os.WriteFile(configPath, profile, 0600); err != nil {
+		s.pki.RevokeClient(clientName)
+		return "", fmt.Errorf("failed to write client config: %w", err)
+	}
+
+	return configPath, nil
+}
+
+// rerenderClientNative заново собирает профиль по сертификату и ключу из PKI, если .ovpn
+// был удален, и сохраняет его на место. Комментарий с владельцем в новый профиль не попадает
+func (s *Service) rerenderClientNative(configPath string) ([]byte, error) {
+	clientName := strings.TrimSuffix(filepath.Base(configPath), ".ovpn")
+	issued, err := s.pki.LoadClient(clientName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+
+	profile, err := s.renderer.Render(issued, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render client config: %w", err)
+	}
+
+	if err := os.WriteFile(configPath, profile, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write client config: %w", err)
+	}
+	return profile, nil
+}
+
+// removeClientNative отзывает сертификат, обновляет CRL сервера и удаляет .ovpn
+func (s *Service) removeClientNative(clientName, configPath string) error {
+	if err := s.pki.RevokeClient(clientName); err != nil {
+		return fmt.Errorf("failed to revoke client: %w", err)
+	}
+
+	crl, err := os.ReadFile(s.pki.CRLPath())
+	if err != nil {
+		return fmt.Errorf("failed to read CRL: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.openvpnDir, "crl.pem"), crl, 0644); err != nil {
+		return fmt.Errorf("failed to install CRL: %w", err)
+	}
+
+	if configPath != "" {
+		os.Remove(configPath)
+	}
+
+	// Освобождаем IP из пула как remove.sh
+	removeLinePrefix(filepath.Join(s.openvpnDir, "ipp.txt"), clientName+",")
+
+	return nil
+}
+
+func removeLinePrefix(path, prefix string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	var kept []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, prefix) {
+			kept = append(kept, line)
+		}
+	}
+	os.WriteFile(path, []byte(strings.Join(kept, "\n")), 0644)
+}
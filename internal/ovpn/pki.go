@@ -0,0 +1,563 @@
+package ovpn
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Формат дат в index.txt (как у openssl ca)
+const indexTimeLayout = "060102150405Z"
+
+const (
+	defaultCertExpireDays = 3650
+	defaultCRLDays        = 3650
+)
+
+// PKI выпускает и отзывает клиентские сертификаты напрямую в директории easy-rsa,
+// без вызова ./easyrsa и sudo
+type PKI struct {
+	dir    string
+	caCert *x509.Certificate
+	caKey  crypto.Signer
+	caPEM  []byte
+
+	// CertExpireDays - срок действия клиентского сертификата в днях
+	CertExpireDays int
+	// CRLDays - срок действия списка отзыва в днях
+	CRLDays int
+
+	mu sync.Mutex
+}
+
+// IssuedCert содержит выпущенный клиентский сертификат и ключ в PEM
+type IssuedCert struct {
+	Name    string
+	Serial  string
+	CertPEM []byte
+	KeyPEM  []byte
+	CAPEM   []byte
+}
+
+type indexEntry struct {
+	status  string
+	expires string
+	revoked string
+	serial  string
+	file    string
+	subject string
+}
+
+// NewPKI загружает CA из директории pki easy-rsa (обычно /etc/openvpn/easy-rsa/pki)
+func NewPKI(dir string) (*PKI, error) {
+	caPEM, err := os.ReadFile(filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+	}
+
+	block, _ := pem.Decode(caPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("invalid CA certificate PEM")
+	}
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+
+	keyPEM, err := os.ReadFile(filepath.Join(dir, "private", "ca.key"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA key: %w", err)
+	}
+	caKey, err := parsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA key: %w", err)
+	}
+
+	return &PKI{
+		dir:            dir,
+		caCert:         caCert,
+		caKey:          caKey,
+		caPEM:          caPEM,
+		CertExpireDays: defaultCertExpireDays,
+		CRLDays:        defaultCRLDays,
+	}, nil
+}
+
+// CAPEM возвращает сертификат CA в PEM
+func (p *PKI) CAPEM() []byte {
+	return p.caPEM
+}
+
+// IssueClient выпускает клиентский сертификат с CN=name и регистрирует его в index.txt
+func (p *PKI) IssueClient(name string) (*IssuedCert, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entries, err := p.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.status == "V" && e.commonName() == name {
+			return nil, fmt.Errorf("client %s already exists", name)
+		}
+	}
+
+	key, err := p.generateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate client key: %w", err)
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial: %w", err)
+	}
+
+	skid, err := subjectKeyID(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.AddDate(0, 0, p.CertExpireDays),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+		SubjectKeyId:          skid,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, p.caCert, key.Public(), p.caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign client certificate: %w", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal client key: %w", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	serialHex := serialString(serial)
+
+	if err := p.writeFile(filepath.Join("issued", name+".crt"), certPEM, 0644); err != nil {
+		return nil, err
+	}
+	if err := p.writeFile(filepath.Join("private", name+".key"), keyPEM, 0600); err != nil {
+		return nil, err
+	}
+	if err := p.writeFile(filepath.Join("certs_by_serial", serialHex+".pem"), certPEM, 0644); err != nil {
+		return nil, err
+	}
+
+	entries = append(entries, indexEntry{
+		status:  "V",
+		expires: template.NotAfter.Format(indexTimeLayout),
+		serial:  serialHex,
+		file:    "unknown",
+		subject: "/CN=" + name,
+	})
+	if err := p.writeIndex(entries); err != nil {
+		return nil, err
+	}
+
+	return &IssuedCert{
+		Name:    name,
+		Serial:  serialHex,
+		CertPEM: certPEM,
+		KeyPEM:  keyPEM,
+		CAPEM:   p.caPEM,
+	}, nil
+}
+
+// LoadClient читает выпущенный ранее действующий сертификат и ключ клиента,
+// чтобы заново собрать его профиль
+func (p *PKI) LoadClient(name string) (*IssuedCert, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entries, err := p.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	var serial string
+	for _, e := range entries {
+		if e.status == "V" && e.commonName() == name {
+			serial = e.serial
+		}
+	}
+	if serial == "" {
+		return nil, fmt.Errorf("client %s has no valid certificate", name)
+	}
+
+	certPEM, err := os.ReadFile(filepath.Join(p.dir, "issued", name+".crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(p.dir, "private", name+".key"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client key: %w", err)
+	}
+
+	return &IssuedCert{
+		Name:    name,
+		Serial:  serial,
+		CertPEM: certPEM,
+		KeyPEM:  keyPEM,
+		CAPEM:   p.caPEM,
+	}, nil
+}
+
+// RevokeClient отзывает действующий сертификат клиента и перевыпускает CRL
+func (p *PKI) RevokeClient(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entries, err := p.readIndex()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	now := time.Now().UTC()
+	for i := range entries {
+		if entries[i].status != "V" || entries[i].commonName() != name {
+			continue
+		}
+		found = true
+
+		// Файлы переносятся до изменения index.txt: при ошибке сертификат остается
+		// действующим, а повторный отзыв пропустит уже перенесенные файлы
+		if err := p.revokeFiles(name, entries[i].serial); err != nil {
+			return err
+		}
+		entries[i].status = "R"
+		entries[i].revoked = now.Format(indexTimeLayout)
+	}
+	if !found {
+		return fmt.Errorf("client %s not found", name)
+	}
+
+	if err := p.generateCRL(entries); err != nil {
+		return err
+	}
+
+	return p.writeIndex(entries)
+}
+
+// ListClients возвращает имена клиентов с действующими сертификатами
+func (p *PKI) ListClients() ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entries, err := p.readIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	serverName := p.serverName()
+	var clients []string
+	for _, e := range entries {
+		if e.status != "V" || p.isServerCert(e, serverName) {
+			continue
+		}
+		clients = append(clients, e.commonName())
+	}
+	return clients, nil
+}
+
+// serverName возвращает CN серверного сертификата, который установщик записывает
+// в SERVER_NAME_GENERATED рядом с директорией pki
+func (p *PKI) serverName() string {
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(p.dir), "SERVER_NAME_GENERATED"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// isServerCert отличает серверный сертификат от клиентских по EKU serverAuth.
+// Если сертификат не удалось прочитать, сравнивается CN с serverName
+func (p *PKI) isServerCert(e indexEntry, serverName string) bool {
+	data, err := os.ReadFile(filepath.Join(p.dir, "certs_by_serial", e.serial+".pem"))
+	if err != nil {
+		data, err = os.ReadFile(filepath.Join(p.dir, "issued", e.commonName()+".crt"))
+	}
+	if err == nil {
+		if block, _ := pem.Decode(data); block != nil {
+			if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+				for _, usage := range cert.ExtKeyUsage {
+					if usage == x509.ExtKeyUsageServerAuth {
+						return true
+					}
+				}
+				return false
+			}
+		}
+	}
+	return serverName != "" && e.commonName() == serverName
+}
+
+// CRLPath возвращает путь к CRL внутри директории pki
+func (p *PKI) CRLPath() string {
+	return filepath.Join(p.dir, "crl.pem")
+}
+
+// generateCRL перевыпускает pki/crl.pem по отозванным записям index.txt
+func (p *PKI) generateCRL(entries []indexEntry) error {
+	var revoked []x509.RevocationListEntry
+	for _, e := range entries {
+		if e.status != "R" {
+			continue
+		}
+		serial, ok := new(big.Int).SetString(e.serial, 16)
+		if !ok {
+			return fmt.Errorf("invalid serial in index: %s", e.serial)
+		}
+		revokedAt, err := time.Parse(indexTimeLayout, strings.SplitN(e.revoked, ",", 2)[0])
+		if err != nil {
+			return fmt.Errorf("invalid revocation date for %s: %w", e.serial, err)
+		}
+		revoked = append(revoked, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: revokedAt,
+		})
+	}
+
+	number, err := p.nextCRLNumber()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    number,
+		ThisUpdate:                now,
+		NextUpdate:                now.AddDate(0, 0, p.CRLDays),
+		RevokedCertificateEntries: revoked,
+	}, p.caCert, p.caKey)
+	if err != nil {
+		return fmt.Errorf("failed to create CRL: %w", err)
+	}
+
+	crlPEM := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+	return p.writeFile("crl.pem", crlPEM, 0644)
+}
+
+func (p *PKI) nextCRLNumber() (*big.Int, error) {
+	number := big.NewInt(1)
+	if data, err := os.ReadFile(filepath.Join(p.dir, "crlnumber")); err == nil {
+		if n, ok := new(big.Int).SetString(strings.TrimSpace(string(data)), 16); ok {
+			number = n
+		}
+	}
+
+	next := new(big.Int).Add(number, big.NewInt(1))
+	if err := p.writeFile("crlnumber", []byte(fmt.Sprintf("%02X\n", next)), 0644); err != nil {
+		return nil, err
+	}
+	return number, nil
+}
+
+func (p *PKI) generateKey() (crypto.Signer, error) {
+	switch pub := p.caCert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.GenerateKey(pub.Curve, rand.Reader)
+	case *rsa.PublicKey:
+		return rsa.GenerateKey(rand.Reader, pub.N.BitLen())
+	default:
+		return nil, fmt.Errorf("unsupported CA key type %T", pub)
+	}
+}
+
+func (p *PKI) readIndex() ([]indexEntry, error) {
+	f, err := os.Open(filepath.Join(p.dir, "index.txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open index.txt: %w", err)
+	}
+	defer f.Close()
+
+	var entries []indexEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("malformed index.txt line: %q", line)
+		}
+		entries = append(entries, indexEntry{
+			status:  fields[0],
+			expires: fields[1],
+			revoked: fields[2],
+			serial:  fields[3],
+			file:    fields[4],
+			subject: fields[5],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read index.txt: %w", err)
+	}
+	return entries, nil
+}
+
+func (p *PKI) writeIndex(entries []indexEntry) error {
+	var sb strings.Builder
+	for _, e := range entries {
+		sb.WriteString(strings.Join([]string{e.status, e.expires, e.revoked, e.serial, e.file, e.subject}, "\t"))
+		sb.WriteString("\n")
+	}
+
+	// Сохраняем резервную копию как remove.sh
+	if data, err := os.ReadFile(filepath.Join(p.dir, "index.txt")); err == nil {
+		if err := p.writeFile("index.txt.bk", data, 0644); err != nil {
+			return err
+		}
+	}
+	return p.writeFile("index.txt", []byte(sb.String()), 0644)
+}
+
+// writeFile атомарно записывает файл относительно директории pki
+func (p *PKI) writeFile(rel string, data []byte, perm os.FileMode) error {
+	path := filepath.Join(p.dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", rel, err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", rel, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", rel, err)
+	}
+	return nil
+}
+
+// revokeFiles раскладывает файлы отозванного сертификата как easy-rsa revoke.
+// Уже отсутствующие файлы пропускаются
+func (p *PKI) revokeFiles(name, serial string) error {
+	if err := p.moveFile(filepath.Join("issued", name+".crt"), filepath.Join("revoked", "certs_by_serial", serial+".crt")); err != nil {
+		return err
+	}
+	if err := p.moveFile(filepath.Join("private", name+".key"), filepath.Join("revoked", "private_by_serial", serial+".key")); err != nil {
+		return err
+	}
+	for _, rel := range []string{
+		filepath.Join("reqs", name+".req"),
+		filepath.Join("certs_by_serial", serial+".pem"),
+		filepath.Join("inline", name+".inline"),
+	} {
+		if err := os.Remove(filepath.Join(p.dir, rel)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", rel, err)
+		}
+	}
+	return nil
+}
+
+// moveFile переносит файл внутри директории pki; отсутствующий файл не считается ошибкой
+func (p *PKI) moveFile(from, to string) error {
+	src := filepath.Join(p.dir, from)
+	if _, err := os.Lstat(src); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	dst := filepath.Join(p.dir, to)
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", to, err)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return fmt.Errorf("failed to move %s: %w", from, err)
+	}
+	return nil
+}
+
+func (e indexEntry) commonName() string {
+	for _, part := range strings.Split(e.subject, "/") {
+		if strings.HasPrefix(part, "CN=") {
+			return strings.TrimPrefix(part, "CN=")
+		}
+	}
+	return ""
+}
+
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+		return signer, nil
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "ENCRYPTED PRIVATE KEY":
+		return nil, fmt.Errorf("encrypted CA keys are not supported")
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %s", block.Type)
+	}
+}
+
+func randomSerial() (*big.Int, error) {
+	// easy-rsa 3 использует 128-битные случайные серийные номера
+	limit := new(big.Int).Lsh(big.NewInt(1), 128)
+	for {
+		serial, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return nil, err
+		}
+		if serial.Sign() > 0 {
+			return serial, nil
+		}
+	}
+}
+
+func subjectKeyID(pub crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	sum := sha1.Sum(der)
+	return sum[:], nil
+}
+
+// serialString форматирует серийный номер как в easy-rsa
+func serialString(serial *big.Int) string {
+	return strings.ToUpper(hex.EncodeToString(serial.Bytes()))
+}
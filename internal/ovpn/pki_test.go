@@ -0,0 +1,480 @@
+package ovpn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testServerName = "server_AbCd"
+
+// testCA - CA временной PKI
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestEasyRSA создает во временной директории раскладку easy-rsa: easy-rsa/pki
+// с CA и пустым index.txt, а также SERVER_NAME_GENERATED. Возвращает путь к pki
+func newTestEasyRSA(t *testing.T) (string, *testCA) {
+	t.Helper()
+	pkiDir := filepath.Join(t.TempDir(), "easy-rsa", "pki")
+	for _, dir := range []string{"private", "issued", "certs_by_serial", "reqs"} {
+		if err := os.MkdirAll(filepath.Join(pkiDir, dir), 0700); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "cn_test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+
+	writeTestFile(t, filepath.Join(pkiDir, "ca.crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeTestFile(t, filepath.Join(pkiDir, "private", "ca.key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	writeTestFile(t, filepath.Join(pkiDir, "index.txt"), nil)
+	writeTestFile(t, filepath.Join(pkiDir, "..", "SERVER_NAME_GENERATED"), []byte(testServerName+"\n"))
+
+	return pkiDir, &testCA{cert: cert, key: key}
+}
+
+func writeTestFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+// issueServerCert выпускает серверный сертификат с EKU serverAuth и добавляет
+// его в index.txt, как build-server-full
+func (ca *testCA) issueServerCert(t *testing.T, pkiDir, name string, serial int64) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	notAfter := time.Now().AddDate(10, 0, 0).UTC()
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca.cert, key.Public(), ca.key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	serialHex := serialString(big.NewInt(serial))
+	writeTestFile(t, filepath.Join(pkiDir, "issued", name+".crt"), certPEM)
+	writeTestFile(t, filepath.Join(pkiDir, "certs_by_serial", serialHex+".pem"), certPEM)
+	appendIndexLine(t, pkiDir, "V\t"+notAfter.Format(indexTimeLayout)+"\t\t"+serialHex+"\tunknown\t/CN="+name)
+}
+
+func appendIndexLine(t *testing.T, pkiDir, line string) {
+	t.Helper()
+	f, err := os.OpenFile(filepath.Join(pkiDir, "index.txt"), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		t.Fatalf("WriteString: %v", err)
+	}
+}
+
+func readIndexLines(t *testing.T, pkiDir string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(pkiDir, "index.txt"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func readTestCRL(t *testing.T, pkiDir string) *x509.RevocationList {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(pkiDir, "crl.pem"))
+	if err != nil {
+		t.Fatalf("ReadFile(crl.pem): %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "X509 CRL" {
+		t.Fatalf("crl.pem is not a PEM CRL")
+	}
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParseRevocationList: %v", err)
+	}
+	return crl
+}
+
+func newTestPKI(t *testing.T, pkiDir string) *PKI {
+	t.Helper()
+	pki, err := NewPKI(pkiDir)
+	if err != nil {
+		t.Fatalf("NewPKI: %v", err)
+	}
+	return pki
+}
+
+var (
+	validIndexLine   = regexp.MustCompile(`^V\t\d{12}Z\t\t[0-9A-F]+\tunknown\t/CN=([^\t]+)$`)
+	revokedIndexLine = regexp.MustCompile(`^R\t\d{12}Z\t\d{12}Z\t[0-9A-F]+\tunknown\t/CN=([^\t]+)$`)
+)
+
+func TestPKIIssueClient(t *testing.T) {
+	pkiDir, ca := newTestEasyRSA(t)
+	ca.issueServerCert(t, pkiDir, testServerName, 2)
+	pki := newTestPKI(t, pkiDir)
+
+	issued, err := pki.IssueClient("client_alice")
+	if err != nil {
+		t.Fatalf("IssueClient: %v", err)
+	}
+
+	// Сертификат подписан CA и предназначен для клиента
+	block, _ := pem.Decode(issued.CertPEM)
+	if block == nil {
+		t.Fatal("issued certificate is not PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	if err := cert.CheckSignatureFrom(ca.cert); err != nil {
+		t.Errorf("certificate is not signed by CA: %v", err)
+	}
+	if cert.Subject.CommonName != "client_alice" || !reflect.DeepEqual(cert.ExtKeyUsage, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}) {
+		t.Errorf("certificate CN = %q, EKU = %v", cert.Subject.CommonName, cert.ExtKeyUsage)
+	}
+	if serialString(cert.SerialNumber) != issued.Serial {
+		t.Errorf("serial = %s, want %s", issued.Serial, serialString(cert.SerialNumber))
+	}
+	if _, err := parsePrivateKey(issued.KeyPEM); err != nil {
+		t.Errorf("issued key: %v", err)
+	}
+	if !reflect.DeepEqual(issued.CAPEM, pki.CAPEM()) {
+		t.Error("issued CA does not match PKI CA")
+	}
+
+	// Файлы разложены как у easy-rsa build-client-full
+	for rel, perm := range map[string]os.FileMode{
+		"issued/client_alice.crt":                   0644,
+		"private/client_alice.key":                  0600,
+		"certs_by_serial/" + issued.Serial + ".pem": 0644,
+	} {
+		info, err := os.Stat(filepath.Join(pkiDir, rel))
+		if err != nil {
+			t.Errorf("%s: %v", rel, err)
+		} else if info.Mode().Perm() != perm {
+			t.Errorf("%s mode = %v, want %v", rel, info.Mode().Perm(), perm)
+		}
+	}
+
+	// Запись index.txt в формате openssl ca, серверная запись не изменилась
+	lines := readIndexLines(t, pkiDir)
+	if len(lines) != 2 {
+		t.Fatalf("index.txt has %d lines, want 2:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+	match := validIndexLine.FindStringSubmatch(lines[1])
+	if match == nil || match[1] != "client_alice" || !strings.Contains(lines[1], "\t"+issued.Serial+"\t") {
+		t.Errorf("index.txt line = %q", lines[1])
+	}
+	if expires := strings.Split(lines[1], "\t")[1]; expires != cert.NotAfter.UTC().Format(indexTimeLayout) {
+		t.Errorf("index.txt expiry = %s, certificate expires %v", expires, cert.NotAfter)
+	}
+
+	if _, err := pki.IssueClient("client_alice"); err == nil {
+		t.Error("IssueClient issued a duplicate client")
+	}
+}
+
+func TestPKIRevokeClient(t *testing.T) {
+	pkiDir, ca := newTestEasyRSA(t)
+	ca.issueServerCert(t, pkiDir, testServerName, 2)
+	pki := newTestPKI(t, pkiDir)
+
+	alice, err := pki.IssueClient("client_alice")
+	if err != nil {
+		t.Fatalf("IssueClient: %v", err)
+	}
+	bob, err := pki.IssueClient("client_bob")
+	if err != nil {
+		t.Fatalf("IssueClient: %v", err)
+	}
+	writeTestFile(t, filepath.Join(pkiDir, "reqs", "client_alice.req"), []byte("req"))
+
+	if err := pki.RevokeClient("client_alice"); err != nil {
+		t.Fatalf("RevokeClient: %v", err)
+	}
+
+	lines := readIndexLines(t, pkiDir)
+	if match := revokedIndexLine.FindStringSubmatch(lines[1]); match == nil || match[1] != "client_alice" {
+		t.Errorf("revoked index.txt line = %q", lines[1])
+	}
+	if match := validIndexLine.FindStringSubmatch(lines[2]); match == nil || match[1] != "client_bob" {
+		t.Errorf("index.txt line of other client = %q", lines[2])
+	}
+	if _, err := os.Stat(filepath.Join(pkiDir, "index.txt.bk")); err != nil {
+		t.Errorf("index.txt.bk: %v", err)
+	}
+
+	// Файлы отозванного сертификата перенесены как у easy-rsa revoke
+	for _, rel := range []string{
+		"revoked/certs_by_serial/" + alice.Serial + ".crt",
+		"revoked/private_by_serial/" + alice.Serial + ".key",
+	} {
+		if _, err := os.Stat(filepath.Join(pkiDir, rel)); err != nil {
+			t.Errorf("%s: %v", rel, err)
+		}
+	}
+	for _, rel := range []string{
+		"issued/client_alice.crt",
+		"private/client_alice.key",
+		"reqs/client_alice.req",
+		"certs_by_serial/" + alice.Serial + ".pem",
+	} {
+		if _, err := os.Stat(filepath.Join(pkiDir, rel)); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed: %v", rel, err)
+		}
+	}
+
+	// CRL подписан CA и содержит только отозванный сертификат
+	crl := readTestCRL(t, pkiDir)
+	if err := crl.CheckSignatureFrom(ca.cert); err != nil {
+		t.Errorf("CRL is not signed by CA: %v", err)
+	}
+	if len(crl.RevokedCertificateEntries) != 1 || serialString(crl.RevokedCertificateEntries[0].SerialNumber) != alice.Serial {
+		t.Errorf("CRL entries = %+v, want serial %s", crl.RevokedCertificateEntries, alice.Serial)
+	}
+	if crl.Number.Int64() != 1 {
+		t.Errorf("CRL number = %v, want 1", crl.Number)
+	}
+
+	if err := pki.RevokeClient("client_alice"); err == nil {
+		t.Error("RevokeClient revoked a client twice")
+	}
+
+	// Второй отзыв перевыпускает CRL со следующим номером и обоими серийными номерами
+	if err := pki.RevokeClient("client_bob"); err != nil {
+		t.Fatalf("RevokeClient(bob): %v", err)
+	}
+	crl = readTestCRL(t, pkiDir)
+	var serials []string
+	for _, entry := range crl.RevokedCertificateEntries {
+		serials = append(serials, serialString(entry.SerialNumber))
+	}
+	if !reflect.DeepEqual(serials, []string{alice.Serial, bob.Serial}) || crl.Number.Int64() != 2 {
+		t.Errorf("CRL #%v serials = %v, want [%s %s]", crl.Number, serials, alice.Serial, bob.Serial)
+	}
+
+	// Имя освобождается после отзыва
+	if _, err := pki.IssueClient("client_alice"); err != nil {
+		t.Errorf("IssueClient after revoke: %v", err)
+	}
+}
+
+func TestPKIRevokeClientErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		// client - отзываемый клиент, по умолчанию client_alice
+		client string
+		// prepare портит PKI после выпуска client_alice
+		prepare func(t *testing.T, pkiDir string)
+		wantErr bool
+	}{
+		{
+			name:    "unknown client",
+			client:  "client_bob",
+			prepare: func(t *testing.T, pkiDir string) {},
+			wantErr: true,
+		},
+		{
+			name: "revoked directory is a file",
+			prepare: func(t *testing.T, pkiDir string) {
+				writeTestFile(t, filepath.Join(pkiDir, "revoked"), []byte("not a directory"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "request is a non-empty directory",
+			prepare: func(t *testing.T, pkiDir string) {
+				dir := filepath.Join(pkiDir, "reqs", "client_alice.req")
+				if err := os.MkdirAll(filepath.Join(dir, "nested"), 0700); err != nil {
+					t.Fatalf("MkdirAll: %v", err)
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "files already removed",
+			prepare: func(t *testing.T, pkiDir string) {
+				for _, rel := range []string{"issued/client_alice.crt", "private/client_alice.key"} {
+					if err := os.Remove(filepath.Join(pkiDir, rel)); err != nil {
+						t.Fatalf("Remove: %v", err)
+					}
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkiDir, _ := newTestEasyRSA(t)
+			pki := newTestPKI(t, pkiDir)
+			if _, err := pki.IssueClient("client_alice"); err != nil {
+				t.Fatalf("IssueClient: %v", err)
+			}
+			tt.prepare(t, pkiDir)
+
+			client := tt.client
+			if client == "" {
+				client = "client_alice"
+			}
+			err := pki.RevokeClient(client)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RevokeClient = %v, want error %v", err, tt.wantErr)
+			}
+
+			// При ошибке index.txt не меняется и CRL не выпускается
+			status := strings.Split(readIndexLines(t, pkiDir)[0], "\t")[0]
+			_, crlErr := os.Stat(filepath.Join(pkiDir, "crl.pem"))
+			if tt.wantErr && (status != "V" || crlErr == nil) {
+				t.Errorf("after failed revoke status = %s, crl.pem exists = %v", status, crlErr == nil)
+			}
+			if !tt.wantErr && (status != "R" || crlErr != nil) {
+				t.Errorf("after revoke status = %s, crl.pem: %v", status, crlErr)
+			}
+		})
+	}
+}
+
+func TestPKIListClients(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup выпускает сертификаты в нужном порядке
+		setup func(t *testing.T, pkiDir string, ca *testCA, pki *PKI)
+		want  []string
+	}{
+		{
+			name:  "empty",
+			setup: func(t *testing.T, pkiDir string, ca *testCA, pki *PKI) {},
+		},
+		{
+			name: "server first",
+			setup: func(t *testing.T, pkiDir string, ca *testCA, pki *PKI) {
+				ca.issueServerCert(t, pkiDir, testServerName, 2)
+				issueTestClients(t, pki, "client_alice", "client_bob")
+			},
+			want: []string{"client_alice", "client_bob"},
+		},
+		{
+			name: "server after clients",
+			setup: func(t *testing.T, pkiDir string, ca *testCA, pki *PKI) {
+				issueTestClients(t, pki, "client_alice")
+				ca.issueServerCert(t, pkiDir, testServerName, 2)
+				issueTestClients(t, pki, "client_bob")
+			},
+			want: []string{"client_alice", "client_bob"},
+		},
+		{
+			name: "no server certificate",
+			setup: func(t *testing.T, pkiDir string, ca *testCA, pki *PKI) {
+				issueTestClients(t, pki, "client_alice", "client_bob")
+			},
+			want: []string{"client_alice", "client_bob"},
+		},
+		{
+			name: "server certificate file missing",
+			setup: func(t *testing.T, pkiDir string, ca *testCA, pki *PKI) {
+				appendIndexLine(t, pkiDir, "V\t360101000000Z\t\t0A\tunknown\t/CN="+testServerName)
+				issueTestClients(t, pki, "client_alice")
+			},
+			want: []string{"client_alice"},
+		},
+		{
+			name: "revoked clients",
+			setup: func(t *testing.T, pkiDir string, ca *testCA, pki *PKI) {
+				ca.issueServerCert(t, pkiDir, testServerName, 2)
+				issueTestClients(t, pki, "client_alice", "client_bob")
+				if err := pki.RevokeClient("client_alice"); err != nil {
+					t.Fatalf("RevokeClient: %v", err)
+				}
+			},
+			want: []string{"client_bob"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkiDir, ca := newTestEasyRSA(t)
+			pki := newTestPKI(t, pkiDir)
+			tt.setup(t, pkiDir, ca, pki)
+
+			clients, err := pki.ListClients()
+			if err != nil {
+				t.Fatalf("ListClients: %v", err)
+			}
+			if !reflect.DeepEqual(clients, tt.want) {
+				t.Errorf("ListClients = %v, want %v", clients, tt.want)
+			}
+		})
+	}
+}
+
+func issueTestClients(t *testing.T, pki *PKI, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if _, err := pki.IssueClient(name); err != nil {
+			t.Fatalf("IssueClient(%s): %v", name, err)
+		}
+	}
+}
+
+func TestPKIMalformedIndex(t *testing.T) {
+	pkiDir, _ := newTestEasyRSA(t)
+	writeTestFile(t, filepath.Join(pkiDir, "index.txt"), []byte("V\tbroken\n"))
+	pki := newTestPKI(t, pkiDir)
+
+	if _, err := pki.ListClients(); err == nil {
+		t.Error("ListClients accepted malformed index.txt")
+	}
+	if _, err := pki.IssueClient("client_alice"); err == nil {
+		t.Error("IssueClient accepted malformed index.txt")
+	}
+}
@@ -0,0 +1,44 @@
+package ovpn
+
+// ClientProvisioner создает и отзывает клиентские конфигурации OpenVPN.
+// Бот работает только через этот интерфейс, поэтому бэкенд можно подменить
+type ClientProvisioner interface {
+	// CreateClient выпускает нового клиента и возвращает его имя и путь к .ovpn
+	CreateClient(opts ClientOptions) (clientName, configPath string, err error)
+	// RemoveClient отзывает клиента и удаляет его конфигурацию
+	RemoveClient(clientName, configPath string) error
+	// ListClients возвращает имена действующих клиентов
+	ListClients() ([]string, error)
+	// ReadConfigFile возвращает содержимое .ovpn
+	ReadConfigFile(configPath string) ([]byte, error)
+}
+
+// Disconnector - провижинер, который умеет сразу разрывать соединение клиента
+type Disconnector interface {
+	DisconnectClient(clientName string) error
+}
+
+// ClientOptions - дополнительные параметры создаваемого клиента
+type ClientOptions struct {
+	// Owner попадает в комментарий клиентского профиля
+	Owner string
+}
+
+var (
+	_ ClientProvisioner = (*Service)(nil)
+	_ ClientProvisioner = (*MemoryProvisioner)(nil)
+	_ ClientProvisioner = (*AgentClient)(nil)
+
+	_ StatusSource = (*Service)(nil)
+	_ StatusSource = (*MemoryProvisioner)(nil)
+	_ StatusSource = (*AgentClient)(nil)
+
+	_ Disconnector = (*Service)(nil)
+	_ Disconnector = (*AgentClient)(nil)
+
+	_ Blocker = (*Service)(nil)
+	_ Blocker = (*MemoryProvisioner)(nil)
+	_ Blocker = (*AgentClient)(nil)
+
+	_ SessionWatcher = (*Service)(nil)
+)
@@ -0,0 +1,138 @@
+package ovpn
+
+import (
+	"fmt"
+	"log"
+
+	"go-ovpn-bot/internal/config"
+)
+
+// Server - VPN сервер из реестра вместе с его провижинером
+type Server struct {
+	Name        string
+	Region      string
+	Capacity    int
+	Provisioner ClientProvisioner
+}
+
+// Registry хранит серверы в порядке объявления; первый сервер считается сервером по умолчанию
+type Registry struct {
+	servers []*Server
+	byName  map[string]*Server
+}
+
+// NewRegistry создает пустой реестр серверов
+func NewRegistry() *Registry {
+	return &Registry{byName: make(map[string]*Server)}
+}
+
+// NewRegistryFromConfig создает провижинеры для всех серверов из конфигурации
+func NewRegistryFromConfig(servers []config.Server) (*Registry, error) {
+	registry := NewRegistry()
+	for _, srv := range servers {
+		provisioner, err := NewProvisioner(srv)
+		if err != nil {
+			return nil, fmt.Errorf("server %s: %w", srv.Name, err)
+		}
+		if err := registry.Add(&Server{
+			Name:        srv.Name,
+			Region:      srv.Region,
+			Capacity:    srv.Capacity,
+			Provisioner: provisioner,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// NewProvisioner создает провижинер по бэкенду сервера
+func NewProvisioner(srv config.Server) (ClientProvisioner, error) {
+	switch srv.Backend {
+	case config.BackendNative:
+		pki, err := NewPKI(srv.PKIPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load PKI: %w", err)
+		}
+		renderer, err := NewRenderer(srv.OpenVPNDir, srv.ClientTemplatePath, srv.RemoteHost)
+		if err != nil {
+			return nil, err
+		}
+		service := NewNative(pki, renderer, srv.OpenVPNDir, srv.ConfigsPath, srv.ConfigPrefix)
+		service.SetStatusPath(srv.StatusPath)
+		service.SetManagement(srv.ManagementAddr, srv.ManagementPassword)
+		service.SetCCDPath(srv.CCDPath)
+		return service, nil
+	case config.BackendMemory:
+		return NewMemory(srv.ConfigsPath, srv.ConfigPrefix), nil
+	case config.BackendAgent:
+		return NewAgentClient(srv.AgentURL, srv.AgentCert, srv.AgentKey, srv.AgentCA)
+	case config.BackendScript, "":
+		renderer, err := NewRenderer(srv.OpenVPNDir, srv.ClientTemplatePath, srv.RemoteHost)
+		if err != nil {
+			return nil, err
+		}
+		service := New(srv.ScriptsPath, srv.ConfigsPath, srv.ConfigPrefix)
+		service.SetRenderer(renderer)
+		service.SetStatusPath(srv.StatusPath)
+		service.SetManagement(srv.ManagementAddr, srv.ManagementPassword)
+		service.SetCCDPath(srv.CCDPath)
+		return service, nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q", srv.Backend)
+	}
+}
+
+// Add добавляет сервер в реестр
+func (r *Registry) Add(server *Server) error {
+	if _, exists := r.byName[server.Name]; exists {
+		return fmt.Errorf("server %s already registered", server.Name)
+	}
+	r.servers = append(r.servers, server)
+	r.byName[server.Name] = server
+	return nil
+}
+
+// Get возвращает сервер по имени. Пустое имя означает сервер по умолчанию
+// (так хранятся конфигурации, созданные до появления реестра)
+func (r *Registry) Get(name string) (*Server, bool) {
+	if name == "" {
+		return r.Default(), len(r.servers) > 0
+	}
+	server, ok := r.byName[name]
+	return server, ok
+}
+
+// Default возвращает первый сервер реестра
+func (r *Registry) Default() *Server {
+	if len(r.servers) == 0 {
+		return nil
+	}
+	return r.servers[0]
+}
+
+// Servers возвращает все серверы в порядке объявления
+func (r *Registry) Servers() []*Server {
+	return r.servers
+}
+
+// RevokeClient отзывает клиента на сервере, где он был создан, и сразу разрывает
+// его соединение, не дожидаясь перечитывания CRL
+func (r *Registry) RevokeClient(serverName, clientName, configPath string) error {
+	server, ok := r.Get(serverName)
+	if !ok {
+		return fmt.Errorf("unknown server %q", serverName)
+	}
+
+	if err := server.Provisioner.RemoveClient(clientName, configPath); err != nil {
+		return fmt.Errorf("failed to remove client: %w", err)
+	}
+
+	if disconnector, ok := server.Provisioner.(Disconnector); ok {
+		if err := disconnector.DisconnectClient(clientName); err != nil {
+			log.Printf("Failed to disconnect client %s: %v", clientName, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package ovpn
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/client.ovpn.tmpl
+var defaultClientTemplate string
+
+// ServerConfig - параметры server.conf, нужные для клиентского профиля
+type ServerConfig struct {
+	// Name - CN серверного сертификата (для verify-x509-name)
+	Name string
+	// Remote - адрес сервера, к которому подключаются клиенты
+	Remote    string
+	Port      string
+	Proto     string // "udp" или "tcp" без суффикса 6
+	Auth      string
+	Cipher    string
+	TLSCipher string
+	Compress  string
+	// TLSSig - "tls-crypt", "tls-auth" или пусто
+	TLSSig string
+	// Directives - все директивы server.conf (первое вхождение)
+	Directives map[string]string
+}
+
+// ProfileData - данные, доступные в шаблоне клиентского профиля
+type ProfileData struct {
+	Server     *ServerConfig
+	ClientName string
+	Owner      string
+	CA         []byte
+	Cert       []byte
+	Key        []byte
+	TLSKey     []byte
+}
+
+// Renderer собирает клиентские .ovpn из server.conf и text/template
+type Renderer struct {
+	openvpnDir string
+	remoteHost string
+	tmpl       *template.Template
+}
+
+// NewRenderer создает рендерер. Если templatePath пуст, используется встроенный шаблон,
+// повторяющий профиль из add.sh. remoteHost переопределяет адрес из client-template.txt
+func NewRenderer(openvpnDir, templatePath, remoteHost string) (*Renderer, error) {
+	text := defaultClientTemplate
+	if templatePath != "" {
+		data, err := os.ReadFile(templatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client template: %w", err)
+		}
+		text = string(data)
+	}
+
+	tmpl, err := template.New("client.ovpn").Funcs(template.FuncMap{
+		"pem": func(data []byte) string {
+			return strings.TrimRight(string(data), "\n")
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse client template: %w", err)
+	}
+
+	return &Renderer{
+		openvpnDir: openvpnDir,
+		remoteHost: remoteHost,
+		tmpl:       tmpl,
+	}, nil
+}
+
+// ParseServerConfig читает server.conf и сопутствующие файлы из директории OpenVPN
+func ParseServerConfig(openvpnDir string) (*ServerConfig, error) {
+	directives, err := readDirectives(filepath.Join(openvpnDir, "server.conf"))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &ServerConfig{
+		Name:       "server",
+		Remote:     "YOUR_SERVER_IP",
+		Port:       directives["port"],
+		Proto:      strings.TrimSuffix(strings.TrimSuffix(directives["proto"], "6"), "-server"),
+		Auth:       directives["auth"],
+		Cipher:     directives["cipher"],
+		TLSCipher:  directives["tls-cipher"],
+		Compress:   directives["compress"],
+		Directives: directives,
+	}
+
+	if _, ok := directives["tls-crypt"]; ok {
+		cfg.TLSSig = "tls-crypt"
+	} else if _, ok := directives["tls-auth"]; ok {
+		cfg.TLSSig = "tls-auth"
+	}
+
+	if data, err := os.ReadFile(filepath.Join(openvpnDir, "easy-rsa", "SERVER_NAME_GENERATED")); err == nil {
+		cfg.Name = strings.TrimSpace(string(data))
+	}
+
+	// Адрес сервера server.conf не содержит, его записывает установщик в client-template.txt
+	if clientTemplate, err := readDirectives(filepath.Join(openvpnDir, "client-template.txt")); err == nil {
+		if fields := strings.Fields(clientTemplate["remote"]); len(fields) > 0 {
+			cfg.Remote = fields[0]
+		}
+	}
+
+	return cfg, nil
+}
+
+// Render собирает профиль клиента по выпущенному сертификату
+func (r *Renderer) Render(issued *IssuedCert, owner string) ([]byte, error) {
+	return r.render(issued, owner, nil)
+}
+
+// render собирает профиль. Ключ tls-crypt/tls-auth читается из директории OpenVPN,
+// если tlsKey не передан
+func (r *Renderer) render(issued *IssuedCert, owner string, tlsKey []byte) ([]byte, error) {
+	server, err := ParseServerConfig(r.openvpnDir)
+	if err != nil {
+		return nil, err
+	}
+	if r.remoteHost != "" {
+		server.Remote = r.remoteHost
+	}
+
+	data := ProfileData{
+		Server:     server,
+		ClientName: issued.Name,
+		Owner:      owner,
+		CA:         issued.CAPEM,
+		Cert:       issued.CertPEM,
+		Key:        issued.KeyPEM,
+		TLSKey:     tlsKey,
+	}
+
+	if server.TLSSig != "" && tlsKey == nil {
+		key, err := os.ReadFile(filepath.Join(r.openvpnDir, server.TLSSig+".key"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s key: %w", server.TLSSig, err)
+		}
+		data.TLSKey = key
+	}
+
+	var buf bytes.Buffer
+	if err := r.tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render client config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// parseInlineBlocks разбирает встроенные блоки профиля вида <ca>...</ca> в
+// "имя блока -> содержимое". Строки вне блоков пропускаются
+func parseInlineBlocks(data []byte) map[string][]byte {
+	blocks := make(map[string][]byte)
+
+	var (
+		name    string
+		content []string
+	)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case name == "" && strings.HasPrefix(line, "<") && strings.HasSuffix(line, ">") && !strings.HasPrefix(line, "</"):
+			name = strings.Trim(line, "<>")
+			content = nil
+		case name != "" && line == "</"+name+">":
+			blocks[name] = []byte(strings.Join(content, "\n") + "\n")
+			name = ""
+		case name != "":
+			content = append(content, line)
+		}
+	}
+	return blocks
+}
+
+// readDirectives читает конфиг OpenVPN в виде "директива -> аргументы"
+func readDirectives(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	directives := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		name, args, _ := strings.Cut(line, " ")
+		if _, exists := directives[name]; !exists {
+			directives[name] = strings.TrimSpace(args)
+		}
+	}
+	return directives, scanner.Err()
+}
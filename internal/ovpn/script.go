@@ -0,0 +1,65 @@
+package ovpn
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// createClientScript собирает профиль по PEM-блокам, которые вывел add.sh, и сохраняет .ovpn.
+// Закрытый ключ и ключ TLS доступны только root, поэтому их передает скрипт,
+// а остальные параметры профиля берутся из server.conf
+func (s *Service) createClientScript(clientName string, output []byte, opts ClientOptions) (string, error) {
+	profile, err := s.renderScriptOutput(clientName, output, opts.Owner)
+	if err != nil {
+		// Не оставляем действующий сертификат без конфигурации
+		s.revokeScriptClient(clientName)
+		return "", err
+	}
+
+	configPath, err := filepath.Abs(filepath.Join(s.configsPath, clientName+".ovpn"))
+	if err != nil {
+		s.revokeScriptClient(clientName)
+		return "", fmt.Errorf("failed to resolve config path: %w", err)
+	}
+
+	if err := os.WriteFile(configPath, profile, 0600); err != nil {
+		s.revokeScriptClient(clientName)
+		return "", fmt.Errorf("failed to write client config: %w", err)
+	}
+
+	return configPath, nil
+}
+
+// revokeScriptClient отзывает сертификат, для которого не удалось сохранить профиль
+func (s *Service) revokeScriptClient(clientName string) {
+	if err := s.RemoveClient(clientName, ""); err != nil {
+		log.Printf("Failed to revoke client %s without config: %v", clientName, err)
+	}
+}
+
+// renderScriptOutput собирает профиль клиента из вывода add.sh
+func (s *Service) renderScriptOutput(clientName string, output []byte, owner string) ([]byte, error) {
+	blocks := parseInlineBlocks(output)
+	issued := &IssuedCert{
+		Name:    clientName,
+		CAPEM:   blocks["ca"],
+		CertPEM: blocks["cert"],
+		KeyPEM:  blocks["key"],
+	}
+	if issued.CAPEM == nil || issued.CertPEM == nil || issued.KeyPEM == nil {
+		return nil, fmt.Errorf("add.sh did not print the client certificate and key")
+	}
+
+	tlsKey := blocks["tls-crypt"]
+	if tlsKey == nil {
+		tlsKey = blocks["tls-auth"]
+	}
+
+	profile, err := s.renderer.render(issued, owner, tlsKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render client config: %w", err)
+	}
+	return profile, nil
+}
@@ -1,19 +1,41 @@
 package ovpn
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	"math/rand"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"time"
+	"sync"
+
+	"go-ovpn-bot/internal/generator"
 )
 
 type Service struct {
 	scriptsPath  string
 	configsPath  string
 	configPrefix string
+	// Нативный бэкенд: если pki задан, скрипты add.sh/remove.sh не используются
+	pki        *PKI
+	renderer   *Renderer
+	openvpnDir string
+	// Путь к status.log сервера (директива status в server.conf)
+	statusPath string
+	// Адрес и пароль management интерфейса (директива management в server.conf)
+	managementAddr     string
+	managementPassword string
+	// OpenVPN обслуживает одного management клиента за раз, поэтому команды
+	// и WatchSessions используют общее соединение
+	managementMu sync.Mutex
+	management   *Management
+	// Директория client-config-dir для блокировки клиентов
+	ccdPath string
+	// scriptMu не дает запускать add.sh и remove.sh параллельно: easy-rsa
+	// изменяет общие index.txt и serial без собственной блокировки
+	scriptMu sync.Mutex
 }
 
 func New(scriptsPath, configsPath, configPrefix string) *Service {
@@ -24,47 +46,137 @@ func New(scriptsPath, configsPath, configPrefix string) *Service {
 	}
 }
 
-// GenerateRandomName генерирует случайное имя для клиента
-func (s *Service) GenerateRandomName() string {
-	rand.Seed(time.Now().UnixNano())
-	
-	// Генерируем 8 случайных символов (латинские буквы и цифры в смешанном регистре)
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	randomPart := make([]byte, 8)
-	
-	for i := range randomPart {
-		randomPart[i] = charset[rand.Intn(len(charset))]
+// NewNative создает сервис, который выпускает сертификаты через PKI без sudo и скриптов
+func NewNative(pki *PKI, renderer *Renderer, openvpnDir, configsPath, configPrefix string) *Service {
+	return &Service{
+		configsPath:  configsPath,
+		configPrefix: configPrefix,
+		pki:          pki,
+		renderer:     renderer,
+		openvpnDir:   openvpnDir,
 	}
-	
-	return s.configPrefix + string(randomPart)
+}
+
+// SetRenderer задает рендерер профилей для скриптового бэкенда: add.sh только
+// выпускает сертификат, а .ovpn собирается по шаблону, как в нативном бэкенде
+func (s *Service) SetRenderer(renderer *Renderer) {
+	s.renderer = renderer
+}
+
+// SetStatusPath задает путь к status.log для ConnectedClients
+func (s *Service) SetStatusPath(statusPath string) {
+	s.statusPath = statusPath
+}
+
+// SetManagement задает адрес management интерфейса ("host:port" или "unix:/path")
+func (s *Service) SetManagement(addr, password string) {
+	s.managementAddr = addr
+	s.managementPassword = password
+}
+
+// ConnectedClients возвращает клиентов, подключенных к серверу.
+// Management интерфейс предпочтительнее: status.log обновляется с задержкой
+func (s *Service) ConnectedClients() ([]ConnectedClient, error) {
+	if s.managementAddr != "" {
+		m, err := s.managementConn()
+		if err != nil {
+			return nil, err
+		}
+		return m.Status()
+	}
+
+	if s.statusPath == "" {
+		return nil, fmt.Errorf("status file is not configured")
+	}
+	return ReadStatusFile(s.statusPath)
+}
+
+// DisconnectClient немедленно отключает клиента через management интерфейс,
+// не дожидаясь перечитывания CRL сервером
+func (s *Service) DisconnectClient(clientName string) error {
+	if s.managementAddr == "" {
+		return nil
+	}
+
+	m, err := s.managementConn()
+	if err != nil {
+		return err
+	}
+
+	if err := m.Kill(clientName); err != nil && !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+	return nil
+}
+
+// GenerateRandomName генерирует случайное имя для клиента, не занятое существующими конфигурациями
+func (s *Service) GenerateRandomName() (string, error) {
+	return generateRandomName(s.configPrefix, func(name string) (bool, error) {
+		_, err := os.Stat(filepath.Join(s.configsPath, name+".ovpn"))
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+}
+
+// clientNames генерирует 8 случайных символов имени клиента (латинские буквы и цифры в смешанном регистре)
+var clientNames = generator.MustNew(generator.Options{Length: 8, Alphabet: generator.Alphanumeric})
+
+// generateRandomName генерирует имя клиента из префикса и 8 случайных символов,
+// повторяя попытку, пока exists сообщает о занятом имени
+func generateRandomName(prefix string, exists func(string) (bool, error)) (string, error) {
+	suffix, err := clientNames.GenerateUnique(func(suffix string) (bool, error) {
+		return exists(prefix + suffix)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate client name: %w", err)
+	}
+	return prefix + suffix, nil
 }
 
 // CreateClient создает нового клиента OpenVPN
-func (s *Service) CreateClient() (string, string, error) {
+func (s *Service) CreateClient(opts ClientOptions) (string, string, error) {
 	// Генерируем случайное имя
-	clientName := s.GenerateRandomName()
+	clientName, err := s.GenerateRandomName()
+	if err != nil {
+		return "", "", err
+	}
 	
 	// Создаем директорию для конфигов если она не существует
 	if err := os.MkdirAll(s.configsPath, 0755); err != nil {
 		return "", "", fmt.Errorf("failed to create configs directory: %w", err)
 	}
 	
+	if s.pki != nil {
+		configPath, err := s.createClientNative(clientName, opts)
+		if err != nil {
+			return "", "", err
+		}
+		return clientName, configPath, nil
+	}
+	
+	if s.renderer == nil {
+		return "", "", fmt.Errorf("client config renderer is not configured")
+	}
+	
 	// Путь к скрипту add.sh
 	addScript := filepath.Join(s.scriptsPath, "add.sh")
 	
-	// Выполняем скрипт add.sh
-	cmd := exec.Command("sudo", addScript, clientName, s.configsPath)
-	output, err := cmd.CombinedOutput()
+	// Выполняем скрипт add.sh: он выпускает сертификат и выводит PEM-блоки профиля
+	s.scriptMu.Lock()
+	cmd := exec.Command("sudo", addScript, clientName)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	s.scriptMu.Unlock()
 	if err != nil {
-		return "", "", fmt.Errorf("failed to create client: %w, output: %s", err, string(output))
+		return "", "", fmt.Errorf("failed to create client: %w, output: %s", err, stderr.String())
 	}
 	
-	// Скрипт возвращает путь к созданному файлу
-	configPath := strings.TrimSpace(string(output))
-	
-	// Проверяем что файл действительно создан
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return "", "", fmt.Errorf("config file was not created: %s", configPath)
+	configPath, err := s.createClientScript(clientName, output, opts)
+	if err != nil {
+		return "", "", err
 	}
 	
 	return clientName, configPath, nil
@@ -72,12 +184,18 @@ func (s *Service) CreateClient() (string, string, error) {
 
 // RemoveClient удаляет клиента OpenVPN
 func (s *Service) RemoveClient(clientName, configPath string) error {
+	if s.pki != nil {
+		return s.removeClientNative(clientName, configPath)
+	}
+	
 	// Путь к скрипту remove.sh
 	removeScript := filepath.Join(s.scriptsPath, "remove.sh")
 	
 	// Выполняем скрипт remove.sh
+	s.scriptMu.Lock()
 	cmd := exec.Command("sudo", removeScript, clientName, configPath)
 	output, err := cmd.CombinedOutput()
+	s.scriptMu.Unlock()
 	if err != nil {
 		return fmt.Errorf("failed to remove client: %w, output: %s", err, string(output))
 	}
@@ -87,6 +205,10 @@ func (s *Service) RemoveClient(clientName, configPath string) error {
 
 // ListClients возвращает список всех клиентов OpenVPN
 func (s *Service) ListClients() ([]string, error) {
+	if s.pki != nil {
+		return s.pki.ListClients()
+	}
+	
 	// Путь к скрипту remove.sh с флагом --list
 	removeScript := filepath.Join(s.scriptsPath, "remove.sh")
 	
@@ -122,7 +244,12 @@ func (s *Service) ListClients() ([]string, error) {
 	return clients, nil
 }
 
-// ReadConfigFile читает содержимое конфигурационного файла
+// ReadConfigFile читает содержимое конфигурационного файла. Нативный бэкенд
+// собирает удаленный файл заново из PKI
 func (s *Service) ReadConfigFile(configPath string) ([]byte, error) {
-	return os.ReadFile(configPath)
+	data, err := os.ReadFile(configPath)
+	if errors.Is(err, fs.ErrNotExist) && s.pki != nil {
+		return s.rerenderClientNative(configPath)
+	}
+	return data, err
 }
@@ -0,0 +1,152 @@
+package ovpn
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNoManagement возвращается WatchSessions, если management интерфейс не настроен
+var ErrNoManagement = errors.New("management interface is not configured")
+
+// SessionWatcher - провижинер, который сообщает счетчики сессий по мере их изменения,
+// в том числе итоговые счетчики отключившихся клиентов
+type SessionWatcher interface {
+	WatchSessions(ctx context.Context, interval time.Duration, handle func(ConnectedClient)) error
+}
+
+// managementConn возвращает общее соединение с management интерфейсом,
+// переподключаясь, если предыдущее разорвано
+func (s *Service) managementConn() (*Management, error) {
+	s.managementMu.Lock()
+	defer s.managementMu.Unlock()
+
+	if s.management != nil {
+		select {
+		case <-s.management.Done():
+		default:
+			return s.management, nil
+		}
+	}
+
+	m, err := DialManagement(s.managementAddr, s.managementPassword, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	s.management = m
+	return m, nil
+}
+
+// WatchSessions передает handle счетчики сессий из уведомлений management интерфейса:
+// каждые interval (>BYTECOUNT_CLI:) и при отключении клиента (>CLIENT:DISCONNECT,
+// OpenVPN присылает его только с management-client-auth). Так трафик между опросами
+// status не теряется, когда клиент отключается. Возвращается при разрыве соединения
+// или после отмены ctx
+func (s *Service) WatchSessions(ctx context.Context, interval time.Duration, handle func(ConnectedClient)) error {
+	if s.managementAddr == "" {
+		return ErrNoManagement
+	}
+
+	m, err := s.managementConn()
+	if err != nil {
+		return err
+	}
+	seconds := int(interval / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	if err := m.ByteCount(seconds); err != nil {
+		return err
+	}
+
+	// Сессии, установленные до подписки, известны только из status
+	clients, err := m.Status()
+	if err != nil {
+		return err
+	}
+	tracker := newSessionTracker(3 * interval)
+	now := time.Now()
+	for _, client := range clients {
+		tracker.add(client, now)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			tracker.prune(now)
+		case event, ok := <-m.Events():
+			if !ok {
+				return ErrManagementClosed
+			}
+			if client, ok := tracker.apply(event, time.Now()); ok {
+				handle(client)
+			}
+		}
+	}
+}
+
+// sessionTracker связывает CID из уведомлений >BYTECOUNT_CLI: с клиентами:
+// в самих уведомлениях есть только CID и счетчики
+type sessionTracker struct {
+	sessions map[int64]*trackedSession
+	// Сессии без обновлений дольше staleAfter считаются завершенными:
+	// без management-client-auth уведомление DISCONNECT не приходит
+	staleAfter time.Duration
+}
+
+type trackedSession struct {
+	client ConnectedClient
+	seen   time.Time
+}
+
+func newSessionTracker(staleAfter time.Duration) *sessionTracker {
+	return &sessionTracker{
+		sessions:   make(map[int64]*trackedSession),
+		staleAfter: staleAfter,
+	}
+}
+
+func (t *sessionTracker) add(client ConnectedClient, now time.Time) {
+	if client.ClientID < 0 {
+		return
+	}
+	t.sessions[client.ClientID] = &trackedSession{client: client, seen: now}
+}
+
+// apply обновляет сессии по уведомлению и возвращает сессию, счетчики которой изменились
+func (t *sessionTracker) apply(event ManagementEvent, now time.Time) (ConnectedClient, bool) {
+	switch {
+	case event.Client != nil:
+		switch event.Client.Action {
+		case "ESTABLISHED":
+			t.add(event.Client.Session(), now)
+		case "DISCONNECT":
+			delete(t.sessions, event.Client.ClientID)
+			return event.Client.Session(), true
+		}
+	case event.ByteCount != nil && event.Type == EventByteCountCli:
+		session, ok := t.sessions[event.ByteCount.ClientID]
+		if !ok {
+			return ConnectedClient{}, false
+		}
+		session.client.BytesReceived = event.ByteCount.BytesIn
+		session.client.BytesSent = event.ByteCount.BytesOut
+		session.seen = now
+		return session.client, true
+	}
+	return ConnectedClient{}, false
+}
+
+// prune забывает сессии, по которым давно не было уведомлений
+func (t *sessionTracker) prune(now time.Time) {
+	for id, session := range t.sessions {
+		if now.Sub(session.seen) > t.staleAfter {
+			delete(t.sessions, id)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package ovpn
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionTracker(t *testing.T) {
+	start := time.Unix(1714561200, 0)
+	tracker := newSessionTracker(time.Minute)
+	tracker.add(ConnectedClient{CommonName: "alice", ClientID: 3}, start)
+	// Без CID сессию нельзя сопоставить с уведомлениями
+	tracker.add(ConnectedClient{CommonName: "legacy", ClientID: -1}, start)
+
+	bytecount := func(id, in, out int64) ManagementEvent {
+		return ManagementEvent{Type: EventByteCountCli, ByteCount: &ByteCountEvent{ClientID: id, BytesIn: in, BytesOut: out}}
+	}
+
+	client, ok := tracker.apply(bytecount(3, 100, 200), start.Add(time.Second))
+	if !ok || client.CommonName != "alice" || client.BytesReceived != 100 || client.BytesSent != 200 {
+		t.Errorf("bytecount of known session = %+v, %v", client, ok)
+	}
+	if _, ok := tracker.apply(bytecount(-1, 1, 1), start); ok {
+		t.Error("bytecount without session was reported")
+	}
+
+	established := ManagementEvent{Type: EventClient, Client: &ClientEvent{
+		Action: "ESTABLISHED", ClientID: 4, KeyID: -1,
+		Env: map[string]string{"common_name": "bob", "trusted_ip": "198.51.100.7", "trusted_port": "1194", "time_unix": "1714561260"},
+	}}
+	if _, ok := tracker.apply(established, start.Add(time.Minute)); ok {
+		t.Error("ESTABLISHED was reported as usage")
+	}
+	client, ok = tracker.apply(bytecount(4, 10, 20), start.Add(time.Minute))
+	if !ok || client.CommonName != "bob" || client.RealAddress != "198.51.100.7:1194" ||
+		client.ConnectedSince.Unix() != 1714561260 || client.BytesReceived != 10 {
+		t.Errorf("bytecount of established session = %+v, %v", client, ok)
+	}
+
+	disconnect := ManagementEvent{Type: EventClient, Client: &ClientEvent{
+		Action: "DISCONNECT", ClientID: 4, KeyID: -1,
+		Env: map[string]string{"common_name": "bob", "bytes_received": "15", "bytes_sent": "25"},
+	}}
+	client, ok = tracker.apply(disconnect, start.Add(time.Minute))
+	if !ok || client.BytesReceived != 15 || client.BytesSent != 25 {
+		t.Errorf("disconnect = %+v, %v", client, ok)
+	}
+	if _, ok := tracker.apply(bytecount(4, 30, 40), start.Add(time.Minute)); ok {
+		t.Error("bytecount after disconnect was reported")
+	}
+
+	// alice не обновлялась дольше staleAfter: без DISCONNECT сессия забывается
+	tracker.prune(start.Add(2 * time.Minute))
+	if _, ok := tracker.apply(bytecount(3, 300, 400), start.Add(2*time.Minute)); ok {
+		t.Error("stale session was not pruned")
+	}
+}
+
+func TestServiceWatchSessions(t *testing.T) {
+	f := newFakeManagement(t, testManagementPassword, false)
+
+	s := New(t.TempDir(), t.TempDir(), "client")
+	s.SetManagement(f.addr(), testManagementPassword)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sessions := make(chan ConnectedClient, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- s.WatchSessions(ctx, 5*time.Second, func(client ConnectedClient) {
+			sessions <- client
+		})
+	}()
+
+	// alice известна из status, поэтому ее счетчики сопоставляются по CID,
+	// а итоговые счетчики приходят с отключением; CID 7 неизвестен
+	want := []ConnectedClient{
+		{CommonName: "alice", BytesReceived: 4096, BytesSent: 8192},
+		{CommonName: "alice", BytesReceived: 5000, BytesSent: 9000},
+	}
+	for _, w := range want {
+		select {
+		case got := <-sessions:
+			if got.CommonName != w.CommonName || got.RealAddress != "203.0.113.5:51000" ||
+				got.ConnectedSince.Unix() != 1714561200 || got.BytesReceived != w.BytesReceived || got.BytesSent != w.BytesSent {
+				t.Errorf("session = %+v, want %+v", got, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no session update")
+		}
+	}
+
+	// Команды идут через то же соединение, что и уведомления
+	if _, err := s.ConnectedClients(); err != nil {
+		t.Errorf("ConnectedClients while watching: %v", err)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("WatchSessions = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WatchSessions did not stop")
+	}
+
+	for _, want := range []string{"bytecount 5", "status 3", "status 3"} {
+		if cmd := <-f.commands; cmd != want {
+			t.Errorf("server received %q, want %q", cmd, want)
+		}
+	}
+	if n := f.connections.Load(); n != 1 {
+		t.Errorf("server accepted %d connections, want 1", n)
+	}
+}
+
+func TestServiceWatchSessionsWithoutManagement(t *testing.T) {
+	s := New(t.TempDir(), t.TempDir(), "client")
+	err := s.WatchSessions(context.Background(), time.Second, func(ConnectedClient) {})
+	if !errors.Is(err, ErrNoManagement) {
+		t.Errorf("WatchSessions = %v, want ErrNoManagement", err)
+	}
+}
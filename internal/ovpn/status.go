@@ -0,0 +1,232 @@
+package ovpn
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConnectedClient - клиент, подключенный к серверу в данный момент
+type ConnectedClient struct {
+	CommonName     string `json:"common_name"`
+	RealAddress    string `json:"real_address"`
+	VirtualAddress string `json:"virtual_address"`
+	VirtualIPv6    string `json:"virtual_ipv6"`
+	// BytesReceived - получено сервером от клиента, BytesSent - отправлено клиенту
+	BytesReceived  int64     `json:"bytes_received"`
+	BytesSent      int64     `json:"bytes_sent"`
+	ConnectedSince time.Time `json:"connected_since"`
+	// ClientID есть только в status-version 2 и 3 (иначе -1)
+	ClientID int64 `json:"client_id"`
+}
+
+// StatusSource - провижинер, который умеет сообщать о подключенных клиентах
+type StatusSource interface {
+	ConnectedClients() ([]ConnectedClient, error)
+}
+
+// Форматы даты в status.log разных версий OpenVPN
+var statusTimeLayouts = []string{
+	time.ANSIC,
+	"2006-01-02 15:04:05",
+}
+
+// ReadStatusFile читает и разбирает status.log OpenVPN
+func ReadStatusFile(path string) ([]ConnectedClient, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open status file: %w", err)
+	}
+	defer f.Close()
+
+	return ParseStatus(f)
+}
+
+// ParseStatus разбирает status.log в форматах status-version 1, 2 и 3.
+// Версия определяется по содержимому файла
+func ParseStatus(r io.Reader) ([]ConnectedClient, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read status: %w", err)
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	if lines[0] == "OpenVPN CLIENT LIST" {
+		return parseStatusV1(lines)
+	}
+
+	// Версия 3 отличается от версии 2 только разделителем
+	sep := ","
+	if strings.Contains(lines[0], "\t") {
+		sep = "\t"
+	}
+	return parseStatusV2(lines, sep)
+}
+
+// parseStatusV1 разбирает формат "OpenVPN CLIENT LIST" с таблицами клиентов и маршрутов
+func parseStatusV1(lines []string) ([]ConnectedClient, error) {
+	var clients []ConnectedClient
+	byName := make(map[string]int)
+
+	section := ""
+	for _, line := range lines {
+		switch line {
+		case "OpenVPN CLIENT LIST", "ROUTING TABLE", "GLOBAL STATS", "END":
+			section = line
+			continue
+		}
+		if strings.HasPrefix(line, "Updated,") || strings.HasPrefix(line, "Common Name,") ||
+			strings.HasPrefix(line, "Virtual Address,") {
+			continue
+		}
+
+		fields := strings.Split(line, ",")
+		switch section {
+		case "OpenVPN CLIENT LIST":
+			if len(fields) < 5 {
+				return nil, fmt.Errorf("malformed client line: %q", line)
+			}
+			client := ConnectedClient{
+				CommonName:  fields[0],
+				RealAddress: fields[1],
+				ClientID:    -1,
+			}
+			var err error
+			if client.BytesReceived, client.BytesSent, err = parseStatusBytes(fields[2], fields[3]); err != nil {
+				return nil, fmt.Errorf("malformed client line: %q", line)
+			}
+			client.ConnectedSince = parseStatusTime(fields[4])
+
+			byName[client.CommonName+"|"+client.RealAddress] = len(clients)
+			clients = append(clients, client)
+		case "ROUTING TABLE":
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("malformed routing line: %q", line)
+			}
+			if i, ok := byName[fields[1]+"|"+fields[2]]; ok {
+				setVirtualAddress(&clients[i], fields[0])
+			}
+		}
+	}
+
+	return clients, nil
+}
+
+// parseStatusV2 разбирает формат с префиксами HEADER/CLIENT_LIST/ROUTING_TABLE.
+// Колонки определяются по строке HEADER, поэтому поддерживаются разные версии OpenVPN
+func parseStatusV2(lines []string, sep string) ([]ConnectedClient, error) {
+	var clients []ConnectedClient
+	headers := make(map[string]map[string]int)
+	byName := make(map[string]int)
+
+	for _, line := range lines {
+		fields := strings.Split(line, sep)
+		switch fields[0] {
+		case "HEADER":
+			if len(fields) < 2 {
+				continue
+			}
+			columns := make(map[string]int)
+			for i, name := range fields[2:] {
+				columns[name] = i + 1
+			}
+			headers[fields[1]] = columns
+		case "CLIENT_LIST":
+			columns, ok := headers["CLIENT_LIST"]
+			if !ok {
+				return nil, fmt.Errorf("CLIENT_LIST before HEADER")
+			}
+			// Строка короче заголовка - файл обрезан на середине записи
+			if len(fields) < len(columns)+1 {
+				return nil, fmt.Errorf("malformed client line: %q", line)
+			}
+			get := func(name string) string {
+				if i, ok := columns[name]; ok && i < len(fields) {
+					return fields[i]
+				}
+				return ""
+			}
+
+			client := ConnectedClient{
+				CommonName:     get("Common Name"),
+				RealAddress:    get("Real Address"),
+				VirtualAddress: get("Virtual Address"),
+				VirtualIPv6:    get("Virtual IPv6 Address"),
+				ClientID:       -1,
+			}
+			var err error
+			if client.BytesReceived, client.BytesSent, err = parseStatusBytes(get("Bytes Received"), get("Bytes Sent")); err != nil {
+				return nil, fmt.Errorf("malformed client line: %q", line)
+			}
+			if id, err := strconv.ParseInt(get("Client ID"), 10, 64); err == nil {
+				client.ClientID = id
+			}
+			if ts, err := strconv.ParseInt(get("Connected Since (time_t)"), 10, 64); err == nil {
+				client.ConnectedSince = time.Unix(ts, 0)
+			} else {
+				client.ConnectedSince = parseStatusTime(get("Connected Since"))
+			}
+
+			byName[client.CommonName+"|"+client.RealAddress] = len(clients)
+			clients = append(clients, client)
+		case "ROUTING_TABLE":
+			// Старые версии не пишут виртуальный адрес в CLIENT_LIST
+			if len(fields) < 4 {
+				continue
+			}
+			if i, ok := byName[fields[2]+"|"+fields[3]]; ok {
+				setVirtualAddress(&clients[i], fields[1])
+			}
+		}
+	}
+
+	return clients, nil
+}
+
+func setVirtualAddress(client *ConnectedClient, addr string) {
+	if strings.Contains(addr, ":") {
+		if client.VirtualIPv6 == "" {
+			client.VirtualIPv6 = addr
+		}
+	} else if client.VirtualAddress == "" {
+		client.VirtualAddress = addr
+	}
+}
+
+// parseStatusBytes разбирает счетчики трафика: нулевые счетчики вместо испорченных
+// сбросили бы накопленную статистику сессии
+func parseStatusBytes(received, sent string) (int64, int64, error) {
+	in, err := strconv.ParseInt(received, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	out, err := strconv.ParseInt(sent, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return in, out, nil
+}
+
+func parseStatusTime(value string) time.Time {
+	for _, layout := range statusTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,154 @@
+package ovpn
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Фикстуры status.log в том виде, в каком их пишет OpenVPN с разными status-version
+const (
+	testStatusV1 = `OpenVPN CLIENT LIST
+Updated,Wed May  1 10:00:00 2024
+Common Name,Real Address,Bytes Received,Bytes Sent,Connected Since
+client_alice,203.0.113.5:52358,12345,67890,Wed May  1 09:55:00 2024
+client_bob,198.51.100.7:1194,100,200,Wed May  1 09:58:30 2024
+ROUTING TABLE
+Virtual Address,Common Name,Real Address,Last Ref
+10.8.0.2,client_alice,203.0.113.5:52358,Wed May  1 09:59:59 2024
+fdd1:1::1000,client_alice,203.0.113.5:52358,Wed May  1 09:59:59 2024
+10.8.0.3,client_bob,198.51.100.7:1194,Wed May  1 09:59:00 2024
+GLOBAL STATS
+Max bcast/mcast queue length,1
+END
+`
+
+	testStatusV2 = `TITLE,OpenVPN 2.5.9 x86_64-pc-linux-gnu [SSL (OpenSSL)] [LZO] [LZ4] [EPOLL] [MH/PKTINFO] [AEAD]
+TIME,2024-05-01 10:00:00,1714557600
+HEADER,CLIENT_LIST,Common Name,Real Address,Virtual Address,Virtual IPv6 Address,Bytes Received,Bytes Sent,Connected Since,Connected Since (time_t),Username,Client ID,Peer ID,Data Channel Cipher
+CLIENT_LIST,client_alice,203.0.113.5:52358,10.8.0.2,fdd1:1::1000,12345,67890,2024-05-01 09:55:00,1714557300,UNDEF,3,0,AES-128-GCM
+CLIENT_LIST,client_bob,198.51.100.7:1194,10.8.0.3,,100,200,2024-05-01 09:58:30,1714557510,UNDEF,7,1,AES-128-GCM
+HEADER,ROUTING_TABLE,Virtual Address,Common Name,Real Address,Last Ref,Last Ref (time_t)
+ROUTING_TABLE,10.8.0.2,client_alice,203.0.113.5:52358,2024-05-01 09:59:59,1714557599
+ROUTING_TABLE,10.8.0.3,client_bob,198.51.100.7:1194,2024-05-01 09:59:00,1714557540
+GLOBAL_STATS,Max bcast/mcast queue length,1
+END
+`
+
+	// OpenVPN 2.3 не пишет виртуальные адреса и Client ID в CLIENT_LIST
+	testStatusV2Legacy = `TITLE,OpenVPN 2.3.10 x86_64-pc-linux-gnu
+TIME,Wed May  1 10:00:00 2024,1714557600
+HEADER,CLIENT_LIST,Common Name,Real Address,Virtual Address,Bytes Received,Bytes Sent,Connected Since,Connected Since (time_t),Username
+CLIENT_LIST,client_alice,203.0.113.5:52358,,12345,67890,Wed May  1 09:55:00 2024,1714557300,UNDEF
+HEADER,ROUTING_TABLE,Virtual Address,Common Name,Real Address,Last Ref,Last Ref (time_t)
+ROUTING_TABLE,10.8.0.2,client_alice,203.0.113.5:52358,Wed May  1 09:59:59 2024,1714557599
+GLOBAL_STATS,Max bcast/mcast queue length,0
+END
+`
+)
+
+func TestParseStatus(t *testing.T) {
+	// status-version 1 пишет время без зоны, в локальном времени сервера
+	localTime := func(value string) time.Time {
+		ts, err := time.ParseInLocation(time.ANSIC, value, time.Local)
+		if err != nil {
+			t.Fatalf("ParseInLocation: %v", err)
+		}
+		return ts
+	}
+
+	alice := ConnectedClient{
+		CommonName: "client_alice", RealAddress: "203.0.113.5:52358",
+		VirtualAddress: "10.8.0.2", VirtualIPv6: "fdd1:1::1000",
+		BytesReceived: 12345, BytesSent: 67890,
+		ConnectedSince: time.Unix(1714557300, 0), ClientID: 3,
+	}
+	bob := ConnectedClient{
+		CommonName: "client_bob", RealAddress: "198.51.100.7:1194",
+		VirtualAddress: "10.8.0.3",
+		BytesReceived:  100, BytesSent: 200,
+		ConnectedSince: time.Unix(1714557510, 0), ClientID: 7,
+	}
+
+	aliceV1, bobV1 := alice, bob
+	aliceV1.ConnectedSince, aliceV1.ClientID = localTime("Wed May  1 09:55:00 2024"), -1
+	bobV1.ConnectedSince, bobV1.ClientID = localTime("Wed May  1 09:58:30 2024"), -1
+
+	aliceLegacy := alice
+	aliceLegacy.VirtualIPv6, aliceLegacy.ClientID = "", -1
+
+	tests := []struct {
+		name   string
+		status string
+		want   []ConnectedClient
+	}{
+		{"version 1", testStatusV1, []ConnectedClient{aliceV1, bobV1}},
+		{"version 2", testStatusV2, []ConnectedClient{alice, bob}},
+		{"version 3", strings.ReplaceAll(testStatusV2, ",", "\t"), []ConnectedClient{alice, bob}},
+		{"version 2 from OpenVPN 2.3", testStatusV2Legacy, []ConnectedClient{aliceLegacy}},
+		{"version 2 with CRLF", strings.ReplaceAll(testStatusV2, "\n", "\r\n"), []ConnectedClient{alice, bob}},
+		{"no clients", "OpenVPN CLIENT LIST\nUpdated,Wed May  1 10:00:00 2024\nCommon Name,Real Address,Bytes Received,Bytes Sent,Connected Since\nROUTING TABLE\nGLOBAL STATS\nEND\n", nil},
+		{"empty", "", nil},
+		// Файл, обрезанный между строками, разбирается до места обрыва
+		{"version 1 truncated after clients", testStatusV1[:strings.Index(testStatusV1, "ROUTING TABLE")], []ConnectedClient{
+			{CommonName: "client_alice", RealAddress: "203.0.113.5:52358", BytesReceived: 12345, BytesSent: 67890, ConnectedSince: aliceV1.ConnectedSince, ClientID: -1},
+			{CommonName: "client_bob", RealAddress: "198.51.100.7:1194", BytesReceived: 100, BytesSent: 200, ConnectedSince: bobV1.ConnectedSince, ClientID: -1},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStatus(strings.NewReader(tt.status))
+			if err != nil {
+				t.Fatalf("ParseStatus: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseStatus =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStatusMalformed(t *testing.T) {
+	clientLine := "CLIENT_LIST,client_alice,203.0.113.5:52358,10.8.0.2,fdd1:1::1000,12345,67890"
+	v2Header := testStatusV2[:strings.Index(testStatusV2, "\nCLIENT_LIST,")+1]
+
+	tests := []struct {
+		name   string
+		status string
+	}{
+		{"version 1 short client line", "OpenVPN CLIENT LIST\nclient_alice,203.0.113.5:52358,12345\n"},
+		{"version 1 bad byte counter", "OpenVPN CLIENT LIST\nclient_alice,203.0.113.5:52358,12x,67890,Wed May  1 09:55:00 2024\n"},
+		{"version 1 short routing line", "OpenVPN CLIENT LIST\nROUTING TABLE\n10.8.0.2,client_alice\n"},
+		{"version 2 client before header", clientLine + "\n"},
+		{"version 2 truncated client line", v2Header + clientLine},
+		{"version 2 bad byte counter", v2Header + "CLIENT_LIST,client_alice,203.0.113.5:52358,10.8.0.2,,-,67890,2024-05-01 09:55:00,1714557300,UNDEF,3,0,AES-128-GCM\n"},
+		{"line too long", "OpenVPN CLIENT LIST\n" + strings.Repeat("a", 2*1024*1024) + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if clients, err := ParseStatus(strings.NewReader(tt.status)); err == nil {
+				t.Errorf("ParseStatus = %+v, want error", clients)
+			}
+		})
+	}
+}
+
+func TestReadStatusFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.log")
+	if err := os.WriteFile(path, []byte(testStatusV2), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	clients, err := ReadStatusFile(path)
+	if err != nil || len(clients) != 2 {
+		t.Fatalf("ReadStatusFile = %+v, %v", clients, err)
+	}
+
+	if _, err := ReadStatusFile(filepath.Join(t.TempDir(), "absent.log")); err == nil {
+		t.Error("ReadStatusFile of missing file returned no error")
+	}
+}